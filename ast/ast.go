@@ -3,6 +3,7 @@ package ast
 import (
 	"bytes"
 	"github.com/noculture/plug/token"
+	"math/big"
 	"strings"
 )
 
@@ -46,6 +47,7 @@ func (program *Program) String() string {
 type LetStatement struct {
 	Token token.Token // the token.LET token
 	Name  *Identifier
+	Type  *TypeAnnotation // nil unless the binding is annotated
 	Value Expression
 }
 
@@ -56,6 +58,9 @@ func (letStatement *LetStatement) String() string {
 
 	out.WriteString(letStatement.TokenLiteral() + " ")
 	out.WriteString(letStatement.Name.String())
+	if letStatement.Type != nil {
+		out.WriteString(": " + letStatement.Type.String())
+	}
 	out.WriteString(" = ")
 	if letStatement.Value != nil {
 		out.WriteString(letStatement.Value.String())
@@ -65,11 +70,14 @@ func (letStatement *LetStatement) String() string {
 	return out.String()
 }
 
+// ForStatement is either a counted loop, `for i = range(n) { ... }`, or
+// a loop over the elements of an iterable, `for x in xs { ... }`.
 type ForStatement struct {
-	Token token.Token
-	Index *Identifier
-	Range *CallExpression
-	Body  *BlockStatement
+	Token    token.Token
+	Index    *Identifier
+	Range    *CallExpression
+	Iterable Expression
+	Body     *BlockStatement
 }
 
 func (forStatement *ForStatement) statementNode()       {}
@@ -79,8 +87,13 @@ func (forStatement *ForStatement) String() string {
 
 	out.WriteString(forStatement.TokenLiteral() + " ")
 	out.WriteString(forStatement.Index.String())
-	out.WriteString(" in ")
-	out.WriteString(forStatement.Range.String())
+	if forStatement.Iterable != nil {
+		out.WriteString(" in ")
+		out.WriteString(forStatement.Iterable.String())
+	} else {
+		out.WriteString(" = ")
+		out.WriteString(forStatement.Range.String())
+	}
 	out.WriteString(" {")
 	out.WriteString(forStatement.Body.String())
 	out.WriteString("}")
@@ -88,6 +101,170 @@ func (forStatement *ForStatement) String() string {
 	return out.String()
 }
 
+type TryStatement struct {
+	Token     token.Token // the token.TRY token
+	Block     *BlockStatement
+	Parameter *Identifier // the name the caught error is bound to, may be nil
+	Catch     *BlockStatement
+	Finally   *BlockStatement
+}
+
+func (tryStatement *TryStatement) statementNode()       {}
+func (tryStatement *TryStatement) TokenLiteral() string { return tryStatement.Token.Literal }
+func (tryStatement *TryStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("try {")
+	out.WriteString(tryStatement.Block.String())
+	out.WriteString("}")
+	if tryStatement.Catch != nil {
+		out.WriteString(" catch ")
+		if tryStatement.Parameter != nil {
+			out.WriteString("(" + tryStatement.Parameter.String() + ") ")
+		}
+		out.WriteString("{")
+		out.WriteString(tryStatement.Catch.String())
+		out.WriteString("}")
+	}
+	if tryStatement.Finally != nil {
+		out.WriteString(" finally {")
+		out.WriteString(tryStatement.Finally.String())
+		out.WriteString("}")
+	}
+
+	return out.String()
+}
+
+type ThrowStatement struct {
+	Token token.Token // the token.THROW token
+	Value Expression
+}
+
+func (throwStatement *ThrowStatement) statementNode()       {}
+func (throwStatement *ThrowStatement) TokenLiteral() string { return throwStatement.Token.Literal }
+func (throwStatement *ThrowStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(throwStatement.TokenLiteral() + " ")
+	if throwStatement.Value != nil {
+		out.WriteString(throwStatement.Value.String())
+	}
+	out.WriteString(";")
+
+	return out.String()
+}
+
+type StructStatement struct {
+	Token   token.Token // the token.STRUCT token
+	Name    *Identifier
+	Fields  []*Identifier
+	Methods []*FunctionLiteral
+}
+
+func (structStatement *StructStatement) statementNode()       {}
+func (structStatement *StructStatement) TokenLiteral() string { return structStatement.Token.Literal }
+func (structStatement *StructStatement) String() string {
+	var out bytes.Buffer
+	var fields []string
+
+	for _, field := range structStatement.Fields {
+		fields = append(fields, field.String())
+	}
+
+	out.WriteString("struct " + structStatement.Name.String() + " {")
+	out.WriteString(strings.Join(fields, ", "))
+	for _, method := range structStatement.Methods {
+		out.WriteString("; ")
+		out.WriteString(method.String())
+	}
+	out.WriteString("}")
+
+	return out.String()
+}
+
+type EnumStatement struct {
+	Token    token.Token // the token.ENUM token
+	Name     *Identifier
+	Variants []*EnumVariant
+}
+
+// EnumVariant is a single variant of an enum declaration, Fields names the
+// payload the variant carries and is empty for plain variants.
+type EnumVariant struct {
+	Name   *Identifier
+	Fields []*Identifier
+}
+
+func (enumStatement *EnumStatement) statementNode()       {}
+func (enumStatement *EnumStatement) TokenLiteral() string { return enumStatement.Token.Literal }
+func (enumStatement *EnumStatement) String() string {
+	var out bytes.Buffer
+	var variants []string
+
+	for _, variant := range enumStatement.Variants {
+		variants = append(variants, variant.String())
+	}
+
+	out.WriteString("enum " + enumStatement.Name.String() + " {")
+	out.WriteString(strings.Join(variants, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// ImportStatement binds the exports of another file to a name,
+// `import "lib/util.plug" as util`.
+type ImportStatement struct {
+	Token token.Token // the token.IMPORT token
+	Path  *StringLiteral
+	Name  *Identifier
+}
+
+func (importStatement *ImportStatement) statementNode()       {}
+func (importStatement *ImportStatement) TokenLiteral() string { return importStatement.Token.Literal }
+func (importStatement *ImportStatement) String() string {
+	return "import \"" + importStatement.Path.Value + "\" as " + importStatement.Name.String() + ";"
+}
+
+// ExportStatement makes the let, struct or enum it wraps visible to files
+// that import the one it appears in.
+type ExportStatement struct {
+	Token     token.Token // the token.EXPORT token
+	Statement Statement
+}
+
+func (exportStatement *ExportStatement) statementNode()       {}
+func (exportStatement *ExportStatement) TokenLiteral() string { return exportStatement.Token.Literal }
+func (exportStatement *ExportStatement) String() string {
+	return "export " + exportStatement.Statement.String()
+}
+
+// Name is the name the exported declaration binds.
+func (exportStatement *ExportStatement) Name() string {
+	switch statement := exportStatement.Statement.(type) {
+	case *LetStatement:
+		return statement.Name.Value
+	case *StructStatement:
+		return statement.Name.Value
+	case *EnumStatement:
+		return statement.Name.Value
+	}
+	return ""
+}
+
+func (variant *EnumVariant) String() string {
+	if len(variant.Fields) == 0 {
+		return variant.Name.String()
+	}
+
+	var fields []string
+	for _, field := range variant.Fields {
+		fields = append(fields, field.String())
+	}
+
+	return variant.Name.String() + "(" + strings.Join(fields, ", ") + ")"
+}
+
 type Identifier struct {
 	Token token.Token
 	Value string
@@ -97,6 +274,23 @@ func (identifier *Identifier) expressionNode()      {}
 func (identifier *Identifier) TokenLiteral() string { return identifier.Token.Literal }
 func (identifier *Identifier) String() string       { return identifier.Value }
 
+// TypeAnnotation is an optional type written after a let binding, parameter
+// or function signature. Array types, [T], name the type of their elements.
+// The evaluator ignores annotations, they are only read by the checker.
+type TypeAnnotation struct {
+	Token   token.Token
+	Name    string
+	Element *TypeAnnotation
+}
+
+func (annotation *TypeAnnotation) TokenLiteral() string { return annotation.Token.Literal }
+func (annotation *TypeAnnotation) String() string {
+	if annotation.Element != nil {
+		return "[" + annotation.Element.String() + "]"
+	}
+	return annotation.Name
+}
+
 type ReturnStatement struct {
 	Token       token.Token
 	ReturnValue Expression
@@ -117,6 +311,25 @@ func (returnStatement *ReturnStatement) String() string {
 	return out.String()
 }
 
+type YieldStatement struct {
+	Token token.Token // the token.YIELD token
+	Value Expression
+}
+
+func (yieldStatement *YieldStatement) statementNode()       {}
+func (yieldStatement *YieldStatement) TokenLiteral() string { return yieldStatement.Token.Literal }
+func (yieldStatement *YieldStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(yieldStatement.TokenLiteral() + " ")
+	if yieldStatement.Value != nil {
+		out.WriteString(yieldStatement.Value.String())
+	}
+	out.WriteString(";")
+
+	return out.String()
+}
+
 type ExpressionStatement struct {
 	Token      token.Token
 	Expression Expression
@@ -195,12 +408,22 @@ func (infixExp *InfixExpression) String() string {
 type IntegerLiteral struct {
 	Token token.Token
 	Value int64
+	Big   *big.Int // set instead of Value when the literal does not fit in 64 bits
 }
 
 func (literal *IntegerLiteral) expressionNode()      {}
 func (literal *IntegerLiteral) TokenLiteral() string { return literal.Token.Literal }
 func (literal *IntegerLiteral) String() string       { return literal.Token.Literal }
 
+// DecimalLiteral is a number written with a fractional part, such as 12.50.
+type DecimalLiteral struct {
+	Token token.Token
+}
+
+func (literal *DecimalLiteral) expressionNode()      {}
+func (literal *DecimalLiteral) TokenLiteral() string { return literal.Token.Literal }
+func (literal *DecimalLiteral) String() string       { return literal.Token.Literal }
+
 type StringLiteral struct {
 	Token token.Token
 	Value string
@@ -244,9 +467,14 @@ func (ifExp *IfExpression) String() string {
 }
 
 type FunctionLiteral struct {
-	Token      token.Token
-	Parameters []*Identifier
-	Body       *BlockStatement
+	Token          token.Token
+	Name           string // only set for methods declared inside a struct
+	Parameters     []*Identifier
+	ParameterTypes []*TypeAnnotation // one per parameter, nil where it is not annotated
+	ReturnType     *TypeAnnotation
+	Body           *BlockStatement
+	Generator      bool // set when the body yields
+	Async          bool
 }
 
 func (funcLiteral *FunctionLiteral) expressionNode()      {}
@@ -255,19 +483,59 @@ func (funcLiteral *FunctionLiteral) String() string {
 	var out bytes.Buffer
 	var params []string
 
-	for _, param := range funcLiteral.Parameters {
-		params = append(params, param.String())
+	for index, param := range funcLiteral.Parameters {
+		if index < len(funcLiteral.ParameterTypes) && funcLiteral.ParameterTypes[index] != nil {
+			params = append(params, param.String()+": "+funcLiteral.ParameterTypes[index].String())
+		} else {
+			params = append(params, param.String())
+		}
 	}
 
+	if funcLiteral.Async {
+		out.WriteString("async ")
+	}
 	out.WriteString("func")
+	if funcLiteral.Name != "" {
+		out.WriteString(" " + funcLiteral.Name)
+	}
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(")")
+	if funcLiteral.ReturnType != nil {
+		out.WriteString(": " + funcLiteral.ReturnType.String())
+	}
 	out.WriteString(funcLiteral.Body.String())
 
 	return out.String()
 }
 
+// MacroLiteral is `macro(params) { body }`. Macros are taken out of the
+// program before it runs and called on the syntax of their arguments.
+type MacroLiteral struct {
+	Token      token.Token // the token.MACRO token
+	Parameters []*Identifier
+	Body       *BlockStatement
+}
+
+func (macroLiteral *MacroLiteral) expressionNode()      {}
+func (macroLiteral *MacroLiteral) TokenLiteral() string { return macroLiteral.Token.Literal }
+func (macroLiteral *MacroLiteral) String() string {
+	var out bytes.Buffer
+	var params []string
+
+	for _, param := range macroLiteral.Parameters {
+		params = append(params, param.String())
+	}
+
+	out.WriteString(macroLiteral.TokenLiteral())
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(")")
+	out.WriteString(macroLiteral.Body.String())
+
+	return out.String()
+}
+
 type CallExpression struct {
 	Token     token.Token
 	Function  Expression
@@ -314,6 +582,31 @@ func (arrLiteral *ArrayLiteral) String() string {
 	return out.String()
 }
 
+// HashLiteral keeps its pairs in the order they were written, Keys[i] maps
+// to Values[i].
+type HashLiteral struct {
+	Token  token.Token // the '{' token
+	Keys   []Expression
+	Values []Expression
+}
+
+func (hashLiteral *HashLiteral) expressionNode()      {}
+func (hashLiteral *HashLiteral) TokenLiteral() string { return hashLiteral.Token.Literal }
+func (hashLiteral *HashLiteral) String() string {
+	var out bytes.Buffer
+	var pairs []string
+
+	for index, key := range hashLiteral.Keys {
+		pairs = append(pairs, key.String()+": "+hashLiteral.Values[index].String())
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
 type IndexExpression struct {
 	Token token.Token
 	Left  Expression
@@ -333,3 +626,115 @@ func (indexExp *IndexExpression) String() string {
 
 	return out.String()
 }
+
+type DotExpression struct {
+	Token token.Token // the '.' token
+	Left  Expression
+	Field *Identifier
+}
+
+func (dotExp *DotExpression) expressionNode()      {}
+func (dotExp *DotExpression) TokenLiteral() string { return dotExp.Token.Literal }
+func (dotExp *DotExpression) String() string {
+	return dotExp.Left.String() + "." + dotExp.Field.String()
+}
+
+type AssignExpression struct {
+	Token  token.Token // the '=' token
+	Target Expression  // an identifier or a field access
+	Value  Expression
+}
+
+func (assignExp *AssignExpression) expressionNode()      {}
+func (assignExp *AssignExpression) TokenLiteral() string { return assignExp.Token.Literal }
+func (assignExp *AssignExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(assignExp.Target.String())
+	out.WriteString(" = ")
+	out.WriteString(assignExp.Value.String())
+	out.WriteString(")")
+
+	return out.String()
+}
+
+type MatchExpression struct {
+	Token   token.Token // the token.MATCH token
+	Subject Expression
+	Arms    []*MatchArm
+}
+
+// MatchArm is a single `pattern => body` arm. A wildcard arm (`_`) has no
+// enum or variant and matches anything.
+type MatchArm struct {
+	Token    token.Token // the first token of the pattern
+	Enum     *Identifier
+	Variant  *Identifier
+	Bindings []*Identifier
+	Body     *BlockStatement
+}
+
+func (matchExp *MatchExpression) expressionNode()      {}
+func (matchExp *MatchExpression) TokenLiteral() string { return matchExp.Token.Literal }
+func (matchExp *MatchExpression) String() string {
+	var out bytes.Buffer
+	var arms []string
+
+	for _, arm := range matchExp.Arms {
+		arms = append(arms, arm.String())
+	}
+
+	out.WriteString("match (")
+	out.WriteString(matchExp.Subject.String())
+	out.WriteString(") {")
+	out.WriteString(strings.Join(arms, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+func (arm *MatchArm) IsWildcard() bool { return arm.Variant == nil }
+
+func (arm *MatchArm) String() string {
+	var out bytes.Buffer
+
+	if arm.IsWildcard() {
+		out.WriteString("_")
+	} else {
+		out.WriteString(arm.Enum.String() + "." + arm.Variant.String())
+		if arm.Bindings != nil {
+			var bindings []string
+			for _, binding := range arm.Bindings {
+				bindings = append(bindings, binding.String())
+			}
+			out.WriteString("(" + strings.Join(bindings, ", ") + ")")
+		}
+	}
+	out.WriteString(" => ")
+	out.WriteString(arm.Body.String())
+
+	return out.String()
+}
+
+type SpawnExpression struct {
+	Token token.Token // the token.SPAWN token
+	Call  *CallExpression
+}
+
+func (spawnExp *SpawnExpression) expressionNode()      {}
+func (spawnExp *SpawnExpression) TokenLiteral() string { return spawnExp.Token.Literal }
+func (spawnExp *SpawnExpression) String() string {
+	return "spawn " + spawnExp.Call.String()
+}
+
+type AwaitExpression struct {
+	Token token.Token // the token.AWAIT token
+	Value Expression
+}
+
+func (awaitExp *AwaitExpression) expressionNode()      {}
+func (awaitExp *AwaitExpression) TokenLiteral() string { return awaitExp.Token.Literal }
+func (awaitExp *AwaitExpression) String() string {
+	return "await " + awaitExp.Value.String()
+}
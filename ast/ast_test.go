@@ -2,6 +2,7 @@ package ast
 
 import (
 	"github.com/noculture/plug/token"
+	"reflect"
 	"testing"
 )
 
@@ -26,3 +27,109 @@ func TestString(t *testing.T) {
 		t.Errorf("program string is wrong, got %q", program.String())
 	}
 }
+
+func TestModify(t *testing.T) {
+	one := func() Expression { return &IntegerLiteral{Value: 1} }
+	two := func() Expression { return &IntegerLiteral{Value: 2} }
+
+	turnOneIntoTwo := func(node Node) Node {
+		integer, ok := node.(*IntegerLiteral)
+		if !ok || integer.Value != 1 {
+			return node
+		}
+		integer.Value = 2
+		return integer
+	}
+
+	testCases := []struct {
+		input    Node
+		expected Node
+	}{
+		{one(), two()},
+		{
+			&Program{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+			&Program{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+		},
+		{&InfixExpression{Left: one(), Operator: "+", Right: two()}, &InfixExpression{Left: two(), Operator: "+", Right: two()}},
+		{&PrefixExpression{Operator: "-", Right: one()}, &PrefixExpression{Operator: "-", Right: two()}},
+		{&IndexExpression{Left: one(), Index: one()}, &IndexExpression{Left: two(), Index: two()}},
+		{
+			&IfExpression{
+				Condition:   one(),
+				Consequence: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+			},
+			&IfExpression{
+				Condition:   two(),
+				Consequence: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+			},
+		},
+		{&ReturnStatement{ReturnValue: one()}, &ReturnStatement{ReturnValue: two()}},
+		{&LetStatement{Value: one()}, &LetStatement{Value: two()}},
+		{
+			&FunctionLiteral{Body: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}}},
+			&FunctionLiteral{Body: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}}},
+		},
+		{&ArrayLiteral{Elements: []Expression{one(), one()}}, &ArrayLiteral{Elements: []Expression{two(), two()}}},
+		{&CallExpression{Function: one(), Arguments: []Expression{one()}}, &CallExpression{Function: two(), Arguments: []Expression{two()}}},
+		{&AssignExpression{Target: &Identifier{Value: "x"}, Value: one()}, &AssignExpression{Target: &Identifier{Value: "x"}, Value: two()}},
+		{&AwaitExpression{Value: one()}, &AwaitExpression{Value: two()}},
+		{
+			&TryStatement{
+				Block: &BlockStatement{Statements: []Statement{&ThrowStatement{Value: one()}}},
+				Catch: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+			},
+			&TryStatement{
+				Block: &BlockStatement{Statements: []Statement{&ThrowStatement{Value: two()}}},
+				Catch: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		modified := Modify(testCase.input, turnOneIntoTwo)
+		if !reflect.DeepEqual(modified, testCase.expected) {
+			t.Errorf("not equal, got %#v, expected %#v", modified, testCase.expected)
+		}
+	}
+}
+
+func TestCopy(t *testing.T) {
+	one := func() Expression { return &IntegerLiteral{Token: token.Token{Literal: "1"}, Value: 1} }
+	block := func() *BlockStatement {
+		return &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}}
+	}
+
+	// replaces rather than changes, the way quote and macros rewrite
+	replaceOne := func(node Node) Node {
+		if integer, ok := node.(*IntegerLiteral); ok && integer.Value == 1 {
+			return &IntegerLiteral{Token: token.Token{Literal: "2"}, Value: 2}
+		}
+		return node
+	}
+
+	inputs := []Node{
+		&Program{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+		&InfixExpression{Left: one(), Operator: "+", Right: one()},
+		&IfExpression{Condition: one(), Consequence: block(), Alternative: block()},
+		&FunctionLiteral{Body: block()},
+		&CallExpression{Function: &Identifier{Value: "f"}, Arguments: []Expression{one(), one()}},
+		&ArrayLiteral{Elements: []Expression{one()}},
+		&HashLiteral{Keys: []Expression{one()}, Values: []Expression{one()}},
+		&TryStatement{Block: block(), Catch: block(), Finally: block()},
+		&MatchExpression{Subject: one(), Arms: []*MatchArm{{Body: block()}}},
+		&ForStatement{Index: &Identifier{Value: "x"}, Iterable: one(), Body: block()},
+	}
+
+	for _, input := range inputs {
+		before := input.String()
+		copied := Copy(input)
+		Modify(copied, replaceOne)
+
+		if input.String() != before {
+			t.Errorf("modifying a copy changed the original, %q became %q", before, input.String())
+		}
+		if copied.String() == before {
+			t.Errorf("the copy of %q was not modified", before)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package ast
+
+// Copy returns a copy of the tree below node that Modify can rewrite without
+// changing the original. Identifiers, literals and the other nodes Modify
+// only ever replaces, never changes, are shared with the original.
+func Copy(node Node) Node {
+	switch node := node.(type) {
+	case *Program:
+		copied := *node
+		copied.Statements = copyStatements(node.Statements)
+		return &copied
+	case *BlockStatement:
+		return copyBlock(node)
+	case *ExpressionStatement:
+		copied := *node
+		copied.Expression = copyExpression(node.Expression)
+		return &copied
+	case *LetStatement:
+		copied := *node
+		copied.Value = copyExpression(node.Value)
+		return &copied
+	case *ReturnStatement:
+		copied := *node
+		copied.ReturnValue = copyExpression(node.ReturnValue)
+		return &copied
+	case *YieldStatement:
+		copied := *node
+		copied.Value = copyExpression(node.Value)
+		return &copied
+	case *ThrowStatement:
+		copied := *node
+		copied.Value = copyExpression(node.Value)
+		return &copied
+	case *ForStatement:
+		copied := *node
+		copied.Range = copyCall(node.Range)
+		copied.Iterable = copyExpression(node.Iterable)
+		copied.Body = copyBlock(node.Body)
+		return &copied
+	case *TryStatement:
+		copied := *node
+		copied.Block = copyBlock(node.Block)
+		copied.Catch = copyBlock(node.Catch)
+		copied.Finally = copyBlock(node.Finally)
+		return &copied
+	case *StructStatement:
+		copied := *node
+		copied.Methods = make([]*FunctionLiteral, len(node.Methods))
+		for index, method := range node.Methods {
+			copied.Methods[index] = Copy(method).(*FunctionLiteral)
+		}
+		return &copied
+	case *ExportStatement:
+		copied := *node
+		copied.Statement = Copy(node.Statement).(Statement)
+		return &copied
+	case *PrefixExpression:
+		copied := *node
+		copied.Right = copyExpression(node.Right)
+		return &copied
+	case *InfixExpression:
+		copied := *node
+		copied.Left = copyExpression(node.Left)
+		copied.Right = copyExpression(node.Right)
+		return &copied
+	case *IfExpression:
+		copied := *node
+		copied.Condition = copyExpression(node.Condition)
+		copied.Consequence = copyBlock(node.Consequence)
+		copied.Alternative = copyBlock(node.Alternative)
+		return &copied
+	case *MatchExpression:
+		copied := *node
+		copied.Subject = copyExpression(node.Subject)
+		copied.Arms = make([]*MatchArm, len(node.Arms))
+		for index, arm := range node.Arms {
+			copiedArm := *arm
+			copiedArm.Body = copyBlock(arm.Body)
+			copied.Arms[index] = &copiedArm
+		}
+		return &copied
+	case *FunctionLiteral:
+		copied := *node
+		copied.Body = copyBlock(node.Body)
+		return &copied
+	case *CallExpression:
+		return copyCall(node)
+	case *SpawnExpression:
+		copied := *node
+		copied.Call = copyCall(node.Call)
+		return &copied
+	case *AwaitExpression:
+		copied := *node
+		copied.Value = copyExpression(node.Value)
+		return &copied
+	case *ArrayLiteral:
+		copied := *node
+		copied.Elements = copyExpressions(node.Elements)
+		return &copied
+	case *HashLiteral:
+		copied := *node
+		copied.Keys = copyExpressions(node.Keys)
+		copied.Values = copyExpressions(node.Values)
+		return &copied
+	case *IndexExpression:
+		copied := *node
+		copied.Left = copyExpression(node.Left)
+		copied.Index = copyExpression(node.Index)
+		return &copied
+	case *DotExpression:
+		copied := *node
+		copied.Left = copyExpression(node.Left)
+		return &copied
+	case *AssignExpression:
+		copied := *node
+		copied.Target = copyExpression(node.Target)
+		copied.Value = copyExpression(node.Value)
+		return &copied
+	}
+
+	return node
+}
+
+func copyStatements(statements []Statement) []Statement {
+	if statements == nil {
+		return nil
+	}
+	copied := make([]Statement, len(statements))
+	for index, statement := range statements {
+		copied[index] = Copy(statement).(Statement)
+	}
+	return copied
+}
+
+func copyExpressions(expressions []Expression) []Expression {
+	if expressions == nil {
+		return nil
+	}
+	copied := make([]Expression, len(expressions))
+	for index, expression := range expressions {
+		copied[index] = copyExpression(expression)
+	}
+	return copied
+}
+
+func copyExpression(expression Expression) Expression {
+	if expression == nil {
+		return nil
+	}
+	return Copy(expression).(Expression)
+}
+
+func copyBlock(block *BlockStatement) *BlockStatement {
+	if block == nil {
+		return nil
+	}
+	copied := *block
+	copied.Statements = copyStatements(block.Statements)
+	return &copied
+}
+
+func copyCall(call *CallExpression) *CallExpression {
+	if call == nil {
+		return nil
+	}
+	copied := *call
+	copied.Function = copyExpression(call.Function)
+	copied.Arguments = copyExpressions(call.Arguments)
+	return &copied
+}
@@ -0,0 +1,135 @@
+package ast
+
+// ModifierFunc returns the node to put in place of the one it is given.
+type ModifierFunc func(Node) Node
+
+// Modify walks the tree below node, children first, and replaces every node
+// with what modifier returns for it. Fields that only hold a particular kind
+// of node, such as blocks and function literals, keep their old node when the
+// modifier returns something of another kind.
+func Modify(node Node, modifier ModifierFunc) Node {
+	switch node := node.(type) {
+	case *Program:
+		node.Statements = modifyStatements(node.Statements, modifier)
+	case *BlockStatement:
+		node.Statements = modifyStatements(node.Statements, modifier)
+	case *ExpressionStatement:
+		node.Expression = modifyExpression(node.Expression, modifier)
+	case *LetStatement:
+		node.Value = modifyExpression(node.Value, modifier)
+	case *ReturnStatement:
+		node.ReturnValue = modifyExpression(node.ReturnValue, modifier)
+	case *YieldStatement:
+		node.Value = modifyExpression(node.Value, modifier)
+	case *ThrowStatement:
+		node.Value = modifyExpression(node.Value, modifier)
+	case *ForStatement:
+		node.Range = modifyCall(node.Range, modifier)
+		node.Iterable = modifyExpression(node.Iterable, modifier)
+		node.Body = modifyBlock(node.Body, modifier)
+	case *TryStatement:
+		node.Block = modifyBlock(node.Block, modifier)
+		node.Catch = modifyBlock(node.Catch, modifier)
+		node.Finally = modifyBlock(node.Finally, modifier)
+	case *StructStatement:
+		for index, method := range node.Methods {
+			if modified, ok := Modify(method, modifier).(*FunctionLiteral); ok {
+				node.Methods[index] = modified
+			}
+		}
+	case *ExportStatement:
+		// only declarations can be exported
+		switch modified := Modify(node.Statement, modifier).(type) {
+		case *LetStatement:
+			node.Statement = modified
+		case *StructStatement:
+			node.Statement = modified
+		case *EnumStatement:
+			node.Statement = modified
+		}
+	case *PrefixExpression:
+		node.Right = modifyExpression(node.Right, modifier)
+	case *InfixExpression:
+		node.Left = modifyExpression(node.Left, modifier)
+		node.Right = modifyExpression(node.Right, modifier)
+	case *IfExpression:
+		node.Condition = modifyExpression(node.Condition, modifier)
+		node.Consequence = modifyBlock(node.Consequence, modifier)
+		node.Alternative = modifyBlock(node.Alternative, modifier)
+	case *MatchExpression:
+		node.Subject = modifyExpression(node.Subject, modifier)
+		for _, arm := range node.Arms {
+			arm.Body = modifyBlock(arm.Body, modifier)
+		}
+	case *FunctionLiteral:
+		node.Body = modifyBlock(node.Body, modifier)
+	case *CallExpression:
+		node.Function = modifyExpression(node.Function, modifier)
+		node.Arguments = modifyExpressions(node.Arguments, modifier)
+	case *SpawnExpression:
+		node.Call = modifyCall(node.Call, modifier)
+	case *AwaitExpression:
+		node.Value = modifyExpression(node.Value, modifier)
+	case *ArrayLiteral:
+		node.Elements = modifyExpressions(node.Elements, modifier)
+	case *HashLiteral:
+		node.Keys = modifyExpressions(node.Keys, modifier)
+		node.Values = modifyExpressions(node.Values, modifier)
+	case *IndexExpression:
+		node.Left = modifyExpression(node.Left, modifier)
+		node.Index = modifyExpression(node.Index, modifier)
+	case *DotExpression:
+		node.Left = modifyExpression(node.Left, modifier)
+	case *AssignExpression:
+		node.Target = modifyExpression(node.Target, modifier)
+		node.Value = modifyExpression(node.Value, modifier)
+	}
+
+	return modifier(node)
+}
+
+func modifyStatements(statements []Statement, modifier ModifierFunc) []Statement {
+	for index, statement := range statements {
+		if modified, ok := Modify(statement, modifier).(Statement); ok {
+			statements[index] = modified
+		}
+	}
+	return statements
+}
+
+func modifyExpressions(expressions []Expression, modifier ModifierFunc) []Expression {
+	for index, expression := range expressions {
+		expressions[index] = modifyExpression(expression, modifier)
+	}
+	return expressions
+}
+
+func modifyExpression(expression Expression, modifier ModifierFunc) Expression {
+	if expression == nil {
+		return nil
+	}
+	if modified, ok := Modify(expression, modifier).(Expression); ok {
+		return modified
+	}
+	return expression
+}
+
+func modifyBlock(block *BlockStatement, modifier ModifierFunc) *BlockStatement {
+	if block == nil {
+		return nil
+	}
+	if modified, ok := Modify(block, modifier).(*BlockStatement); ok {
+		return modified
+	}
+	return block
+}
+
+func modifyCall(call *CallExpression, modifier ModifierFunc) *CallExpression {
+	if call == nil {
+		return nil
+	}
+	if modified, ok := Modify(call, modifier).(*CallExpression); ok {
+		return modified
+	}
+	return call
+}
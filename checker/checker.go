@@ -0,0 +1,495 @@
+// Package checker looks for type errors in a program before it runs. Types
+// come from annotations where the program has them and are inferred locally
+// from literals, operators and calls everywhere else. Anything whose type
+// cannot be worked out is treated as `any` and never reported, so programs
+// without annotations only get errors for mistakes that would certainly fail.
+package checker
+
+import (
+	"fmt"
+	"github.com/noculture/plug/ast"
+	"github.com/noculture/plug/token"
+)
+
+// Type is what the checker knows about a value. Element is set for arrays
+// whose element type is known, Parameters for functions whose signature is.
+type Type struct {
+	Name       string
+	Element    *Type
+	Parameters []*Type
+	Return     *Type
+}
+
+var (
+	Any      = &Type{Name: "any"}
+	Int      = &Type{Name: "int"}
+	Decimal  = &Type{Name: "decimal"}
+	Rational = &Type{Name: "rational"}
+	String   = &Type{Name: "string"}
+	Bool     = &Type{Name: "bool"}
+	Array    = &Type{Name: "array"}
+	Func     = &Type{Name: "func"}
+	Hash     = &Type{Name: "hash"}
+)
+
+// the types annotations may name, besides declared structs and enums
+var builtinTypes = map[string]*Type{
+	"any": Any, "int": Int, "decimal": Decimal, "rational": Rational,
+	"string": String, "bool": Bool, "array": Array, "func": Func, "hash": Hash,
+}
+
+// the result types of builtins that always return the same type
+var builtinResults = map[string]*Type{
+	"len": Int, "str": String, "type": String, "is_builtin": Bool,
+	"decimal": Decimal, "rational": Rational, "round": Decimal,
+	"any": Bool, "every": Bool, "sort": Array, "zip": Array, "flatten": Array, "group_by": Hash,
+	"format": String, "read_lines": Array,
+}
+
+func (t *Type) String() string {
+	if t.Element != nil {
+		return "[" + t.Element.String() + "]"
+	}
+	return t.Name
+}
+
+func (t *Type) isNumber() bool {
+	return t == Int || t == Decimal || t == Rational
+}
+
+// isPrimitive reports whether the type's operators are the language's own,
+// instances of structs may define their own.
+func (t *Type) isPrimitive() bool {
+	_, ok := builtinTypes[t.Name]
+	return ok && t.Name != "any"
+}
+
+// assignable reports whether a value of type from can be used where to is expected.
+func assignable(from, to *Type) bool {
+	if from == Any || to == Any {
+		return true
+	}
+	if from.Name != to.Name {
+		return false
+	}
+	if from.Element != nil && to.Element != nil {
+		return assignable(from.Element, to.Element)
+	}
+	return true
+}
+
+type binding struct {
+	valueType *Type
+	declared  bool // annotated, so later assignments must keep to the type
+}
+
+type scope struct {
+	bindings map[string]binding
+	outer    *scope
+}
+
+func newScope(outer *scope) *scope {
+	return &scope{bindings: map[string]binding{}, outer: outer}
+}
+
+func (s *scope) lookup(name string) (binding, bool) {
+	for current := s; current != nil; current = current.outer {
+		if found, ok := current.bindings[name]; ok {
+			return found, true
+		}
+	}
+	return binding{}, false
+}
+
+// assign records a new value for a name. Names that were not annotated can
+// hold anything, so once they are given a value of another type nothing is
+// known about them any more.
+func (s *scope) assign(name string, valueType *Type) {
+	for current := s; current != nil; current = current.outer {
+		if found, ok := current.bindings[name]; ok {
+			if !found.declared && found.valueType != valueType {
+				current.bindings[name] = binding{valueType: Any}
+			}
+			return
+		}
+	}
+}
+
+type checker struct {
+	errors []string
+	named  map[string]bool // structs and enums, which annotations may name
+	// the annotated return types of the functions being checked, innermost
+	// last, nil for functions without one
+	returns []*Type
+}
+
+// Check returns the type errors found in the program, each starting with
+// the line and column it was found at.
+func Check(program *ast.Program) []string {
+	c := &checker{named: map[string]bool{}}
+
+	// declarations can be used in annotations before they appear
+	for _, statement := range program.Statements {
+		if export, ok := statement.(*ast.ExportStatement); ok {
+			statement = export.Statement
+		}
+		switch statement := statement.(type) {
+		case *ast.StructStatement:
+			c.named[statement.Name.Value] = true
+		case *ast.EnumStatement:
+			c.named[statement.Name.Value] = true
+		}
+	}
+
+	c.statements(program.Statements, newScope(nil))
+	return c.errors
+}
+
+func (c *checker) errorf(position token.Token, format string, a ...interface{}) {
+	message := fmt.Sprintf("%d:%d: ", position.Line, position.Column) + fmt.Sprintf(format, a...)
+	c.errors = append(c.errors, message)
+}
+
+func (c *checker) annotation(annotation *ast.TypeAnnotation) *Type {
+	switch {
+	case annotation == nil:
+		return Any
+	case annotation.Element != nil:
+		return &Type{Name: "array", Element: c.annotation(annotation.Element)}
+	case builtinTypes[annotation.Name] != nil:
+		return builtinTypes[annotation.Name]
+	case c.named[annotation.Name]:
+		return &Type{Name: annotation.Name}
+	default:
+		c.errorf(annotation.Token, "unknown type %s", annotation.Name)
+		return Any
+	}
+}
+
+// statements checks a list of statements and returns the type of the last
+// one when it is an expression, which is the value the list evaluates to.
+func (c *checker) statements(statements []ast.Statement, s *scope) *Type {
+	result := Any
+	for _, statement := range statements {
+		result = c.statement(statement, s)
+	}
+	return result
+}
+
+func (c *checker) block(block *ast.BlockStatement, s *scope) *Type {
+	if block == nil {
+		return Any
+	}
+	return c.statements(block.Statements, s)
+}
+
+func (c *checker) statement(statement ast.Statement, s *scope) *Type {
+	switch statement := statement.(type) {
+	case *ast.ExpressionStatement:
+		return c.expression(statement.Expression, s)
+	case *ast.LetStatement:
+		c.let(statement, s)
+	case *ast.ReturnStatement:
+		valueType := c.expression(statement.ReturnValue, s)
+		if len(c.returns) == 0 {
+			break
+		}
+		if expected := c.returns[len(c.returns)-1]; expected != nil && !assignable(valueType, expected) {
+			c.errorf(statement.Token, "cannot return %s from a function returning %s", valueType, expected)
+		}
+	case *ast.YieldStatement:
+		c.expression(statement.Value, s)
+	case *ast.ThrowStatement:
+		c.expression(statement.Value, s)
+	case *ast.TryStatement:
+		c.block(statement.Block, s)
+		if statement.Parameter != nil {
+			s.bindings[statement.Parameter.Value] = binding{valueType: Any}
+		}
+		c.block(statement.Catch, s)
+		c.block(statement.Finally, s)
+	case *ast.ForStatement:
+		elementType := Int
+		if statement.Iterable != nil {
+			elementType = Any
+			if iterable := c.expression(statement.Iterable, s); iterable.Element != nil {
+				elementType = iterable.Element
+			}
+		} else {
+			c.expression(statement.Range, s)
+		}
+		s.bindings[statement.Index.Value] = binding{valueType: elementType}
+		c.block(statement.Body, s)
+	case *ast.StructStatement:
+		instance := &Type{Name: statement.Name.Value}
+		s.bindings[statement.Name.Value] = binding{valueType: &Type{Name: "func", Return: instance}}
+		for _, method := range statement.Methods {
+			methodScope := newScope(s)
+			methodScope.bindings["self"] = binding{valueType: instance}
+			c.function(method, methodScope)
+		}
+	case *ast.EnumStatement:
+		s.bindings[statement.Name.Value] = binding{valueType: Any}
+	case *ast.ImportStatement:
+		// modules are checked on their own
+		s.bindings[statement.Name.Value] = binding{valueType: Any}
+	case *ast.ExportStatement:
+		c.statement(statement.Statement, s)
+	}
+	return Any
+}
+
+func (c *checker) let(statement *ast.LetStatement, s *scope) {
+	declared := statement.Type != nil
+	declaredType := c.annotation(statement.Type)
+
+	// a function can call itself, so its name is bound before its body is checked
+	if literal, ok := statement.Value.(*ast.FunctionLiteral); ok && !declared {
+		s.bindings[statement.Name.Value] = binding{valueType: c.signature(literal)}
+	}
+
+	valueType := c.expression(statement.Value, s)
+	if declared && !assignable(valueType, declaredType) {
+		c.errorf(statement.Name.Token, "cannot assign %s to %s of type %s", valueType, statement.Name.Value, declaredType)
+	}
+	if declared {
+		valueType = declaredType
+	}
+	s.bindings[statement.Name.Value] = binding{valueType: valueType, declared: declared}
+}
+
+// signature is the type of a function as far as its annotations tell.
+func (c *checker) signature(literal *ast.FunctionLiteral) *Type {
+	signature := &Type{Name: "func", Parameters: []*Type{}, Return: Any}
+	for index := range literal.Parameters {
+		var annotation *ast.TypeAnnotation
+		if index < len(literal.ParameterTypes) {
+			annotation = literal.ParameterTypes[index]
+		}
+		signature.Parameters = append(signature.Parameters, c.annotation(annotation))
+	}
+	// generators and async functions return iterators and promises, whatever
+	// their bodies return
+	if !literal.Generator && !literal.Async {
+		signature.Return = c.annotation(literal.ReturnType)
+	}
+	return signature
+}
+
+func (c *checker) function(literal *ast.FunctionLiteral, outer *scope) *Type {
+	s := newScope(outer)
+	for index, parameter := range literal.Parameters {
+		var annotation *ast.TypeAnnotation
+		if index < len(literal.ParameterTypes) {
+			annotation = literal.ParameterTypes[index]
+		}
+		s.bindings[parameter.Value] = binding{valueType: c.annotation(annotation), declared: annotation != nil}
+	}
+
+	var expected *Type
+	if literal.ReturnType != nil {
+		expected = c.annotation(literal.ReturnType)
+	}
+	c.returns = append(c.returns, expected)
+	result := c.block(literal.Body, s)
+	c.returns = c.returns[:len(c.returns)-1]
+
+	// the last expression of the body is returned too
+	statements := literal.Body.Statements
+	if expected != nil && len(statements) > 0 && !assignable(result, expected) {
+		if last, ok := statements[len(statements)-1].(*ast.ExpressionStatement); ok {
+			c.errorf(last.Token, "cannot return %s from a function returning %s", result, expected)
+		}
+	}
+
+	return c.signature(literal)
+}
+
+func (c *checker) expression(expression ast.Expression, s *scope) *Type {
+	switch expression := expression.(type) {
+	case *ast.IntegerLiteral:
+		return Int
+	case *ast.DecimalLiteral:
+		return Decimal
+	case *ast.StringLiteral:
+		return String
+	case *ast.Boolean:
+		return Bool
+	case *ast.Identifier:
+		if found, ok := s.lookup(expression.Value); ok {
+			return found.valueType
+		}
+		return Any
+	case *ast.PrefixExpression:
+		return c.prefix(expression, s)
+	case *ast.InfixExpression:
+		return c.infix(expression, s)
+	case *ast.IfExpression:
+		c.expression(expression.Condition, s)
+		consequence := c.block(expression.Consequence, s)
+		alternative := c.block(expression.Alternative, s)
+		if expression.Alternative != nil && consequence == alternative {
+			return consequence
+		}
+		return Any
+	case *ast.FunctionLiteral:
+		return c.function(expression, s)
+	case *ast.CallExpression:
+		return c.call(expression, s)
+	case *ast.ArrayLiteral:
+		var element *Type
+		for index, item := range expression.Elements {
+			itemType := c.expression(item, s)
+			if index == 0 {
+				element = itemType
+			} else if element != itemType {
+				element = Any
+			}
+		}
+		if element == nil || element == Any {
+			return Array
+		}
+		return &Type{Name: "array", Element: element}
+	case *ast.HashLiteral:
+		for index, key := range expression.Keys {
+			c.expression(key, s)
+			c.expression(expression.Values[index], s)
+		}
+		return Hash
+	case *ast.IndexExpression:
+		left := c.expression(expression.Left, s)
+		c.expression(expression.Index, s)
+		if left.Element != nil {
+			return left.Element
+		}
+		return Any
+	case *ast.DotExpression:
+		c.expression(expression.Left, s)
+		return Any
+	case *ast.AssignExpression:
+		valueType := c.expression(expression.Value, s)
+		if target, ok := expression.Target.(*ast.Identifier); ok {
+			if found, ok := s.lookup(target.Value); ok && found.declared && !assignable(valueType, found.valueType) {
+				c.errorf(expression.Token, "cannot assign %s to %s of type %s", valueType, target.Value, found.valueType)
+			}
+			s.assign(target.Value, valueType)
+		} else {
+			c.expression(expression.Target, s)
+		}
+		return valueType
+	case *ast.MatchExpression:
+		c.expression(expression.Subject, s)
+		for _, arm := range expression.Arms {
+			for _, name := range arm.Bindings {
+				s.bindings[name.Value] = binding{valueType: Any}
+			}
+			c.block(arm.Body, s)
+		}
+		return Any
+	case *ast.SpawnExpression:
+		c.call(expression.Call, s)
+		return Any
+	case *ast.AwaitExpression:
+		c.expression(expression.Value, s)
+		return Any
+	}
+	return Any
+}
+
+func (c *checker) prefix(expression *ast.PrefixExpression, s *scope) *Type {
+	operand := c.expression(expression.Right, s)
+	if expression.Operator == "!" {
+		return Bool
+	}
+	if operand.isNumber() || !operand.isPrimitive() {
+		return operand
+	}
+	c.errorf(expression.Token, "unknown operator: %s%s", expression.Operator, operand)
+	return Any
+}
+
+// infix follows the evaluator's rules for the language's own types. Numbers
+// of different types mix, every other pair of different types is a mismatch.
+func (c *checker) infix(expression *ast.InfixExpression, s *scope) *Type {
+	left := c.expression(expression.Left, s)
+	right := c.expression(expression.Right, s)
+	operator := expression.Operator
+
+	if operator == "==" || operator == "!=" {
+		return Bool
+	}
+	if !left.isPrimitive() || !right.isPrimitive() {
+		if operator == "<" || operator == ">" {
+			return Bool
+		}
+		return Any
+	}
+
+	switch {
+	case left.isNumber() && right.isNumber():
+		if operator == "<" || operator == ">" {
+			return Bool
+		}
+		return numberResult(left, right)
+	case left.Name != right.Name:
+		c.errorf(expression.Token, "type mismatch: %s %s %s", left, operator, right)
+	case left == String && operator == "+":
+		return String
+	case left == String && (operator == "<" || operator == ">"):
+		return Bool
+	default:
+		c.errorf(expression.Token, "unknown operator: %s %s %s", left, operator, right)
+	}
+	return Any
+}
+
+// numberResult is the type arithmetic on two numbers gives: integers mixed
+// with anything take the other type, and rationals win over decimals.
+func numberResult(left, right *Type) *Type {
+	switch {
+	case left == Rational || right == Rational:
+		return Rational
+	case left == Decimal || right == Decimal:
+		return Decimal
+	default:
+		return Int
+	}
+}
+
+func (c *checker) call(call *ast.CallExpression, s *scope) *Type {
+	callee := c.expression(call.Function, s)
+
+	arguments := make([]*Type, len(call.Arguments))
+	for index, argument := range call.Arguments {
+		arguments[index] = c.expression(argument, s)
+	}
+
+	if identifier, ok := call.Function.(*ast.Identifier); ok {
+		if _, bound := s.lookup(identifier.Value); !bound && builtinResults[identifier.Value] != nil {
+			return builtinResults[identifier.Value]
+		}
+	}
+
+	if callee.isPrimitive() && callee.Name != "func" {
+		c.errorf(call.Token, "not a function: %s", callee)
+		return Any
+	}
+	if callee.Parameters != nil {
+		if len(arguments) != len(callee.Parameters) {
+			c.errorf(call.Token, "wrong number of arguments to %s, expected %d, got %d",
+				call.Function.String(), len(callee.Parameters), len(arguments))
+		} else {
+			for index, argument := range arguments {
+				if !assignable(argument, callee.Parameters[index]) {
+					c.errorf(call.Token, "argument %d to %s must be %s, got %s",
+						index+1, call.Function.String(), callee.Parameters[index], argument)
+				}
+			}
+		}
+	}
+
+	if callee.Return != nil {
+		return callee.Return
+	}
+	return Any
+}
@@ -0,0 +1,78 @@
+package checker
+
+import (
+	"github.com/noculture/plug/lexer"
+	"github.com/noculture/plug/parser"
+	"testing"
+)
+
+func TestCheckReportsMismatches(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected []string
+	}{
+		{`1 + "a"`, []string{`1:3: type mismatch: int + string`}},
+		{`"a" - "b"`, []string{`1:5: unknown operator: string - string`}},
+		{`-true`, []string{`1:1: unknown operator: -bool`}},
+		{`let x: int = "a";`, []string{`1:5: cannot assign string to x of type int`}},
+		{`let x: string = "a"; x = 1`, []string{`1:24: cannot assign int to x of type string`}},
+		{`let f = func(x: int): string { x }`, []string{`1:32: cannot return int from a function returning string`}},
+		{`let f = func(): int { return "no" }`, []string{`1:23: cannot return string from a function returning int`}},
+		{`let f = func(x: int) { x }; f("a")`, []string{`1:30: argument 1 to f must be int, got string`}},
+		{`let f = func(x, y) { x }; f(1)`, []string{`1:28: wrong number of arguments to f, expected 2, got 1`}},
+		{`let xs: [int] = ["a", "b"]`, []string{`1:5: cannot assign [string] to xs of type [int]`}},
+		{`let n = len("abc"); n + "a"`, []string{`1:23: type mismatch: int + string`}},
+		{`let p: Point = 1`, []string{`1:8: unknown type Point`}},
+		{`let h: hash = [1]`, []string{`1:5: cannot assign [int] to h of type hash`}},
+		{`let n: int = sort([2, 1])`, []string{`1:5: cannot assign array to n of type int`}},
+		{`let n: int = every([1], func(x) { true })`, []string{`1:5: cannot assign bool to n of type int`}},
+		{`let s = "a"; let f = func() { s * 2 }`, []string{`1:33: type mismatch: string * int`}},
+		{"let x = 5;\nlet y = x(1)", []string{`2:10: not a function: int`}},
+	}
+
+	for _, testCase := range testCases {
+		errors := check(t, testCase.input)
+		if len(errors) != len(testCase.expected) {
+			t.Errorf("expected %v for %q, got %v", testCase.expected, testCase.input, errors)
+			continue
+		}
+		for index, message := range testCase.expected {
+			if errors[index] != message {
+				t.Errorf("wrong error for %q, expected %q, got %q", testCase.input, message, errors[index])
+			}
+		}
+	}
+}
+
+func TestCheckAcceptsValidPrograms(t *testing.T) {
+	inputs := []string{
+		`let add = func(x: int, y: int): int { x + y }; add(1, 2) * 3`,
+		`let price: decimal = 19.99 * 3; price + 1`,
+		`rational(1, 3) + 0.5`,
+		`let greet = func(name: string): string { "hello " + name }; greet(str(1))`,
+		`let fact = func(n: int): int { if (n == 0) { return 1 }; n * fact(n - 1) }`,
+		`let x = 1; x = "now a string"; x + "!"`,
+		`let unknown = func(v) { v + 1 }; unknown("a")`,
+		"struct Money { cents\n func __add__(other) { Money(self.cents + other.cents) }\n}\nlet m: Money = Money(1); m + m",
+		`let xs: [int] = [1, 2, 3]; for x in xs { x * 2 }`,
+		`let count = async func(): int { 1 }; await count()`,
+		`let f: func = func() { 1 }; f()`,
+		`let h: hash = {"a": 1, "b": [2]}; h["a"] + 1`,
+		`"a" < "b"`,
+	}
+
+	for _, input := range inputs {
+		if errors := check(t, input); len(errors) != 0 {
+			t.Errorf("expected no errors for %q, got %v", input, errors)
+		}
+	}
+}
+
+func check(t *testing.T, input string) []string {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+	return Check(program)
+}
@@ -0,0 +1,182 @@
+package evaluator
+
+import (
+	"github.com/noculture/plug/ast"
+	"github.com/noculture/plug/object"
+	"github.com/noculture/plug/token"
+	"time"
+)
+
+// startAsync runs the body of an async function until its first await and
+// returns a promise of its result. Like a generator the body has its own
+// goroutine, but it only ever runs while the event loop or the caller waits
+// for it, so async code never runs in parallel with anything else. A call to
+// exit in the body stops the event loop, or before the first await simply
+// unwinds the caller.
+func startAsync(function *object.Function, args []object.Object, call token.Token, depth int) object.Object {
+	loop := function.Env.Runtime().EventLoop()
+	promise := loop.NewPromise()
+	suspended := make(chan struct{})
+	resume := make(chan object.Object)
+
+	go func() {
+		env := createFunctionScope(function, args, depth)
+		env.SetAwait(func(awaited *object.Promise) object.Object {
+			awaited.Then(func(result object.Object) {
+				loop.Schedule(func() {
+					resume <- result
+					<-suspended
+				})
+			})
+			suspended <- struct{}{}
+			return <-resume
+		})
+
+		result := unwrapReturnValue(Eval(function.Body, env))
+		if err, ok := result.(*object.Error); ok {
+			frame := object.Frame{Function: function.Name, Line: call.Line, Column: call.Column, Arguments: len(args)}
+			err.Stack = append(err.Stack, frame)
+			if err.Kind == object.EXIT {
+				loop.Stop(err)
+			}
+		}
+		promise.Settle(result)
+		suspended <- struct{}{}
+	}()
+
+	<-suspended
+	if exit := loop.Stopped(); exit != nil {
+		promise.Handle()
+		return exit
+	}
+	return promise
+}
+
+// evalAwaitExpression waits for a promise to settle and evaluates to its
+// result, a rejected promise evaluates to its error. Inside an async function
+// only that function waits, at the top of a program the event loop runs
+// until the promise settles. Anything other than a promise is its own result.
+func evalAwaitExpression(node *ast.AwaitExpression, env *object.Environment) object.Object {
+	value := Eval(node.Value, env)
+	if isError(value) {
+		return value
+	}
+
+	promise, ok := value.(*object.Promise)
+	if !ok {
+		return value
+	}
+
+	result, ok := env.Await(promise)
+	if !ok {
+		loop := env.Runtime().EventLoop()
+		if !loop.RunUntil(promise.Settled) {
+			if exit := loop.Stopped(); exit != nil {
+				return exit
+			}
+			return withPosition(newError(object.RUNTIME_ERROR, "await on a promise that can never settle"), node.Token)
+		}
+		promise.Handle()
+		result, _ = promise.Result()
+	}
+
+	if err, ok := result.(*object.Error); ok {
+		// the same rejection can be awaited more than once, each gets its own stack
+		rejection := *err
+		rejection.Stack = append([]object.Frame(nil), err.Stack...)
+		return &rejection
+	}
+	return result
+}
+
+// sleepBuiltin returns a promise that settles with null after the given
+// number of milliseconds on the event loop's clock.
+func sleepBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `sleep`, expected 1, got %d", len(args))
+	}
+
+	milliseconds, ok := args[0].(*object.Integer)
+	if !ok {
+		return newError(object.TYPE_ERROR, "argument to `sleep` must be INTEGER, got %s", args[0].Type())
+	}
+	if milliseconds.Value < 0 {
+		return newError(object.ARGUMENT_ERROR, "argument to `sleep` must not be negative, got %d", milliseconds.Value)
+	}
+
+	promise := object.NewPromise()
+	env.Runtime().EventLoop().After(time.Duration(milliseconds.Value)*time.Millisecond, func() {
+		promise.Settle(NULL)
+	})
+
+	return promise
+}
+
+// allBuiltin returns a promise of the results of every promise in an array,
+// in the same order. It is rejected as soon as any of them is. Given a
+// predicate as well, it reports whether the predicate holds for every element.
+func allBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) == 2 {
+		return allMatch(env, "all", args)
+	}
+	if len(args) != 1 {
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `all`, expected 1 or 2, got %d", len(args))
+	}
+	array, ok := args[0].(*object.Array)
+	if !ok {
+		return newError(object.TYPE_ERROR, "argument to `all` must be ARRAY, got %s", args[0].Type())
+	}
+
+	promise := env.Runtime().EventLoop().NewPromise()
+	results := make([]object.Object, len(array.Elements))
+	pending := len(array.Elements)
+
+	settleOne := func(index int, result object.Object) {
+		if isError(result) {
+			promise.Settle(result)
+			return
+		}
+		results[index] = result
+		pending--
+		if pending == 0 {
+			promise.Settle(&object.Array{Elements: results})
+		}
+	}
+
+	if pending == 0 {
+		promise.Settle(&object.Array{Elements: results})
+	}
+	for index, element := range array.Elements {
+		index := index
+		if awaited, ok := element.(*object.Promise); ok {
+			awaited.Then(func(result object.Object) { settleOne(index, result) })
+		} else {
+			settleOne(index, element)
+		}
+	}
+
+	return promise
+}
+
+// raceBuiltin returns a promise that settles the same way as the first
+// promise in an array to settle.
+func raceBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `race`, expected 1, got %d", len(args))
+	}
+	array, ok := args[0].(*object.Array)
+	if !ok || len(array.Elements) == 0 {
+		return newError(object.TYPE_ERROR, "argument to `race` must be a non-empty array")
+	}
+
+	promise := env.Runtime().EventLoop().NewPromise()
+	for _, element := range array.Elements {
+		if awaited, ok := element.(*object.Promise); ok {
+			awaited.Then(promise.Settle)
+		} else {
+			promise.Settle(element)
+		}
+	}
+
+	return promise
+}
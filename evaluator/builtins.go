@@ -1,103 +1,212 @@
 package evaluator
 
 import (
-	"fmt"
 	"github.com/noculture/plug/object"
+	"math"
 )
 
-var builtins = map[string]*object.Builtin{
-	"len": &object.Builtin{Function: func(args ...object.Object) object.Object {
-		if len(args) != 1 {
-			return newError("invalid number of arguments to `len`, expected 1, got %d", len(args))
-		}
-
-		switch argument := args[0].(type) {
-		case *object.Array:
-			return &object.Integer{Value: int64(len(argument.Elements))}
-		case *object.String:
-			return &object.Integer{Value: int64(len(argument.Value))}
-		default:
-			return newError("argument to `len` not supported, got %s", args[0].Type())
-		}
-	},
-	},
-	"first": &object.Builtin{Function: func(args ...object.Object) object.Object {
-		if len(args) != 1 {
-			return newError("invalid number of arguments, expected 1, got %d", len(args))
-		}
-
-		if args[0].Type() != object.ARRAY {
-			return newError("argument to `first` must be an array, got %s", args[0].Type())
-		}
-
-		arr := args[0].(*object.Array)
-		if len(arr.Elements) > 0 {
-			return arr.Elements[0]
-		}
-
-		return NULL
-	}},
-	"last": &object.Builtin{Function: func(args ...object.Object) object.Object {
-		if len(args) != 1 {
-			return newError("invalid number of arguments, expected 1, got %d", len(args))
-		}
-
-		if args[0].Type() != object.ARRAY {
-			return newError("argument to `last` must be an array, got %s", args[0].Type())
-		}
-
-		arr := args[0].(*object.Array)
-		length := len(arr.Elements)
-
-		if length > 0 {
-			return arr.Elements[length-1]
-		}
-
-		return NULL
-	}},
-	"rest": &object.Builtin{Function: func(args ...object.Object) object.Object {
-		if len(args) != 1 {
-			return newError("invalid number of arguments, expected 1, got %d", len(args))
-		}
-
-		if args[0].Type() != object.ARRAY {
-			return newError("argument to `rest` must be an array, got %s", args[0].Type())
-		}
-
-		arr := args[0].(*object.Array)
-		length := len(arr.Elements)
-
-		if length > 0 {
-			newElements := make([]object.Object, length-1, length-1)
-			copy(newElements, arr.Elements[1:length])
+// builtins is filled in by init because several builtins call back into the
+// evaluator, which itself looks builtins up
+var builtins map[string]*object.Builtin
+
+// nativeModules are the standard library modules written in Go, imported
+// like the ones written in Plug
+var nativeModules map[string]map[string]object.Object
+
+func init() {
+	builtins = map[string]*object.Builtin{
+		"len": &object.Builtin{Function: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `len`, expected 1, got %d", len(args))
+			}
+
+			return length(env, args[0])
+		},
+		},
+		"first": &object.Builtin{Function: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError(object.ARGUMENT_ERROR, "invalid number of arguments, expected 1, got %d", len(args))
+			}
+
+			if args[0].Type() != object.ARRAY {
+				return newError(object.TYPE_ERROR, "argument to `first` must be an array, got %s", args[0].Type())
+			}
+
+			arr := args[0].(*object.Array)
+			if len(arr.Elements) > 0 {
+				return arr.Elements[0]
+			}
+
+			return NULL
+		}},
+		"last": &object.Builtin{Function: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError(object.ARGUMENT_ERROR, "invalid number of arguments, expected 1, got %d", len(args))
+			}
+
+			if args[0].Type() != object.ARRAY {
+				return newError(object.TYPE_ERROR, "argument to `last` must be an array, got %s", args[0].Type())
+			}
+
+			arr := args[0].(*object.Array)
+			length := len(arr.Elements)
+
+			if length > 0 {
+				return arr.Elements[length-1]
+			}
+
+			return NULL
+		}},
+		"rest": &object.Builtin{Function: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError(object.ARGUMENT_ERROR, "invalid number of arguments, expected 1, got %d", len(args))
+			}
+
+			if args[0].Type() != object.ARRAY {
+				return newError(object.TYPE_ERROR, "argument to `rest` must be an array, got %s", args[0].Type())
+			}
+
+			arr := args[0].(*object.Array)
+			length := len(arr.Elements)
+
+			if length > 0 {
+				newElements := make([]object.Object, length-1, length-1)
+				copy(newElements, arr.Elements[1:length])
+				return &object.Array{Elements: newElements}
+			}
+
+			return NULL
+		}},
+		"push": &object.Builtin{Function: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError(object.ARGUMENT_ERROR, "invalid number of arguments, expected 2, got %d", len(args))
+			}
+
+			if args[0].Type() != object.ARRAY {
+				return newError(object.TYPE_ERROR, "first argument to `push` not supported, expected ARRAY, got %s", args[0].Type())
+			}
+
+			arr := args[0].(*object.Array)
+			length := len(arr.Elements)
+
+			newElements := make([]object.Object, length+1, length+1)
+			copy(newElements, arr.Elements)
+			newElements[length] = args[1]
+
 			return &object.Array{Elements: newElements}
-		}
-
-		return NULL
-	}},
-	"push": &object.Builtin{Function: func(args ...object.Object) object.Object {
-		if len(args) != 2 {
-			return newError("invalid number of arguments, expected 2, got %d", len(args))
-		}
-
-		if args[0].Type() != object.ARRAY {
-			return newError("first argument to `push` not supported, expected ARRAY, got %s", args[0].Type())
-		}
-
-		arr := args[0].(*object.Array)
-		length := len(arr.Elements)
-
-		newElements := make([]object.Object, length+1, length+1)
-		copy(newElements, arr.Elements)
-		newElements[length] = args[1]
-
-		return &object.Array{Elements: newElements}
-	}},
-	"print": &object.Builtin{Function: func(args ...object.Object) object.Object {
-		for _, arg := range args {
-			fmt.Println(arg.Inspect())
-		}
-		return NULL
-	},
-	},
+		}},
+		"print":  &object.Builtin{Function: printBuiltin},
+		"eprint": &object.Builtin{Function: eprintBuiltin},
+		"str": &object.Builtin{Function: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError(object.ARGUMENT_ERROR, "invalid number of arguments, expected 1, got %d", len(args))
+			}
+
+			return toString(env, args[0])
+		},
+		},
+		"range":       &object.Builtin{Function: rangeBuiltin},
+		"count":       &object.Builtin{Function: countBuiltin},
+		"map":         &object.Builtin{Function: mapBuiltin},
+		"filter":      &object.Builtin{Function: filterBuiltin},
+		"take":        &object.Builtin{Function: takeBuiltin},
+		"list":        &object.Builtin{Function: listBuiltin},
+		"next":        &object.Builtin{Function: nextBuiltin},
+		"wait":        &object.Builtin{Function: waitBuiltin},
+		"chan":        &object.Builtin{Function: chanBuiltin},
+		"send":        &object.Builtin{Function: sendBuiltin},
+		"recv":        &object.Builtin{Function: recvBuiltin},
+		"close":       &object.Builtin{Function: closeBuiltin},
+		"select":      &object.Builtin{Function: selectBuiltin},
+		"sleep":       &object.Builtin{Function: sleepBuiltin},
+		"all":         &object.Builtin{Function: allBuiltin},
+		"race":        &object.Builtin{Function: raceBuiltin},
+		"decimal":     &object.Builtin{Function: decimalBuiltin},
+		"rational":    &object.Builtin{Function: rationalBuiltin},
+		"round":       &object.Builtin{Function: roundBuiltin},
+		"numerator":   &object.Builtin{Function: numeratorBuiltin},
+		"denominator": &object.Builtin{Function: denominatorBuiltin},
+		"eval":        &object.Builtin{Function: evalBuiltin},
+		"scope":       &object.Builtin{Function: scopeBuiltin},
+		"new_scope":   &object.Builtin{Function: newScopeBuiltin},
+		"bindings":    &object.Builtin{Function: bindingsBuiltin},
+		"type":        &object.Builtin{Function: typeBuiltin},
+		"params":      &object.Builtin{Function: paramsBuiltin},
+		"is_builtin":  &object.Builtin{Function: isBuiltinBuiltin},
+		"reduce":      &object.Builtin{Function: reduceBuiltin},
+		"any":         &object.Builtin{Function: anyBuiltin},
+		"every":       &object.Builtin{Function: everyBuiltin},
+		"find":        &object.Builtin{Function: findBuiltin},
+		"sort":        &object.Builtin{Function: sortBuiltin},
+		"zip":         &object.Builtin{Function: zipBuiltin},
+		"flatten":     &object.Builtin{Function: flattenBuiltin},
+		"group_by":    &object.Builtin{Function: groupByBuiltin},
+		"format":      &object.Builtin{Function: formatBuiltin},
+		"input":       &object.Builtin{Function: inputBuiltin},
+		"read_lines":  &object.Builtin{Function: readLinesBuiltin},
+		"exit":        &object.Builtin{Function: exitBuiltin},
+	}
+
+	nativeModules = map[string]map[string]object.Object{
+		"strings": {
+			"split":       &object.Builtin{Function: splitBuiltin},
+			"join":        &object.Builtin{Function: joinBuiltin},
+			"trim":        &object.Builtin{Function: trimBuiltin},
+			"replace":     &object.Builtin{Function: replaceBuiltin},
+			"contains":    &object.Builtin{Function: containsBuiltin},
+			"starts_with": &object.Builtin{Function: startsWithBuiltin},
+			"ends_with":   &object.Builtin{Function: endsWithBuiltin},
+			"upper":       &object.Builtin{Function: upperBuiltin},
+			"lower":       &object.Builtin{Function: lowerBuiltin},
+			"repeat":      &object.Builtin{Function: repeatBuiltin},
+			"pad_left":    &object.Builtin{Function: padLeftBuiltin},
+			"pad_right":   &object.Builtin{Function: padRightBuiltin},
+			"index_of":    &object.Builtin{Function: indexOfBuiltin},
+		},
+		"math": {
+			"PI":    mathConstant("3.14159265358979323846"),
+			"E":     mathConstant("2.71828182845904523536"),
+			"abs":   &object.Builtin{Function: absBuiltin},
+			"min":   &object.Builtin{Function: minBuiltin},
+			"max":   &object.Builtin{Function: maxBuiltin},
+			"pow":   &object.Builtin{Function: powBuiltin},
+			"sqrt":  &object.Builtin{Function: sqrtBuiltin},
+			"floor": &object.Builtin{Function: floorBuiltin},
+			"ceil":  &object.Builtin{Function: ceilBuiltin},
+			"round": &object.Builtin{Function: mathRoundBuiltin},
+			"sin":   &object.Builtin{Function: floatFunction("sin", math.Sin, nil, "")},
+			"cos":   &object.Builtin{Function: floatFunction("cos", math.Cos, nil, "")},
+			"tan":   &object.Builtin{Function: floatFunction("tan", math.Tan, nil, "")},
+			"asin":  &object.Builtin{Function: floatFunction("asin", math.Asin, unitInterval, "numbers from -1 to 1")},
+			"acos":  &object.Builtin{Function: floatFunction("acos", math.Acos, unitInterval, "numbers from -1 to 1")},
+			"atan":  &object.Builtin{Function: floatFunction("atan", math.Atan, nil, "")},
+			"atan2": &object.Builtin{Function: atan2Builtin},
+			"exp":   &object.Builtin{Function: floatFunction("exp", math.Exp, nil, "")},
+			"log":   &object.Builtin{Function: logBuiltin},
+			"log2":  &object.Builtin{Function: floatFunction("log2", math.Log2, positive, "numbers > 0")},
+			"log10": &object.Builtin{Function: floatFunction("log10", math.Log10, positive, "numbers > 0")},
+			"gcd":   &object.Builtin{Function: gcdBuiltin},
+			"lcm":   &object.Builtin{Function: lcmBuiltin},
+		},
+		"random": {
+			"seed":    &object.Builtin{Function: seedBuiltin},
+			"int":     &object.Builtin{Function: randomIntBuiltin},
+			"choice":  &object.Builtin{Function: choiceBuiltin},
+			"shuffle": &object.Builtin{Function: shuffleBuiltin},
+			"sample":  &object.Builtin{Function: sampleBuiltin},
+		},
+		"json": {
+			"encode": &object.Builtin{Function: encodeBuiltin},
+			"decode": &object.Builtin{Function: decodeBuiltin},
+		},
+		"fs": {
+			"read":   &object.Builtin{Function: readBuiltin},
+			"write":  &object.Builtin{Function: writeBuiltin},
+			"append": &object.Builtin{Function: appendBuiltin},
+			"exists": &object.Builtin{Function: existsBuiltin},
+			"list":   &object.Builtin{Function: fsListBuiltin},
+			"mkdir":  &object.Builtin{Function: mkdirBuiltin},
+			"remove": &object.Builtin{Function: removeBuiltin},
+		},
+	}
 }
@@ -0,0 +1,233 @@
+package evaluator
+
+import (
+	"github.com/noculture/plug/object"
+	"github.com/noculture/plug/token"
+	"sort"
+)
+
+// Builtins that take a collection and a Plug function to call back with its
+// elements. An error from the function stops the builtin and is returned.
+
+// call applies a callback given to a builtin.
+func call(env *object.Environment, function object.Object, args ...object.Object) object.Object {
+	return applyFunction(function, args, token.Token{}, env)
+}
+
+func reduceBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 3 {
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `reduce`, expected 3, got %d", len(args))
+	}
+
+	result, function := args[1], args[2]
+	err := iterate(env, args[0], func(element object.Object) object.Object {
+		result = call(env, function, result, element)
+		if isError(result) {
+			return result
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return result
+}
+
+func anyBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `any`, expected 2, got %d", len(args))
+	}
+
+	found, err := findMatch(env, args[0], args[1], true)
+	if err != nil {
+		return err
+	}
+	return referenceBoolObject(found != nil)
+}
+
+// everyBuiltin reports whether the predicate holds for every element, like
+// `all` given a predicate.
+func everyBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	return allMatch(env, "every", args)
+}
+
+func allMatch(env *object.Environment, name string, args []object.Object) object.Object {
+	if len(args) != 2 {
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `%s`, expected 2, got %d", name, len(args))
+	}
+
+	found, err := findMatch(env, args[0], args[1], false)
+	if err != nil {
+		return err
+	}
+	return referenceBoolObject(found == nil)
+}
+
+func findBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `find`, expected 2, got %d", len(args))
+	}
+
+	found, err := findMatch(env, args[0], args[1], true)
+	if err != nil {
+		return err
+	}
+	if found == nil {
+		return NULL
+	}
+	return found
+}
+
+// findMatch returns the first element the predicate's truthiness equals
+// want for, or nil when there is none.
+func findMatch(env *object.Environment, iterable, predicate object.Object, want bool) (object.Object, object.Object) {
+	var found object.Object
+	err := iterate(env, iterable, func(element object.Object) object.Object {
+		result := call(env, predicate, element)
+		if isError(result) {
+			return result
+		}
+		if isTruthy(result) == want {
+			found = element
+			return element
+		}
+		return nil
+	})
+	if isError(err) {
+		return nil, err
+	}
+	return found, nil
+}
+
+// sortBuiltin returns the elements in ascending order, or ordered by a
+// comparator that reports whether its first argument goes before its second.
+// The sort is stable.
+func sortBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `sort`, expected 1 or 2, got %d", len(args))
+	}
+
+	collected := collect(env, args[0])
+	if isError(collected) {
+		return collected
+	}
+	elements := append([]object.Object{}, collected.(*object.Array).Elements...)
+
+	less := func(left, right object.Object) object.Object {
+		if result, ok := evalOverloadedInfix("<", left, right, token.Token{}, env); ok {
+			return result
+		}
+		return evalInfixExpression("<", left, right, env)
+	}
+	if len(args) == 2 {
+		less = func(left, right object.Object) object.Object {
+			return call(env, args[1], left, right)
+		}
+	}
+
+	var err object.Object
+	sort.SliceStable(elements, func(i, j int) bool {
+		if err != nil {
+			return false
+		}
+		result := less(elements[i], elements[j])
+		if isError(result) {
+			err = result
+			return false
+		}
+		return isTruthy(result)
+	})
+	if err != nil {
+		return err
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+// zipBuiltin pairs up the elements of its arguments until the shortest runs
+// out, so any but the shortest may be endless iterators.
+func zipBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) == 0 {
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `zip`, expected at least 1, got 0")
+	}
+
+	var nexts []func() (object.Object, bool)
+	for _, arg := range args {
+		iterator := iteratorOf(env, arg)
+		if isError(iterator) {
+			return iterator
+		}
+		nexts = append(nexts, iterator.(*object.Iterator).Next)
+	}
+
+	zipped := []object.Object{}
+	for {
+		tuple := make([]object.Object, len(nexts))
+		for index, next := range nexts {
+			element, ok := next()
+			if !ok {
+				return &object.Array{Elements: zipped}
+			}
+			if isError(element) {
+				return element
+			}
+			tuple[index] = element
+		}
+		zipped = append(zipped, &object.Array{Elements: tuple})
+	}
+}
+
+// flattenBuiltin splices the elements of nested arrays into one array, one
+// level deep.
+func flattenBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `flatten`, expected 1, got %d", len(args))
+	}
+
+	flattened := []object.Object{}
+	err := iterate(env, args[0], func(element object.Object) object.Object {
+		if nested, ok := element.(*object.Array); ok {
+			flattened = append(flattened, nested.Elements...)
+		} else {
+			flattened = append(flattened, element)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return &object.Array{Elements: flattened}
+}
+
+// groupByBuiltin returns a hash from each key the function gives to the
+// elements it was given for, keys in the order they were first seen.
+func groupByBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `group_by`, expected 2, got %d", len(args))
+	}
+
+	groups := object.NewHash()
+	err := iterate(env, args[0], func(element object.Object) object.Object {
+		key := call(env, args[1], element)
+		if isError(key) {
+			return key
+		}
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return newError(object.TYPE_ERROR, "unusable as hash key: %s", key.Type())
+		}
+
+		if group, ok := groups.Get(hashKey); ok {
+			group.(*object.Array).Elements = append(group.(*object.Array).Elements, element)
+		} else {
+			groups.Set(hashKey, &object.Array{Elements: []object.Object{element}})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return groups
+}
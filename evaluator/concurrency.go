@@ -0,0 +1,159 @@
+package evaluator
+
+import (
+	"github.com/noculture/plug/ast"
+	"github.com/noculture/plug/object"
+	"reflect"
+)
+
+// evalSpawnExpression evaluates the function and its arguments where the
+// spawn is written, then runs the call on a new goroutine. The function keeps
+// sharing the scopes it closes over with the code that spawned it.
+func evalSpawnExpression(node *ast.SpawnExpression, env *object.Environment) object.Object {
+	function := Eval(node.Call.Function, env)
+	if isError(function) {
+		return function
+	}
+	arguments := evalExpressions(node.Call.Arguments, env)
+	if len(arguments) == 1 && isError(arguments[0]) {
+		return arguments[0]
+	}
+
+	// the goroutine has a fresh Go stack, so its calls are counted from zero
+	caller := object.NewCallEnvironment(env, 0)
+
+	task := object.NewTask()
+	go func() {
+		task.Finish(withPosition(applyFunction(function, arguments, node.Call.Token, caller), node.Call.Token))
+	}()
+
+	return task
+}
+
+func waitBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `wait`, expected 1, got %d", len(args))
+	}
+
+	switch argument := args[0].(type) {
+	case *object.Task:
+		return argument.Wait()
+	case *object.Array:
+		// waiting on several tasks gives their results in the same order
+		results := make([]object.Object, len(argument.Elements))
+		for index, element := range argument.Elements {
+			task, ok := element.(*object.Task)
+			if !ok {
+				return newError(object.TYPE_ERROR, "argument to `wait` must be TASK or an array of them, got %s", element.Type())
+			}
+			results[index] = task.Wait()
+			if isError(results[index]) {
+				return results[index]
+			}
+		}
+		return &object.Array{Elements: results}
+	default:
+		return newError(object.TYPE_ERROR, "argument to `wait` must be TASK or an array of them, got %s", args[0].Type())
+	}
+}
+
+func chanBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) > 1 {
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `chan`, expected 0 or 1, got %d", len(args))
+	}
+
+	capacity := int64(0)
+	if len(args) == 1 {
+		integer, ok := args[0].(*object.Integer)
+		if !ok {
+			return newError(object.TYPE_ERROR, "argument to `chan` must be INTEGER, got %s", args[0].Type())
+		}
+		if integer.Value < 0 {
+			return newError(object.ARGUMENT_ERROR, "capacity of `chan` must not be negative, got %d", integer.Value)
+		}
+		capacity = integer.Value
+	}
+
+	return &object.Channel{Channel: make(chan object.Object, capacity)}
+}
+
+func sendBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `send`, expected 2, got %d", len(args))
+	}
+
+	channel, ok := args[0].(*object.Channel)
+	if !ok {
+		return newError(object.TYPE_ERROR, "first argument to `send` must be CHANNEL, got %s", args[0].Type())
+	}
+	if !channel.Send(args[1]) {
+		return newError(object.RUNTIME_ERROR, "send on closed channel")
+	}
+
+	return NULL
+}
+
+// recvBuiltin blocks until a value arrives, a closed and drained channel gives null.
+func recvBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `recv`, expected 1, got %d", len(args))
+	}
+
+	channel, ok := args[0].(*object.Channel)
+	if !ok {
+		return newError(object.TYPE_ERROR, "argument to `recv` must be CHANNEL, got %s", args[0].Type())
+	}
+
+	value, ok := <-channel.Channel
+	if !ok {
+		return NULL
+	}
+	return value
+}
+
+func closeBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `close`, expected 1, got %d", len(args))
+	}
+
+	channel, ok := args[0].(*object.Channel)
+	if !ok {
+		return newError(object.TYPE_ERROR, "argument to `close` must be CHANNEL, got %s", args[0].Type())
+	}
+	if !channel.Close() {
+		return newError(object.RUNTIME_ERROR, "close of closed channel")
+	}
+
+	return NULL
+}
+
+// selectBuiltin waits on several channels at once and returns the index of
+// the channel that was ready along with the value received from it, which is
+// null if that channel was closed.
+func selectBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `select`, expected 1, got %d", len(args))
+	}
+
+	channels, ok := args[0].(*object.Array)
+	if !ok || len(channels.Elements) == 0 {
+		return newError(object.TYPE_ERROR, "argument to `select` must be a non-empty array of channels")
+	}
+
+	cases := make([]reflect.SelectCase, len(channels.Elements))
+	for index, element := range channels.Elements {
+		channel, ok := element.(*object.Channel)
+		if !ok {
+			return newError(object.TYPE_ERROR, "argument to `select` must be a non-empty array of channels")
+		}
+		cases[index] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(channel.Channel)}
+	}
+
+	chosen, value, ok := reflect.Select(cases)
+	var received object.Object = NULL
+	if ok {
+		received = value.Interface().(object.Object)
+	}
+
+	return &object.Array{Elements: []object.Object{&object.Integer{Value: int64(chosen)}, received}}
+}
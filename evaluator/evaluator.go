@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/noculture/plug/ast"
 	"github.com/noculture/plug/object"
+	"github.com/noculture/plug/token"
 )
 
 var (
@@ -29,14 +30,40 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(value) {
 			return value
 		}
+		if function, ok := value.(*object.Function); ok && function.Name == "" {
+			function.Name = node.Name.Value
+		}
 		env.Set(node.Name.Value, value)
 	case *ast.ForStatement:
 		return evalForLoop(node, env)
+	case *ast.YieldStatement:
+		value := Eval(node.Value, env)
+		if isError(value) {
+			return value
+		}
+		if !env.Yield(value) {
+			return withPosition(newError(object.RUNTIME_ERROR, "yield outside of a generator"), node.Token)
+		}
+	case *ast.TryStatement:
+		return evalTryStatement(node, env)
+	case *ast.ThrowStatement:
+		return evalThrowStatement(node, env)
+	case *ast.StructStatement:
+		env.Set(node.Name.Value, evalStructStatement(node, env))
+	case *ast.EnumStatement:
+		env.Set(node.Name.Value, evalEnumStatement(node))
+	case *ast.ImportStatement:
+		return evalImportStatement(node, env)
+	case *ast.ExportStatement:
+		return Eval(node.Statement, env)
 	case *ast.FunctionLiteral:
 		parameters := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: parameters, Env: env, Body: body}
+		return &object.Function{Parameters: parameters, Env: env, Body: body, Generator: node.Generator, Async: node.Async}
 	case *ast.CallExpression:
+		if isSpecialCall(node, quoteFunction) {
+			return withPosition(quote(node, env), node.Token)
+		}
 		function := Eval(node.Function, env)
 		if isError(function) {
 			return function
@@ -46,9 +73,25 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if len(arguments) == 1 && isError(arguments[0]) {
 			return arguments[0]
 		}
-		return applyFunction(function, arguments)
+		return withPosition(applyFunction(function, arguments, node.Token, env), node.Token)
+	case *ast.MacroLiteral:
+		return withPosition(newError(object.RUNTIME_ERROR, "macros can only be bound with let at the top of a program"), node.Token)
+	case *ast.SpawnExpression:
+		return evalSpawnExpression(node, env)
+	case *ast.AwaitExpression:
+		return evalAwaitExpression(node, env)
 	case *ast.IfExpression:
 		return evalIfExpression(node, env)
+	case *ast.MatchExpression:
+		subject := Eval(node.Subject, env)
+		if isError(subject) {
+			return subject
+		}
+		body, err := selectMatchArm(node, subject, env)
+		if err != nil {
+			return withPosition(err, node.Token)
+		}
+		return Eval(body, env)
 	case *ast.ExpressionStatement:
 		return Eval(node.Expression, env)
 	case *ast.InfixExpression:
@@ -60,13 +103,16 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(rightExpression) {
 			return rightExpression
 		}
-		return evalInfixExpression(node.Operator, leftExpression, rightExpression)
+		if result, ok := evalOverloadedInfix(node.Operator, leftExpression, rightExpression, node.Token, env); ok {
+			return withPosition(result, node.Token)
+		}
+		return withPosition(evalInfixExpression(node.Operator, leftExpression, rightExpression, env), node.Token)
 	case *ast.PrefixExpression:
 		rightExpression := Eval(node.Right, env)
 		if isError(rightExpression) {
 			return rightExpression
 		}
-		return evalPrefixExpression(node.Operator, rightExpression)
+		return withPosition(evalPrefixExpression(node.Operator, rightExpression), node.Token)
 	case *ast.IndexExpression:
 		left := Eval(node.Left, env)
 		if isError(left) {
@@ -76,7 +122,22 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(index) {
 			return index
 		}
-		return evalIndexExpression(left, index)
+		if result, ok := callMethod(env, left, indexMethod, node.Token, index); ok {
+			return withPosition(result, node.Token)
+		}
+		return withPosition(evalIndexExpression(left, index), node.Token)
+	case *ast.DotExpression:
+		left := Eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+		return withPosition(evalDotExpression(left, node.Field.Value), node.Token)
+	case *ast.AssignExpression:
+		value := Eval(node.Value, env)
+		if isError(value) {
+			return value
+		}
+		return withPosition(evalAssignExpression(node, value, env), node.Token)
 	case *ast.ArrayLiteral:
 		elements := evalExpressions(node.Elements, env)
 		// catch errors
@@ -84,9 +145,18 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return elements[0]
 		}
 		return &object.Array{Elements: elements}
+	case *ast.HashLiteral:
+		return evalHashLiteral(node, env)
 	case *ast.Identifier:
-		return evalIdentifier(node, env)
+		return withPosition(evalIdentifier(node, env), node.Token)
+	case *ast.DecimalLiteral:
+		// the lexer only produces digits around a single dot, which always parse
+		decimal, _ := object.ParseDecimal(node.Token.Literal)
+		return decimal
 	case *ast.IntegerLiteral:
+		if node.Big != nil {
+			return &object.BigInt{Value: node.Big}
+		}
 		return &object.Integer{Value: node.Value}
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
@@ -133,14 +203,24 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 	return result
 }
 
-func evalForLoop(statement *ast.ForStatement, environment *object.Environment) object.Object {
+func evalForLoop(statement *ast.ForStatement, env *object.Environment) object.Object {
 	var body object.Object
+	var iterable object.Object
+
+	if statement.Iterable != nil {
+		iterable = Eval(statement.Iterable, env)
+	} else {
+		iterable = Eval(statement.Range, env)
+	}
+	if isError(iterable) {
+		return iterable
+	}
 
-	loopNumber := statement.Range.Arguments[0].(*ast.IntegerLiteral)
-	value := int(loopNumber.Value)
-	for i := 0; i < value; i++ {
-		environment.Set(statement.Index.Value, &object.Integer{Value: int64(i)})
-		body = evalBlockStatement(statement.Body, environment)
+	stop := iterate(env, iterable, func(element object.Object) object.Object {
+		if statement.Index != nil {
+			env.Set(statement.Index.Value, element)
+		}
+		body = evalBlockStatement(statement.Body, env)
 
 		if body != nil {
 			returnType := body.Type()
@@ -148,10 +228,61 @@ func evalForLoop(statement *ast.ForStatement, environment *object.Environment) o
 				return body
 			}
 		}
+		return nil
+	})
+	if stop != nil {
+		return withPosition(stop, statement.Token)
 	}
+
 	return body
 }
 
+func evalTryStatement(statement *ast.TryStatement, env *object.Environment) object.Object {
+	result := Eval(statement.Block, env)
+
+	if err, ok := result.(*object.Error); ok && err.Kind != object.EXIT && statement.Catch != nil {
+		if statement.Parameter != nil {
+			env.Set(statement.Parameter.Value, &object.Exception{Error: err})
+		}
+		result = Eval(statement.Catch, env)
+	}
+
+	if statement.Finally != nil {
+		// finally always runs, and a return or error inside it replaces
+		// whatever the try or catch blocks produced
+		final := Eval(statement.Finally, env)
+		if final != nil {
+			finalType := final.Type()
+			if finalType == object.RETURN_VALUE || finalType == object.ERROR {
+				return final
+			}
+		}
+	}
+
+	return result
+}
+
+func evalThrowStatement(statement *ast.ThrowStatement, env *object.Environment) object.Object {
+	value := Eval(statement.Value, env)
+	if isError(value) {
+		return value
+	}
+	if value == nil {
+		// empty blocks and bodies evaluate to nothing
+		value = NULL
+	}
+
+	switch value := value.(type) {
+	case *object.Exception:
+		// rethrowing keeps the original error intact
+		return value.Error
+	case *object.String:
+		return withPosition(&object.Error{Message: value.Value, Kind: object.THROWN_ERROR, Value: value}, statement.Token)
+	default:
+		return withPosition(&object.Error{Message: value.Inspect(), Kind: object.THROWN_ERROR, Value: value}, statement.Token)
+	}
+}
+
 func evalExpressions(expressions []ast.Expression, env *object.Environment) []object.Object {
 	var result []object.Object
 
@@ -166,26 +297,354 @@ func evalExpressions(expressions []ast.Expression, env *object.Environment) []ob
 	return result
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+func applyFunction(fn object.Object, args []object.Object, call token.Token, caller *object.Environment) object.Object {
 	switch function := fn.(type) {
 	case *object.Function:
-		innerEnv := createFunctionScope(function, args)
-		evaluated := Eval(function.Body, innerEnv)
-		return unwrapReturnValue(evaluated)
+		return applyUserFunction(function, args, call, caller)
 
 	case *object.Builtin:
-		return function.Function(args...)
+		return function.Function(caller, args...)
+
+	case *object.Struct:
+		return newInstance(function, args)
+
+	case *object.VariantConstructor:
+		if len(args) != len(function.Fields) {
+			return newError(object.ARGUMENT_ERROR, "invalid number of arguments to %s, expected %d, got %d",
+				function.Inspect(), len(function.Fields), len(args))
+		}
+		return &object.Variant{Constructor: function, Values: args}
+
+	default:
+		return newError(object.TYPE_ERROR, "not a function: %s", fn.Type())
+	}
+}
+
+// maxTailFrames bounds the frames kept for the calls a loop of tail calls
+// replaced, so tail recursion runs in constant memory. Tracebacks through
+// longer loops show the call that started the loop and the latest tail calls.
+const maxTailFrames = 100
+
+// applyUserFunction runs a Plug function. Calls the body makes in tail position
+// come back as TailCalls and are run by looping here, so tail recursion does
+// not grow the Go stack or count towards the call depth. Each tail call still
+// leaves a frame for tracebacks, up to maxTailFrames of them.
+func applyUserFunction(function *object.Function, args []object.Object, call token.Token, caller *object.Environment) object.Object {
+	depth := caller.Depth() + 1
+	var tailFrames []object.Frame // the calls replaced by tail calls, oldest first
+
+	for {
+		var evaluated object.Object
+
+		if limit := caller.Runtime().MaxCallDepth; limit > 0 && depth > limit {
+			evaluated = newError(object.RECURSION_ERROR, "maximum recursion depth exceeded")
+		} else if len(args) != len(function.Parameters) {
+			evaluated = newError(object.ARGUMENT_ERROR, "invalid number of arguments to %s, expected %d, got %d",
+				functionName(function), len(function.Parameters), len(args))
+		} else if function.Generator {
+			return newGenerator(function, args, call, depth)
+		} else if function.Async {
+			return startAsync(function, args, call, depth)
+		} else {
+			innerEnv := createFunctionScope(function, args, depth)
+			evaluated = evalTailBlock(function.Body, innerEnv, true)
+
+			if tailCall, ok := evaluated.(*object.TailCall); ok {
+				if next, ok := tailCall.Function.(*object.Function); ok {
+					if len(tailFrames) == 2*maxTailFrames {
+						tailFrames = append(tailFrames[:1], tailFrames[len(tailFrames)-maxTailFrames+1:]...)
+					}
+					tailFrames = append(tailFrames, callFrame(function, args, call))
+					function, args, call = next, tailCall.Arguments, tailCall.Call
+					continue
+				}
+				evaluated = withPosition(applyFunction(tailCall.Function, tailCall.Arguments, tailCall.Call, innerEnv), tailCall.Call)
+			}
+		}
+
+		if err, ok := evaluated.(*object.Error); ok {
+			// record the call, and the ones it replaced, so the error can be traced back to them
+			err.Stack = append(err.Stack, callFrame(function, args, call))
+			if len(tailFrames) > maxTailFrames {
+				tailFrames = append(tailFrames[:1], tailFrames[len(tailFrames)-maxTailFrames+1:]...)
+			}
+			for index := len(tailFrames) - 1; index >= 0; index-- {
+				err.Stack = append(err.Stack, tailFrames[index])
+			}
+			return err
+		}
+		return unwrapReturnValue(evaluated)
+	}
+}
+
+func callFrame(function *object.Function, args []object.Object, call token.Token) object.Frame {
+	return object.Frame{Function: function.Name, Line: call.Line, Column: call.Column, Arguments: len(args)}
+}
+
+// evalTailBlock evaluates a block of a function body. When tail is set the
+// last statement of the block is in tail position, return statements always are.
+func evalTailBlock(block *ast.BlockStatement, env *object.Environment, tail bool) object.Object {
+	var result object.Object
+
+	for index, statement := range block.Statements {
+		last := tail && index == len(block.Statements)-1
+
+		switch statement := statement.(type) {
+		case *ast.ReturnStatement:
+			result = evalTailExpression(statement.ReturnValue, env, true)
+			if result != nil && !isError(result) && result.Type() != object.TAIL_CALL {
+				result = &object.ReturnValue{Value: result}
+			}
+		case *ast.ExpressionStatement:
+			result = evalTailExpression(statement.Expression, env, last)
+		default:
+			result = Eval(statement, env)
+		}
+
+		if result != nil {
+			resultType := result.Type()
+			if resultType == object.RETURN_VALUE || resultType == object.ERROR || resultType == object.TAIL_CALL {
+				return result
+			}
+		}
+	}
+
+	return result
+}
+
+func evalTailExpression(expression ast.Expression, env *object.Environment, tail bool) object.Object {
+	switch expression := expression.(type) {
+	case *ast.CallExpression:
+		if !tail || isSpecialCall(expression, quoteFunction) {
+			break
+		}
+		function := Eval(expression.Function, env)
+		if isError(function) {
+			return function
+		}
+		arguments := evalExpressions(expression.Arguments, env)
+		if len(arguments) == 1 && isError(arguments[0]) {
+			return arguments[0]
+		}
+		return &object.TailCall{Function: function, Arguments: arguments, Call: expression.Token}
+
+	case *ast.IfExpression:
+		// returns inside either branch can still be tail calls, so the
+		// branches are walked even when the if itself is not in tail position
+		condition := Eval(expression.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+		if isTruthy(condition) {
+			return evalTailBlock(expression.Consequence, env, tail)
+		} else if expression.Alternative != nil {
+			return evalTailBlock(expression.Alternative, env, tail)
+		}
+		return NULL
+
+	case *ast.MatchExpression:
+		subject := Eval(expression.Subject, env)
+		if isError(subject) {
+			return subject
+		}
+		body, err := selectMatchArm(expression, subject, env)
+		if err != nil {
+			return withPosition(err, expression.Token)
+		}
+		return evalTailBlock(body, env, tail)
+	}
+
+	return Eval(expression, env)
+}
+
+func evalStructStatement(statement *ast.StructStatement, env *object.Environment) *object.Struct {
+	structure := &object.Struct{Name: statement.Name.Value, Methods: map[string]*object.Function{}}
+
+	for _, field := range statement.Fields {
+		structure.Fields = append(structure.Fields, field.Value)
+	}
+	for _, method := range statement.Methods {
+		structure.Methods[method.Name] = &object.Function{
+			Name:       structure.Name + "." + method.Name,
+			Parameters: method.Parameters,
+			Body:       method.Body,
+			Env:        env,
+			Generator:  method.Generator,
+			Async:      method.Async,
+		}
+	}
+
+	return structure
+}
+
+func evalEnumStatement(statement *ast.EnumStatement) *object.Enum {
+	enum := &object.Enum{Name: statement.Name.Value}
+
+	for _, declared := range statement.Variants {
+		variant := &object.VariantConstructor{Enum: enum, Name: declared.Name.Value}
+		for _, field := range declared.Fields {
+			variant.Fields = append(variant.Fields, field.Value)
+		}
+		if len(variant.Fields) == 0 {
+			variant.Unit = &object.Variant{Constructor: variant}
+		}
+		enum.Variants = append(enum.Variants, variant)
+	}
+
+	return enum
+}
+
+// selectMatchArm finds the first arm matching the subject and binds the
+// payload names of its pattern, the arm's body is left for the caller to run.
+func selectMatchArm(match *ast.MatchExpression, subject object.Object, env *object.Environment) (*ast.BlockStatement, *object.Error) {
+	variant, isVariant := subject.(*object.Variant)
+
+	for _, arm := range match.Arms {
+		if arm.IsWildcard() {
+			return arm.Body, nil
+		}
+		if !isVariant {
+			continue
+		}
+
+		constructor := variant.Constructor
+		if constructor.Enum.Name != arm.Enum.Value || constructor.Name != arm.Variant.Value {
+			continue
+		}
+
+		if arm.Bindings != nil {
+			if len(arm.Bindings) != len(variant.Values) {
+				return nil, newError(object.TYPE_ERROR, "%s.%s carries %d values, pattern binds %d",
+					constructor.Enum.Name, constructor.Name, len(variant.Values), len(arm.Bindings))
+			}
+			for index, binding := range arm.Bindings {
+				if binding.Value != "_" {
+					env.Set(binding.Value, variant.Values[index])
+				}
+			}
+		}
+
+		return arm.Body, nil
+	}
+
+	if !isVariant {
+		return nil, newError(object.TYPE_ERROR, "cannot match on %s", subject.Type())
+	}
+	return nil, newError(object.RUNTIME_ERROR, "no match arm for %s", variant.Inspect())
+}
+
+func newInstance(structure *object.Struct, args []object.Object) object.Object {
+	if len(args) > len(structure.Fields) {
+		return newError(object.ARGUMENT_ERROR, "too many arguments to %s, expected at most %d, got %d",
+			structure.Name, len(structure.Fields), len(args))
+	}
+
+	fields := map[string]object.Object{}
+	for index, field := range structure.Fields {
+		if index < len(args) {
+			fields[field] = args[index]
+		} else {
+			fields[field] = NULL
+		}
+	}
+
+	return object.NewInstance(structure, fields)
+}
+
+// boundMethod looks up a method on the instance's struct and binds `self`
+// to the instance, the result can be called like any other function.
+func boundMethod(instance *object.Instance, name string) (*object.Function, bool) {
+	method, ok := instance.Struct.Methods[name]
+	if !ok {
+		return nil, false
+	}
+
+	env := object.NewEnclosedEvironment(method.Env)
+	env.Set("self", instance)
 
+	bound := &object.Function{
+		Name:       method.Name,
+		Parameters: method.Parameters,
+		Body:       method.Body,
+		Env:        env,
+		Generator:  method.Generator,
+		Async:      method.Async,
+	}
+	return bound, true
+}
+
+func evalDotExpression(left object.Object, name string) object.Object {
+	switch left := left.(type) {
+	case *object.Instance:
+		if value, ok := left.Field(name); ok {
+			return value
+		}
+		if method, ok := boundMethod(left, name); ok {
+			return method
+		}
+		return newError(object.NAME_ERROR, "%s has no field or method %s", left.Struct.Name, name)
+	case *object.Exception:
+		return evalExceptionIndexExpression(left, &object.String{Value: name})
+	case *object.Module:
+		value, ok := left.Exports[name]
+		if !ok {
+			return newError(object.NAME_ERROR, "module %s has no export %s", left.Name, name)
+		}
+		return value
+	case *object.Enum:
+		variant, ok := left.Variant(name)
+		if !ok {
+			return newError(object.NAME_ERROR, "%s has no variant %s", left.Name, name)
+		}
+		if variant.Unit != nil {
+			return variant.Unit
+		}
+		return variant
+	default:
+		return newError(object.TYPE_ERROR, "field access not supported: %s.%s", left.Type(), name)
+	}
+}
+
+func evalAssignExpression(node *ast.AssignExpression, value object.Object, env *object.Environment) object.Object {
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		if !env.Assign(target.Value, value) {
+			return newError(object.NAME_ERROR, "cannot assign to undeclared identifier: %s", target.Value)
+		}
+	case *ast.DotExpression:
+		left := Eval(target.Left, env)
+		if isError(left) {
+			return left
+		}
+		instance, ok := left.(*object.Instance)
+		if !ok {
+			return newError(object.TYPE_ERROR, "field assignment not supported: %s.%s", left.Type(), target.Field.Value)
+		}
+		if !instance.Struct.HasField(target.Field.Value) {
+			return newError(object.NAME_ERROR, "%s has no field %s", instance.Struct.Name, target.Field.Value)
+		}
+		instance.SetField(target.Field.Value, value)
 	default:
-		return newError("not a function: %s", fn.Type())
+		// the parser only builds these two, macros can produce anything
+		return newError(object.TYPE_ERROR, "cannot assign to %s", node.Target.String())
 	}
+
+	return value
+}
+
+// functionName is how errors refer to a function.
+func functionName(function *object.Function) string {
+	if function.Name == "" {
+		return "<anonymous>"
+	}
+	return function.Name
 }
 
-func createFunctionScope(fn *object.Function, arguments []object.Object) *object.Environment {
+func createFunctionScope(fn *object.Function, arguments []object.Object, depth int) *object.Environment {
 
 	// passing the function's environment allow for closures, we still have the
 	// function's bindings ling after it has finished execution
-	env := object.NewEnclosedEvironment(fn.Env)
+	env := object.NewCallEnvironment(fn.Env, depth)
 
 	for paramIndex, param := range fn.Parameters {
 		env.Set(param.Value, arguments[paramIndex])
@@ -233,8 +692,77 @@ func evalIndexExpression(left, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.ARRAY && index.Type() == object.INTEGER:
 		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.HASH:
+		return evalHashIndexExpression(left, index)
+	case left.Type() == object.EXCEPTION && index.Type() == object.STRING:
+		return evalExceptionIndexExpression(left, index)
+	default:
+		return newError(object.TYPE_ERROR, "index operator not supported: %s", index.Type())
+	}
+}
+
+func evalHashIndexExpression(hash, index object.Object) object.Object {
+	key, ok := index.(object.Hashable)
+	if !ok {
+		return newError(object.TYPE_ERROR, "unusable as hash key: %s", index.Type())
+	}
+
+	value, ok := hash.(*object.Hash).Get(key)
+	if !ok {
+		return NULL
+	}
+	return value
+}
+
+func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+	hash := object.NewHash()
+
+	for index, keyNode := range node.Keys {
+		key := Eval(keyNode, env)
+		if isError(key) {
+			return key
+		}
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return withPosition(newError(object.TYPE_ERROR, "unusable as hash key: %s", key.Type()), node.Token)
+		}
+
+		value := Eval(node.Values[index], env)
+		if isError(value) {
+			return value
+		}
+		hash.Set(hashKey, value)
+	}
+
+	return hash
+}
+
+func evalExceptionIndexExpression(exception, index object.Object) object.Object {
+	err := exception.(*object.Exception).Error
+	key := index.(*object.String).Value
+
+	switch key {
+	case "message":
+		return &object.String{Value: err.Message}
+	case "kind":
+		return &object.String{Value: err.Kind}
+	case "line":
+		return &object.Integer{Value: int64(err.Line)}
+	case "column":
+		return &object.Integer{Value: int64(err.Column)}
+	case "value":
+		if err.Value == nil {
+			return NULL
+		}
+		return err.Value
+	case "stack":
+		frames := make([]object.Object, len(err.Stack))
+		for i, frame := range err.Stack {
+			frames[i] = &object.String{Value: frame.String()}
+		}
+		return &object.Array{Elements: frames}
 	default:
-		return newError("index operator not supported: %s", index.Type())
+		return NULL
 	}
 }
 
@@ -252,59 +780,82 @@ func evalArrayIndexExpression(array, index object.Object) object.Object {
 
 // For expressions that resolve to boolean, direct comparison can be carried out since there are only
 // two boolean objects. In other cases the values have to be unwrapped and compared instead.
-func evalInfixExpression(operator string, left, right object.Object) object.Object {
+func evalInfixExpression(operator string, left, right object.Object, env *object.Environment) object.Object {
 	switch {
-	case left.Type() == object.INTEGER && right.Type() == object.INTEGER:
+	case isInteger(left) && isInteger(right):
 		return evalIntegerInfixExpression(operator, left, right)
+	case isNumber(left) && isNumber(right):
+		return evalNumberInfixExpression(operator, left, right, env)
 	case left.Type() == object.STRING && right.Type() == object.STRING:
 		return evalStringInfixExpression(operator, left, right)
+	case left.Type() == object.VARIANT && right.Type() == object.VARIANT && operator == "==":
+		return referenceBoolObject(objectsEqual(left, right))
+	case left.Type() == object.VARIANT && right.Type() == object.VARIANT && operator == "!=":
+		return referenceBoolObject(!objectsEqual(left, right))
 	case operator == "==":
 		return referenceBoolObject(left == right)
 	case operator == "!=":
 		return referenceBoolObject(left != right)
 	case left.Type() != right.Type():
-		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+		return newError(object.TYPE_ERROR, "type mismatch: %s %s %s", left.Type(), operator, right.Type())
+	default:
+		return newError(object.TYPE_ERROR, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// objectsEqual compares values structurally where Plug does, everything else
+// is only equal to itself.
+func objectsEqual(left, right object.Object) bool {
+	switch left := left.(type) {
+	case *object.Integer:
+		if other, ok := right.(*object.Integer); ok {
+			return left.Value == other.Value
+		}
+		return isNumber(right) && toRat(left).Cmp(toRat(right)) == 0
+	case *object.BigInt, *object.Decimal, *object.Rational:
+		// numbers of different types are equal when their values are
+		return isNumber(right) && toRat(left).Cmp(toRat(right)) == 0
+	case *object.String:
+		other, ok := right.(*object.String)
+		return ok && left.Value == other.Value
+	case *object.Variant:
+		other, ok := right.(*object.Variant)
+		if !ok || left.Constructor != other.Constructor {
+			return false
+		}
+		for index := range left.Values {
+			if !objectsEqual(left.Values[index], other.Values[index]) {
+				return false
+			}
+		}
+		return true
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return left == right
 	}
 }
 
-func evalIntegerInfixExpression(operator string, left, right object.Object) object.Object {
-	leftValue := left.(*object.Integer).Value
-	rightValue := right.(*object.Integer).Value
+// evalStringInfixExpression concatenates and compares strings. They compare
+// by their text, so strings built separately are equal when it is the same.
+func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
+	leftValue := left.(*object.String).Value
+	rightValue := right.(*object.String).Value
 
 	switch operator {
 	case "+":
-		return &object.Integer{Value: leftValue + rightValue}
-	case "-":
-		return &object.Integer{Value: leftValue - rightValue}
-	case "*":
-		return &object.Integer{Value: leftValue * rightValue}
-	case "/":
-		return &object.Integer{Value: leftValue / rightValue}
-	case ">":
-		return referenceBoolObject(leftValue > rightValue)
-	case "<":
-		return referenceBoolObject(leftValue < rightValue)
+		return &object.String{Value: leftValue + rightValue}
 	case "==":
 		return referenceBoolObject(leftValue == rightValue)
 	case "!=":
 		return referenceBoolObject(leftValue != rightValue)
+	case "<":
+		return referenceBoolObject(leftValue < rightValue)
+	case ">":
+		return referenceBoolObject(leftValue > rightValue)
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newError(object.TYPE_ERROR, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
 
-func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
-	if operator != "+" {
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
-	}
-
-	leftValue := left.(*object.String).Value
-	rightValue := right.(*object.String).Value
-	return &object.String{Value: leftValue + rightValue}
-}
-
 func evalPrefixExpression(operator string, right object.Object) object.Object {
 	switch operator {
 	case "!":
@@ -312,7 +863,7 @@ func evalPrefixExpression(operator string, right object.Object) object.Object {
 	case "-":
 		return evalMinusPrefixOperator(right)
 	default:
-		return newError("unknown operator: %s%s", operator, right.Type())
+		return newError(object.TYPE_ERROR, "unknown operator: %s%s", operator, right.Type())
 	}
 }
 
@@ -329,14 +880,6 @@ func evalBangOperator(expression object.Object) object.Object {
 	}
 }
 
-func evalMinusPrefixOperator(expression object.Object) object.Object {
-	if expression.Type() != object.INTEGER {
-		return newError("unknown operator: -%s", expression.Type())
-	}
-	value := expression.(*object.Integer).Value
-	return &object.Integer{Value: -value}
-}
-
 func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
 	if value, ok := env.Get(node.Value); ok {
 		return value
@@ -345,11 +888,21 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 		return builtin
 	}
 
-	return newError("identifier not found: " + node.Value)
+	return newError(object.NAME_ERROR, "identifier not found: %s", node.Value)
 }
 
-func newError(format string, a ...interface{}) *object.Error {
-	return &object.Error{Message: fmt.Sprintf(format, a...)}
+func newError(kind string, format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...), Kind: kind}
+}
+
+// withPosition records where an error was raised. Errors keep the position of
+// the innermost node that produced them as they unwind.
+func withPosition(obj object.Object, tok token.Token) object.Object {
+	if err, ok := obj.(*object.Error); ok && err.Line == 0 {
+		err.Line = tok.Line
+		err.Column = tok.Column
+	}
+	return obj
 }
 
 func isError(obj object.Object) bool {
@@ -1,10 +1,18 @@
 package evaluator
 
 import (
+	"fmt"
+	"github.com/noculture/plug/ast"
 	"github.com/noculture/plug/lexer"
 	"github.com/noculture/plug/object"
 	"github.com/noculture/plug/parser"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 )
 
 type TestCase struct {
@@ -164,6 +172,28 @@ func TestStringConcatenation(t *testing.T) {
 	}
 }
 
+func TestStringEquality(t *testing.T) {
+	testCases := []BooleanTestCase{
+		{`"a" == "a"`, true},
+		{`"a" == "b"`, false},
+		{`"a" != "b"`, true},
+		{`"a" != "a"`, false},
+		{`"a" + "b" == "ab"`, true},
+		{`"a" + "b" != "ab"`, false},
+		{`let x = "ab"; let y = "a" + "b"; x == y`, true},
+		{`"" == ""`, true},
+		{`"1" == 1`, false},
+		{`"1" != 1`, true},
+	}
+
+	for _, testCase := range testCases {
+		evaluated := testEval(testCase.input)
+		testBoolObject(t, testCase.expected, evaluated)
+	}
+
+	testErrorObject(t, testEval(`"a" - "b"`), "unknown operator: STRING - STRING")
+}
+
 func TestEvalBooleanExpression(t *testing.T) {
 	testCases := []BooleanTestCase{
 		{"true", true},
@@ -333,26 +363,1872 @@ func TestBuiltinFunctions(t *testing.T) {
 	}
 }
 
-func testEval(input string) object.Object {
-	lex := lexer.New(input)
-	p := parser.New(lex)
-	program := p.ParseProgram()
+func TestTryCatchFinally(t *testing.T) {
+	testCases := []TestCase{
+		{"try { 1 } catch (e) { 2 }", 1},
+		{"try { throw 1; 5 } catch (e) { 2 }", 2},
+		{"try { len(1) } catch (e) { 3 }", 3},
+		{"try { foobar } catch { 4 }", 4},
+		{"let x = 1; try { throw 2 } catch (e) { let x = e[\"value\"] }; x", 2},
+		{"let x = 1; try { x } finally { let x = 5 }; x", 5},
+		{"let f = func() { try { return 1 } finally { return 2 } }; f()", 2},
+		{"let f = func() { try { throw 1 } catch (e) { return 3 } finally { 4 } }; f()", 3},
+		{"try { try { throw 1 } catch (e) { throw e } } catch (e) { e[\"value\"] }", 1},
+		{"try { throw 1 } catch (e) { e[\"line\"] }", 1},
+		{"try { throw 7 } catch (e) { e.value }", 7},
+		{"try {\n\n  5 + true } catch (e) { e[\"line\"] }", 3},
+	}
+
+	for _, testCase := range testCases {
+		evaluated := testEval(testCase.input)
+		testIntegerObject(t, int64(testCase.expected.(int)), evaluated)
+	}
+}
+
+func TestCaughtErrorFields(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{`try { throw "boom" } catch (e) { e["message"] }`, "boom"},
+		{`try { throw "boom" } catch (e) { e["kind"] }`, "Error"},
+		{`try { len(1) } catch (e) { e["message"] }`, "argument to `len` not supported, got INTEGER"},
+		{`try { len(1) } catch (e) { e["kind"] }`, "TypeError"},
+		{`try { len(1, 2) } catch (e) { e["kind"] }`, "ArgumentError"},
+		{`let f = func() {}; try { throw f() } catch (e) { e["message"] }`, "null"},
+		{`try { throw if (true) {} } catch (e) { e["message"] }`, "null"},
+		{`try { foobar } catch (e) { e["kind"] }`, "NameError"},
+	}
+
+	for _, testCase := range testCases {
+		evaluated := testEval(testCase.input)
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("object is not String, got %T (%+v)", evaluated, evaluated)
+			continue
+		}
+		if str.Value != testCase.expected {
+			t.Errorf("wrong value, expected %q, got %q", testCase.expected, str.Value)
+		}
+	}
+}
+
+func TestUncaughtThrow(t *testing.T) {
+	evaluated := testEval(`let f = func() { throw "bad" }; try { 1 } finally { 2 }; f(); 5`)
+	err, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("object is not an error, got %T (%+v)", evaluated, evaluated)
+	}
+	if err.Message != "bad" || err.Kind != object.THROWN_ERROR {
+		t.Errorf("wrong error, got %s %q", err.Kind, err.Message)
+	}
+}
+
+func TestErrorStackTrace(t *testing.T) {
+	input := `let inner = func(x) {
+	x + missing;
+};
+let outer = func(a, b) {
+	inner(a);
+};
+outer(1, 2);`
+
+	evaluated := testEval(input)
+	err, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("object is not an error, got %T (%+v)", evaluated, evaluated)
+	}
+
+	expected := []object.Frame{
+		{Function: "inner", Line: 5, Column: 7, Arguments: 1},
+		{Function: "outer", Line: 7, Column: 6, Arguments: 2},
+	}
+	if len(err.Stack) != len(expected) {
+		t.Fatalf("wrong number of frames, expected %d, got %d (%+v)", len(expected), len(err.Stack), err.Stack)
+	}
+	for index, frame := range expected {
+		if err.Stack[index] != frame {
+			t.Errorf("wrong frame %d, expected %+v, got %+v", index, frame, err.Stack[index])
+		}
+	}
+
+	traceback := `Error: identifier not found: missing [2:6]
+    at inner(1 arg) 5:7
+    at outer(2 args) 7:6
+`
+	if err.Traceback() != traceback {
+		t.Errorf("wrong traceback, expected %q, got %q", traceback, err.Traceback())
+	}
+}
+
+func TestLongTracebackIsCompacted(t *testing.T) {
+	err := &object.Error{Message: "deep"}
+	for i := 0; i < 25; i++ {
+		err.Stack = append(err.Stack, object.Frame{Function: "f", Line: 1, Column: 1, Arguments: 1})
+	}
+
+	traceback := err.Traceback()
+	if !strings.Contains(traceback, "    ... 5 more frames\n") {
+		t.Errorf("long traceback not compacted, got %q", traceback)
+	}
+	if lines := strings.Count(traceback, "\n"); lines != 22 {
+		t.Errorf("expected 22 lines in traceback, got %d", lines)
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	testCases := []IntegerTestCase{
+		{"let count = func(n, acc) { if (n == 0) { return acc }; count(n - 1, acc + 1) }; count(1000000, 0)", 1000000},
+		{"let count = func(n) { if (n == 0) { 0 } else { return count(n - 1) } }; count(100000)", 0},
+		{`let even = func(n) { if (n == 0) { true } else { odd(n - 1) } };
+let odd = func(n) { if (n == 0) { false } else { even(n - 1) } };
+if (even(100000)) { 1 } else { 0 }`, 1},
+		{"let last = func(n) { if (n == 0) { len([1, 2, 3]) } else { last(n - 1) } }; last(20000)", 3},
+	}
+
+	testIntegerCases(testCases, t)
+
+	evaluated := testEval("let count = func(n) { if (n == 0) { foo } else { count(n - 1) } }; count(20000)")
+	err, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("object is not an error, got %T (%+v)", evaluated, evaluated)
+	}
+	if err.Message != "identifier not found: foo" {
+		t.Errorf("wrong error from tail call, got %q", err.Message)
+	}
+}
+
+func TestTailCallTraceback(t *testing.T) {
+	input := `let fail = func(x) { x + missing };
+let odd = func(n) { if (n == 0) { fail(n) } else { even(n - 1) } };
+let even = func(n) { odd(n) };
+even(1)`
+
+	evaluated := testEval(input)
+	err, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("object is not an error, got %T (%+v)", evaluated, evaluated)
+	}
+
+	expected := []object.Frame{
+		{Function: "fail", Line: 2, Column: 39, Arguments: 1},
+		{Function: "odd", Line: 3, Column: 25, Arguments: 1},
+		{Function: "even", Line: 2, Column: 56, Arguments: 1},
+		{Function: "odd", Line: 3, Column: 25, Arguments: 1},
+		{Function: "even", Line: 4, Column: 5, Arguments: 1},
+	}
+	if len(err.Stack) != len(expected) {
+		t.Fatalf("tail calls should leave frames, expected %d, got %d (%+v)", len(expected), len(err.Stack), err.Stack)
+	}
+	for index, frame := range expected {
+		if err.Stack[index] != frame {
+			t.Errorf("wrong frame %d, expected %+v, got %+v", index, frame, err.Stack[index])
+		}
+	}
+
+	// a long loop keeps the call that started it and the latest tail calls
+	evaluated = testEval("let count = func(n) { if (n == 0) { foo } else { count(n - 1) } }; count(20000)")
+	err, ok = evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("object is not an error, got %T (%+v)", evaluated, evaluated)
+	}
+	if len(err.Stack) != maxTailFrames+1 {
+		t.Fatalf("expected %d frames, got %d", maxTailFrames+1, len(err.Stack))
+	}
+	outermost := object.Frame{Function: "count", Line: 1, Column: 73, Arguments: 1}
+	if err.Stack[len(err.Stack)-1] != outermost {
+		t.Errorf("the last frame should be the call starting the loop, got %+v", err.Stack[len(err.Stack)-1])
+	}
+}
+
+func TestTailRecursionOverArray(t *testing.T) {
+	elements := make([]object.Object, 12000)
+	for i := range elements {
+		elements[i] = &object.Integer{Value: 1}
+	}
+
 	env := object.NewEnvironment()
+	env.Set("items", &object.Array{Elements: elements})
+
+	input := `let sum = func(arr, acc) {
+	if (len(arr) == 0) { return acc }
+	sum(rest(arr), acc + first(arr))
+};
+sum(items, 0)`
+	program := testParse(input)
 
-	return Eval(program, env)
+	testIntegerObject(t, 12000, Eval(program, env))
 }
 
-func testIntegerCases(testCases []IntegerTestCase, t *testing.T) {
+func TestNonTailCallsStillNest(t *testing.T) {
+	evaluated := testEval("let count = func(n) { if (n == 0) { 0 } else { 1 + count(n - 1) } }; count(20000)")
+	err, ok := evaluated.(*object.Error)
+	if !ok || err.Kind != object.RECURSION_ERROR {
+		t.Errorf("expected a recursion error, got %T (%+v)", evaluated, evaluated)
+	}
+}
+
+func TestRecursionDepthLimit(t *testing.T) {
+	input := `let forever = func(n) { forever(n + 1) + 1 }; forever(0);`
+
+	evaluated := testEval(input)
+	err, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("object is not an error, got %T (%+v)", evaluated, evaluated)
+	}
+	if err.Kind != object.RECURSION_ERROR || err.Message != "maximum recursion depth exceeded" {
+		t.Errorf("wrong error, got %s %q", err.Kind, err.Message)
+	}
+	if len(err.Stack) != object.DefaultMaxCallDepth+1 {
+		t.Errorf("expected %d frames, got %d", object.DefaultMaxCallDepth+1, len(err.Stack))
+	}
+
+	caught := testEval(`let forever = func(n) { forever(n + 1) + 1 };
+try { forever(0) } catch (e) { e["kind"] }`)
+	str, ok := caught.(*object.String)
+	if !ok || str.Value != object.RECURSION_ERROR {
+		t.Errorf("recursion error was not caught, got %T (%+v)", caught, caught)
+	}
+}
+
+func TestConfigurableRecursionDepth(t *testing.T) {
+	input := `let down = func(n) { if (n == 0) { 0 } else { down(n - 1) + 1 } };`
+	program := testParse(input + "down(5)")
+
+	runtime := object.NewRuntime()
+	runtime.MaxCallDepth = 5
+	if _, ok := Eval(program, object.NewEnvironmentWithRuntime(runtime)).(*object.Error); !ok {
+		t.Errorf("expected depth 6 to exceed a limit of 5")
+	}
+
+	runtime.MaxCallDepth = 6
+	testIntegerObject(t, 5, Eval(program, object.NewEnvironmentWithRuntime(runtime)))
+}
+
+func TestStructs(t *testing.T) {
+	declaration := `struct Point {
+	x, y
+	func sum() { self.x + self.y }
+	func scale(factor) { Point(self.x * factor, self.y * factor) }
+	func move(dx) { self.x = self.x + dx; self }
+};
+`
+	testCases := []IntegerTestCase{
+		{"let p = Point(1, 2); p.x", 1},
+		{"let p = Point(1, 2); p.y", 2},
+		{"let p = Point(1, 2); p.sum()", 3},
+		{"let p = Point(1, 2); p.scale(3).sum()", 9},
+		{"let p = Point(1, 2); p.x = 10; p.sum()", 12},
+		{"let p = Point(1, 2); p.move(4); p.x", 5},
+		{"let p = Point(1, 2); let s = p.sum; p.x = 3; s()", 5},
+		{"let ps = [Point(1, 1), Point(2, 2)]; ps[1].y", 2},
+	}
+
 	for _, testCase := range testCases {
-		testIntegerObject(t, testCase.expected, testEval(testCase.input))
+		testIntegerObject(t, testCase.expected, testEval(declaration+testCase.input))
+	}
+
+	testNullObject(t, testEval(declaration+"Point(1).y"))
+
+	inspected := testEval(declaration + "Point(1, [2, 3])").Inspect()
+	if inspected != "Point{x: 1, y: [2, 3]}" {
+		t.Errorf("wrong inspect output, got %q", inspected)
 	}
 }
 
-func testNullObject(t *testing.T, obj object.Object) bool {
-	if obj != NULL {
-		t.Errorf("object is not null, got %T (%+v)", obj, obj)
-		return false
+func TestAssignment(t *testing.T) {
+	testCases := []IntegerTestCase{
+		{"let a = 1; a = 2; a", 2},
+		{"let a = 1; let b = 1; a = b = 3; a + b", 6},
+		{"let a = 1; let set = func() { a = 5 }; set(); a", 5},
+		{"let a = 1; let shadow = func() { let a = 2; a = 3 }; shadow(); a", 1},
+	}
+
+	testIntegerCases(testCases, t)
+}
+
+func TestStructErrors(t *testing.T) {
+	testCases := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"struct P { x }; P(1, 2)", "too many arguments to P, expected at most 1, got 2"},
+		{"struct P { x }; P(1).y", "P has no field or method y"},
+		{"struct P { x }; let p = P(1); p.y = 2", "P has no field y"},
+		{"5.x", "field access not supported: INTEGER.x"},
+		{"b = 1", "cannot assign to undeclared identifier: b"},
+	}
+
+	for _, testCase := range testCases {
+		evaluated := testEval(testCase.input)
+		errorObject, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("No error object returned, got %T (%+v)", evaluated, evaluated)
+			continue
+		}
+		if errorObject.Message != testCase.expectedMessage {
+			t.Errorf("Wrong error message, expected %q, got %q", testCase.expectedMessage, errorObject.Message)
+		}
+	}
+}
+
+func TestEnumsAndMatch(t *testing.T) {
+	declaration := `enum Shape { Circle(radius), Rect(width, height), Empty }
+let area = func(shape) {
+	match (shape) {
+		Shape.Circle(r) => 3 * r * r,
+		Shape.Rect(w, h) => { let a = w * h; a },
+		Shape.Empty => 0,
+	}
+};
+`
+	testCases := []IntegerTestCase{
+		{"area(Shape.Circle(2))", 12},
+		{"area(Shape.Rect(2, 5))", 10},
+		{"area(Shape.Empty)", 0},
+		{"match (Shape.Rect(1, 2)) { Shape.Circle(r) => r, _ => 7 }", 7},
+		{"match (Shape.Rect(1, 2)) { Shape.Rect(_, h) => h, _ => 7 }", 2},
+		{"match (5) { _ => 1 }", 1},
+		{"if (Shape.Circle(1) == Shape.Circle(1)) { 1 } else { 0 }", 1},
+		{"if (Shape.Circle(1) != Shape.Circle(2)) { 1 } else { 0 }", 1},
+		{"if (Shape.Empty == Shape.Empty) { 1 } else { 0 }", 1},
+		{`let count = func(n) { match (n) { Shape.Circle(r) => if (r == 0) { 0 } else { count(Shape.Circle(r - 1)) }, _ => 1 } };
+count(Shape.Circle(100000))`, 0},
+	}
+
+	for _, testCase := range testCases {
+		testIntegerObject(t, testCase.expected, testEval(declaration+testCase.input))
+	}
+
+	inspectCases := []struct {
+		input    string
+		expected string
+	}{
+		{"Shape.Circle(3)", "Shape.Circle(3)"},
+		{"Shape.Rect(1, 2)", "Shape.Rect(1, 2)"},
+		{"Shape.Empty", "Shape.Empty"},
+		{"Shape.Circle", "Shape.Circle(radius)"},
+		{"Shape", "enum Shape {Circle(radius), Rect(width, height), Empty}"},
+	}
+
+	for _, testCase := range inspectCases {
+		inspected := testEval(declaration + testCase.input).Inspect()
+		if inspected != testCase.expected {
+			t.Errorf("wrong inspect output, expected %q, got %q", testCase.expected, inspected)
+		}
+	}
+}
+
+func TestMatchErrors(t *testing.T) {
+	testCases := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"enum E { A(x) }; E.A(1, 2)", "invalid number of arguments to E.A(x), expected 1, got 2"},
+		{"enum E { A }; E.B", "E has no variant B"},
+		{"match (5) { E.A => 1 }", "cannot match on INTEGER"},
+	}
+
+	for _, testCase := range testCases {
+		evaluated := testEval(testCase.input)
+		errorObject, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("No error object returned, got %T (%+v)", evaluated, evaluated)
+			continue
+		}
+		if errorObject.Message != testCase.expectedMessage {
+			t.Errorf("Wrong error message, expected %q, got %q", testCase.expectedMessage, errorObject.Message)
+		}
+	}
+
+	// enums from another program are only checked when the match runs
+	env := object.NewEnvironment()
+	Eval(testParse("enum E { A, B }"), env)
+	evaluated := Eval(testParse("match (E.B) { E.A => 1 }"), env)
+	errorObject, ok := evaluated.(*object.Error)
+	if !ok || errorObject.Message != "no match arm for E.B" {
+		t.Errorf("expected a missing arm error, got %T (%+v)", evaluated, evaluated)
+	}
+}
+
+func TestForInLoops(t *testing.T) {
+	testCases := []IntegerTestCase{
+		{"let total = 0; for x in [1, 2, 3] { total = total + x }; total", 6},
+		{"let count = 0; for c in \"héllo\" { count = count + 1 }; count", 5},
+		{"let f = func() { for x in [1, 2, 3] { if (x == 2) { return x } } }; f()", 2},
+		{"for x in [4, 5] { x }", 5},
+	}
+
+	testIntegerCases(testCases, t)
+
+	evaluated := testEval("for x in 5 { x }")
+	err, ok := evaluated.(*object.Error)
+	if !ok || err.Message != "INTEGER is not iterable" {
+		t.Errorf("expected an iteration error, got %T (%+v)", evaluated, evaluated)
+	}
+}
+
+func TestOperatorOverloading(t *testing.T) {
+	declaration := `struct Vec {
+	x, y
+	func __add__(other) { Vec(self.x + other.x, self.y + other.y) }
+	func __sub__(other) { Vec(self.x - other.x, self.y - other.y) }
+	func __mul__(k) { Vec(self.x * k, self.y * k) }
+	func __eq__(other) { self.x == other.x }
+	func __lt__(other) { self.x < other.x }
+	func __index__(i) { if (i == 0) { self.x } else { self.y } }
+	func __len__() { 2 }
+	func __iter__() { [self.x, self.y] }
+	func __str__() { "<" + str(self.x) + ", " + str(self.y) + ">" }
+};
+`
+	testCases := []IntegerTestCase{
+		{"(Vec(1, 2) + Vec(3, 4)).y", 6},
+		{"(Vec(5, 5) - Vec(3, 4)).x", 2},
+		{"(Vec(1, 2) * 3).y", 6},
+		{"Vec(7, 8)[1]", 8},
+		{"len(Vec(7, 8))", 2},
+		{"let total = 0; for v in Vec(7, 8) { total = total + v }; total", 15},
+		{"if (Vec(1, 2) == Vec(1, 3)) { 1 } else { 0 }", 1},
+		{"if (Vec(1, 2) != Vec(1, 3)) { 1 } else { 0 }", 0},
+		{"if (Vec(1, 2) < Vec(2, 0)) { 1 } else { 0 }", 1},
+		{"if (Vec(3, 2) > Vec(2, 0)) { 1 } else { 0 }", 1},
+		{"if (Vec(1, 2) > Vec(2, 0)) { 1 } else { 0 }", 0},
+	}
+
+	for _, testCase := range testCases {
+		testIntegerObject(t, testCase.expected, testEval(declaration+testCase.input))
+	}
+
+	evaluated := testEval(declaration + "str(Vec(1, 2) + Vec(1, 1))")
+	str, ok := evaluated.(*object.String)
+	if !ok || str.Value != "<2, 3>" {
+		t.Errorf("wrong string conversion, got %T (%+v)", evaluated, evaluated)
+	}
+
+	errorCases := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"struct P { x }; P(1) + P(2)", "unknown operator: INSTANCE + INSTANCE"},
+		{"struct P { x; func __len__() { \"no\" } }; len(P(1))", "__len__ must return INTEGER, got STRING"},
+		{"struct P { x; func __str__() { 1 } }; str(P(1))", "__str__ must return STRING, got INTEGER"},
+		{"struct P { x; func __len__() { } }; len(P(1))", "__len__ must return INTEGER, got NULL"},
+		{"struct P { x; func __eq__(o) { 5 } }; P(1) == P(2)", "__eq__ must return BOOLEAN, got INTEGER"},
+		{"struct P { x; func __eq__(o) { 5 } }; P(1) != P(2)", "__eq__ must return BOOLEAN, got INTEGER"},
+		{"struct P { x; func __lt__(o) { \"no\" } }; P(1) < P(2)", "__lt__ must return BOOLEAN, got STRING"},
+		{"struct P { x; func __lt__(o) { } }; P(1) > P(2)", "__lt__ must return BOOLEAN, got NULL"},
+		{"struct P { x; func __gt__(o) { 1 } }; P(1) > P(2)", "__gt__ must return BOOLEAN, got INTEGER"},
+		{"struct P { x; func __add__(o) { missing } }; P(1) + P(2)", "identifier not found: missing"},
+	}
+
+	for _, testCase := range errorCases {
+		evaluated := testEval(testCase.input)
+		errorObject, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("No error object returned, got %T (%+v)", evaluated, evaluated)
+			continue
+		}
+		if errorObject.Message != testCase.expectedMessage {
+			t.Errorf("Wrong error message, expected %q, got %q", testCase.expectedMessage, errorObject.Message)
+		}
+	}
+}
+
+func TestGenerators(t *testing.T) {
+	testCases := []TestCase{
+		{"let gen = func() { yield 1; yield 2; yield 3 }; list(gen())", []int{1, 2, 3}},
+		{"let naturals = func() { let n = 0; for i in count() { yield n; n = n + 1 } }; list(take(naturals(), 4))", []int{0, 1, 2, 3}},
+		{"let evens = func(xs) { for x in xs { if (x / 2 * 2 == x) { yield x } } }; list(evens([1, 2, 3, 4]))", []int{2, 4}},
+		{"let it = func() { yield 5; yield 6 }(); next(it); next(it)", 6},
+		{"let it = func() { yield 5 }(); next(it); next(it)", nil},
+		{"let total = 0; for x in func() { yield 1; yield 2 }() { total = total + x }; total", 3},
+		{"let early = func() { yield 1; return 9; yield 2 }; list(early())", []int{1}},
+		{`struct Pair { a, b
+	func __iter__() { yield self.a; yield self.b }
+};
+list(Pair(7, 8))`, []int{7, 8}},
+	}
+
+	testIterableCases(t, testCases)
+
+	evaluated := testEval("let bad = func() { yield 1; missing }; list(bad())")
+	err, ok := evaluated.(*object.Error)
+	if !ok || err.Message != "identifier not found: missing" {
+		t.Errorf("expected the generator's error, got %T (%+v)", evaluated, evaluated)
+	}
+}
+
+func TestGeneratorsSurviveGarbageCollection(t *testing.T) {
+	// map keeps only the generator's Next, collecting garbage while the
+	// pipeline runs must not stop the generator under it
+	env := object.NewEnvironment()
+	env.Set("collect", &object.Builtin{Function: func(env *object.Environment, args ...object.Object) object.Object {
+		runtime.GC()
+		return NULL
+	}})
+
+	input := `let naturals = func() { let n = 0; for i in count() { yield n; n = n + 1 } };
+len(list(take(map(naturals(), func(x) { collect(); x }), 200)))`
+	program := testParse(input)
+
+	testIntegerObject(t, 200, Eval(program, env))
+
+	// while generators dropped half way are stopped
+	before := runtime.NumGoroutine()
+	testEval(`let naturals = func() { let n = 0; for i in count() { yield n; n = n + 1 } };
+for i in range(50) { next(naturals()) }`)
+	for attempt := 0; attempt < 100 && runtime.NumGoroutine() > before; attempt++ {
+		runtime.GC()
+		time.Sleep(time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("dropped generators should be stopped, %d goroutines before and %d after", before, after)
+	}
+}
+
+func TestLazyIterators(t *testing.T) {
+	testCases := []TestCase{
+		{"list(range(4))", []int{0, 1, 2, 3}},
+		{"list(range(2, 5))", []int{2, 3, 4}},
+		{"list(range(5, 0, -2))", []int{5, 3, 1}},
+		{"list(take(count(10, 5), 3))", []int{10, 15, 20}},
+		{"map([1, 2, 3], func(x) { x * x })", []int{1, 4, 9}},
+		{"filter([1, 2, 3, 4], func(x) { x > 2 })", []int{3, 4}},
+		{"take([1, 2, 3], 2)", []int{1, 2}},
+		{"list(take(filter(map(count(), func(x) { x * 3 }), func(x) { x > 10 }), 3))", []int{12, 15, 18}},
+		{"let calls = 0; let squares = map(count(), func(x) { calls = calls + 1; x * x }); list(take(squares, 3)); calls", 3},
+		{"let total = 0; for i = range(4) { total = total + i }; total", 6},
+		{"next(range(0))", nil},
+	}
+
+	testIterableCases(t, testCases)
+
+	if evaluated := testEval("map(count(), func(x) { x })"); evaluated.Type() != object.ITERATOR {
+		t.Errorf("mapping an iterator should stay lazy, got %T (%+v)", evaluated, evaluated)
+	}
+
+	errorCases := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"range(1, 2, 0)", "step of `range` must not be 0"},
+		{"list(map([1], func(x) { x + true }))", "type mismatch: INTEGER + BOOLEAN"},
+		{"list(take(map(count(), func(x) { missing }), 2))", "identifier not found: missing"},
+		{"next([1])", "argument to `next` must be ITERATOR, got ARRAY"},
+	}
+
+	for _, testCase := range errorCases {
+		evaluated := testEval(testCase.input)
+		errorObject, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("No error object returned, got %T (%+v)", evaluated, evaluated)
+			continue
+		}
+		if errorObject.Message != testCase.expectedMessage {
+			t.Errorf("Wrong error message, expected %q, got %q", testCase.expectedMessage, errorObject.Message)
+		}
+	}
+}
+
+func TestArbitraryPrecisionIntegers(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"9223372036854775807 + 1", "9223372036854775808"},
+		{"-9223372036854775807 - 2", "-9223372036854775809"},
+		{"4294967296 * 4294967296", "18446744073709551616"},
+		{"-9223372036854775807 - 1", "-9223372036854775808"},
+		{"-(-9223372036854775807 - 1)", "9223372036854775808"},
+		{"(-9223372036854775807 - 1) / -1", "9223372036854775808"},
+		{"(-9223372036854775807 - 1) * -1", "9223372036854775808"},
+		{"123456789012345678901234567890", "123456789012345678901234567890"},
+		{"123456789012345678901234567890 / 10", "12345678901234567890123456789"},
+		{"-123456789012345678901234567890 / 7", "-17636684144620811271604938270"},
+		{"let f = func(n) { if (n == 0) { 1 } else { n * f(n - 1) } }; f(25)", "15511210043330985984000000"},
+	}
+
+	for _, testCase := range testCases {
+		evaluated := testEval(testCase.input)
+		if isError(evaluated) || evaluated.Inspect() != testCase.expected {
+			t.Errorf("%s should be %s, got %T (%+v)", testCase.input, testCase.expected, evaluated, evaluated)
+		}
 	}
+
+	// results that fit in 64 bits again are plain integers
+	evaluated := testEval("(9223372036854775807 + 10) - 20")
+	testIntegerObject(t, 9223372036854775797, evaluated)
+
+	boolCases := []struct {
+		input    string
+		expected bool
+	}{
+		{"9223372036854775808 > 9223372036854775807", true},
+		{"1 < 9223372036854775808", true},
+		{"9223372036854775807 + 1 == 9223372036854775808", true},
+		{"-9223372036854775809 != -9223372036854775809", false},
+	}
+
+	for _, testCase := range boolCases {
+		testBoolObject(t, testCase.expected, testEval(testCase.input))
+	}
+
+	errorCases := []string{"1 / 0", "99999999999999999999 / 0", "let zero = 0; 5 / zero"}
+	for _, input := range errorCases {
+		errorObject, ok := testEval(input).(*object.Error)
+		if !ok || errorObject.Message != "division by zero" || errorObject.Kind != object.RUNTIME_ERROR {
+			t.Errorf("expected a division by zero error for %q, got %+v", input, errorObject)
+		}
+	}
+}
+
+func TestDecimalsAndRationals(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"0.1 + 0.2", "0.3"},
+		{"19.99 * 3", "59.97"},
+		{"2.50 + 1", "3.50"},
+		{"1.5 * 1.5", "2.25"},
+		{"10.00 / 4", "2.50"},
+		{"1 / 3.0", "0.33333333333333333333"},
+		{"-0.05 - 1", "-1.05"},
+		{"decimal(\"12.345\")", "12.345"},
+		{"decimal(rational(1, 8))", "0.125"},
+		{"rational(1, 3) + rational(1, 6)", "1/2"},
+		{"rational(2, 4) * 2", "1"},
+		{"rational(1, 3) + 0.5", "5/6"},
+		{"rational(\"3/9\")", "1/3"},
+		{"-rational(1, 3)", "-1/3"},
+		{"numerator(rational(6, 8))", "3"},
+		{"denominator(0.25)", "4"},
+		{"round(2.345, 2)", "2.34"},
+		{"round(2.355, 2)", "2.36"},
+		{"round(2.345, 2, \"half_up\")", "2.35"},
+		{"round(-2.341, 1, \"floor\")", "-2.4"},
+		{"round(-2.341, 1, \"ceiling\")", "-2.3"},
+		{"round(2.301, 1, \"up\")", "2.4"},
+		{"round(rational(2, 3), 3)", "0.667"},
+		{"round(7, 2)", "7.00"},
+		{"str(round(1.5, 4))", "1.5000"},
+	}
+
+	for _, testCase := range testCases {
+		evaluated := testEval(testCase.input)
+		if isError(evaluated) || evaluated.Inspect() != testCase.expected {
+			t.Errorf("%s should be %s, got %T (%+v)", testCase.input, testCase.expected, evaluated, evaluated)
+		}
+	}
+
+	boolCases := []struct {
+		input    string
+		expected bool
+	}{
+		{"1.0 == 1", true},
+		{"1.10 == 1.1", true},
+		{"0.5 == rational(1, 2)", true},
+		{"0.3 < rational(1, 3)", true},
+		{"2.5 > 3", false},
+	}
+
+	for _, testCase := range boolCases {
+		testBoolObject(t, testCase.expected, testEval(testCase.input))
+	}
+
+	runtime := object.NewRuntime()
+	runtime.DecimalPlaces = 2
+	runtime.Rounding = object.RoundDown
+	program := testParse("2 / 3.0")
+	if evaluated := Eval(program, object.NewEnvironmentWithRuntime(runtime)); evaluated.Inspect() != "0.66" {
+		t.Errorf("division should follow the runtime's places and rounding, got %s", evaluated.Inspect())
+	}
+
+	errorCases := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"1.5 / 0", "division by zero"},
+		{"rational(1, 0)", "division by zero"},
+		{"rational(1, 2) / 0.0", "division by zero"},
+		{"decimal(\"1.2.3\")", "could not parse \"1.2.3\" as decimal"},
+		{"round(1.25, 1, \"sideways\")", "unknown rounding mode \"sideways\""},
+		{"round(decimal(\"1.5\"), 2000000000)", "places given to `round` must be at most 1048576, got 2000000000"},
+		{"1.5 + \"a\"", "type mismatch: DECIMAL + STRING"},
+	}
+
+	for _, testCase := range errorCases {
+		errorObject, ok := testEval(testCase.input).(*object.Error)
+		if !ok {
+			t.Errorf("No error object returned for %q", testCase.input)
+			continue
+		}
+		if errorObject.Message != testCase.expectedMessage {
+			t.Errorf("Wrong error message, expected %q, got %q", testCase.expectedMessage, errorObject.Message)
+		}
+	}
+}
+
+func TestConcurrency(t *testing.T) {
+	testCases := []TestCase{
+		{"let square = func(x) { x * x }; wait(spawn square(7))", 49},
+		{"let square = func(x) { x * x }; wait([spawn square(1), spawn square(2), spawn square(3)])", []int{1, 4, 9}},
+		{"let c = chan(); spawn func() { send(c, 1); send(c, 2); close(c) }(); list(c)", []int{1, 2}},
+		{"let c = chan(1); send(c, 5); recv(c)", 5},
+		{"let c = chan(); close(c); recv(c)", nil},
+		{`let work = chan();
+let results = chan(4);
+let worker = func() { for n in work { send(results, n * 10) } };
+let workers = [spawn worker(), spawn worker(), spawn worker()];
+for i in range(1, 5) { send(work, i) };
+close(work);
+wait(workers);
+close(results);
+let total = 0;
+for r in results { total = total + r };
+total`, 100},
+		{"let a = chan(); let b = chan(1); send(b, 3); select([a, b])", []int{1, 3}},
+		{"let counter = func(n) { if (n == 0) { return 0 }; counter(n - 1) + 1 }; wait(spawn counter(500))", 500},
+	}
+
+	testIterableCases(t, testCases)
+
+	errorCases := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"wait(spawn func() { missing }())", "identifier not found: missing"},
+		{"let failed = spawn func() { missing }(); let waiter = func() { wait(failed) }; wait([spawn waiter(), spawn waiter()])", "identifier not found: missing"},
+		{"let c = chan(); close(c); send(c, 1)", "send on closed channel"},
+		{"let c = chan(); close(c); close(c)", "close of closed channel"},
+		{"spawn missing()", "identifier not found: missing"},
+		{"wait(1)", "argument to `wait` must be TASK or an array of them, got INTEGER"},
+		{"select([])", "argument to `select` must be a non-empty array of channels"},
+	}
+
+	for _, testCase := range errorCases {
+		evaluated := testEval(testCase.input)
+		errorObject, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("No error object returned, got %T (%+v)", evaluated, evaluated)
+			continue
+		}
+		if errorObject.Message != testCase.expectedMessage {
+			t.Errorf("Wrong error message, expected %q, got %q", testCase.expectedMessage, errorObject.Message)
+		}
+	}
+}
+
+func TestAsyncAwait(t *testing.T) {
+	testCases := []TestCase{
+		{"let double = async func(x) { x * 2 }; await double(21)", 42},
+		{"let slow = async func(x) { await sleep(50); x }; await all([slow(1), slow(2), 3])", []int{1, 2, 3}},
+		{`let order = [];
+let after = async func(ms, n) { await sleep(ms); order = push(order, n) };
+await all([after(30, 3), after(10, 1), after(20, 2)]);
+order`, []int{1, 2, 3}},
+		{"let after = async func(ms, n) { await sleep(ms); n }; await race([after(20, 1), after(10, 2)])", 2},
+		{"let chain = async func(n) { if (n == 0) { return 0 }; 1 + await chain(n - 1) }; await chain(50)", 50},
+		{"let order = []; let f = async func() { order = push(order, 1); await sleep(0); order = push(order, 3) }; f(); order = push(order, 2); order", []int{1, 2}},
+		{"let order = []; let f = async func() { await sleep(0); order = push(order, 2) }; f(); await sleep(5); order", []int{2}},
+		{"let order = []; let f = async func() { await sleep(0); order = push(order, 2) }; eval(\"f()\"); order = push(order, 1); order", []int{1}},
+		{"let safe = async func() { try { await async func() { throw 7 }() } catch (e) { e.value } }; await safe()", 7},
+		{"await 5", 5},
+		{"await all([])", []int{}},
+	}
+
+	for _, testCase := range testCases {
+		evaluated := testEvalVirtual(testCase.input, object.NewRuntime())
+		switch expected := testCase.expected.(type) {
+		case int:
+			testIntegerObject(t, int64(expected), evaluated)
+		case []int:
+			array, ok := evaluated.(*object.Array)
+			if !ok || len(array.Elements) != len(expected) {
+				t.Errorf("expected %v for %q, got %T (%+v)", expected, testCase.input, evaluated, evaluated)
+				continue
+			}
+			for index, element := range expected {
+				testIntegerObject(t, int64(element), array.Elements[index])
+			}
+		}
+	}
+
+	errorCases := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"await async func() { missing }()", "identifier not found: missing"},
+		{"let f = async func() { await sleep(5); throw \"late\" }; await all([f(), sleep(100)])", "late"},
+		{"await race([sleep(10), async func() { throw \"first\" }()])", "first"},
+		{"await async func() { await promise }()", "identifier not found: promise"},
+		{"sleep(-1)", "argument to `sleep` must not be negative, got -1"},
+		{"race([])", "argument to `race` must be a non-empty array"},
+	}
+
+	for _, testCase := range errorCases {
+		evaluated := testEvalVirtual(testCase.input, object.NewRuntime())
+		errorObject, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("No error object returned for %q, got %T (%+v)", testCase.input, evaluated, evaluated)
+			continue
+		}
+		if errorObject.Message != testCase.expectedMessage {
+			t.Errorf("Wrong error message, expected %q, got %q", testCase.expectedMessage, errorObject.Message)
+		}
+	}
+}
+
+func TestEventLoopClock(t *testing.T) {
+	input := "let wait = async func() { await sleep(60000); await sleep(60000); 1 }; await wait()"
+
+	runtime := object.NewRuntime()
+	start := time.Now()
+	testIntegerObject(t, 1, testEvalVirtual(input, runtime))
+	if elapsed := runtime.EventLoop().Now(); elapsed != 2*time.Minute {
+		t.Errorf("virtual clock should have advanced by 2m, got %s", elapsed)
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("virtual clock waited in real time")
+	}
+
+	start = time.Now()
+	testNullObject(t, testEval("await sleep(20)"))
+	if time.Since(start) < 20*time.Millisecond {
+		t.Errorf("real clock returned before the timer was due")
+	}
+}
+
+// testEvalVirtual evaluates input on the given runtime with a virtual clock.
+func testEvalVirtual(input string, runtime *object.Runtime) object.Object {
+	runtime.VirtualClock = true
+	program := testParse(input)
+
+	return Eval(program, object.NewEnvironmentWithRuntime(runtime))
+}
+
+func TestQuoteUnquote(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"quote(5)", "5"},
+		{"quote(5 + 8)", "(5 + 8)"},
+		{"quote(foobar)", "foobar"},
+		{"quote(unquote(4 + 4))", "8"},
+		{"quote(8 + unquote(4 + 4))", "(8 + 8)"},
+		{"let foobar = 8; quote(unquote(foobar))", "8"},
+		{"quote(unquote(true == false))", "false"},
+		{"quote(unquote(quote(4 + 4)))", "(4 + 4)"},
+		{"let quoted = quote(4 + 4); quote(unquote(4 + 4) + unquote(quoted))", "(8 + (4 + 4))"},
+		{"quote(unquote(\"a\" + \"b\"))", "ab"},
+		{"quote(unquote(1.25 * 2))", "2.50"},
+		{"let q = func() { quote(x + 1) }; q()", "(x + 1)"},
+		{"let f = func(x) { quote(unquote(x) + 1) }; f(1); f(2)", "(2 + 1)"},
+	}
+
+	for _, testCase := range testCases {
+		evaluated := testEval(testCase.input)
+		quote, ok := evaluated.(*object.Quote)
+		if !ok {
+			t.Errorf("expected *object.Quote for %q, got %T (%+v)", testCase.input, evaluated, evaluated)
+			continue
+		}
+		if quote.Node.String() != testCase.expected {
+			t.Errorf("quoted node is wrong, expected %q, got %q", testCase.expected, quote.Node.String())
+		}
+	}
+
+	errorObject, ok := testEval("quote(unquote([1]))").(*object.Error)
+	if !ok || errorObject.Message != "cannot unquote ARRAY into syntax" {
+		t.Errorf("expected an unquote error, got %+v", errorObject)
+	}
+}
+
+func TestDefineMacros(t *testing.T) {
+	input := `let number = 1;
+let function = func(x, y) { x + y };
+let mymacro = macro(x, y) { x + y; };`
+
+	env := object.NewEnvironment()
+	program := testParse(input)
+	DefineMacros(program, env)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("wrong number of statements, got %d", len(program.Statements))
+	}
+	if _, ok := env.Get("number"); ok {
+		t.Errorf("number should not be defined")
+	}
+	value, ok := env.Get("mymacro")
+	if !ok {
+		t.Fatalf("macro not in environment")
+	}
+	macro, ok := value.(*object.Macro)
+	if !ok {
+		t.Fatalf("object is not *object.Macro, got %T", value)
+	}
+	if len(macro.Parameters) != 2 || macro.Body.String() != "(x + y)" {
+		t.Errorf("macro is wrong, got %s", macro.Inspect())
+	}
+}
+
+func TestExpandMacros(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"let infix = macro() { quote(1 + 2) }; infix()", "(1 + 2)"},
+		{"let reverse = macro(a, b) { quote(unquote(b) - unquote(a)) }; reverse(2 + 2, 10 - 5)", "(10 - 5) - (2 + 2)"},
+		{`let unless = macro(condition, consequence, alternative) {
+	quote(if (!(unquote(condition))) { unquote(consequence) } else { unquote(alternative) })
+};
+unless(10 > 5, print("not greater"), print("greater"))`,
+			`if (!(10 > 5)) { print("not greater") } else { print("greater") }`},
+		{`let unless = macro(condition, consequence, alternative) {
+	quote(if (!(unquote(condition))) { unquote(consequence) } else { unquote(alternative) })
+};
+unless(1 > 2, 10, 20); unless(1 < 2, 30, 40)`,
+			`if (!(1 > 2)) { 10 } else { 20 }; if (!(1 < 2)) { 30 } else { 40 }`},
+		{"let twice = macro(x) { quote(unquote(x) + unquote(x)) }; [twice(1), twice(2)]", "[(1 + 1), (2 + 2)]"},
+	}
+
+	for _, testCase := range testCases {
+		expected := testParse(testCase.expected)
+		program := testParse(testCase.input)
+
+		env := object.NewEnvironment()
+		DefineMacros(program, env)
+		expanded, err := ExpandMacros(program, env)
+		if err != nil {
+			t.Errorf("unexpected error expanding %q: %s", testCase.input, err.Message)
+			continue
+		}
+		if expanded.String() != expected.String() {
+			t.Errorf("not equal, expected %q, got %q", expected.String(), expanded.String())
+		}
+	}
+
+	errorCases := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"let m = macro(x) { 1 }; m(2)", "macro m must return QUOTE, got INTEGER"},
+		{"let m = macro(x) { quote(x) }; m()", "macro m expects 1 arguments, got 0"},
+		{"let m = macro(x) { missing }; m(1)", "identifier not found: missing"},
+	}
+
+	for _, testCase := range errorCases {
+		program := testParse(testCase.input)
+		env := object.NewEnvironment()
+		DefineMacros(program, env)
+		_, err := ExpandMacros(program, env)
+		if err == nil || err.Message != testCase.expectedMessage {
+			t.Errorf("expected %q expanding %q, got %+v", testCase.expectedMessage, testCase.input, err)
+		}
+	}
+}
+
+func TestEvalAndReflection(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{`eval("1 + 2")`, "3"},
+		{`let x = 10; eval("x * 2")`, "20"},
+		{`eval("let y = 5"); y`, "5"},
+		{`let f = func() { let local = 7; eval("local + 1") }; f()`, "8"},
+		{`let s = new_scope(); eval("let a = 1", s); eval("a + 1", s)`, "2"},
+		{`let counter = func() { let n = 41; func() { n } }(); eval("n + 1", scope(counter))`, "42"},
+		{`eval("let twice = macro(x) { quote(unquote(x) + unquote(x)) }; twice(4)")`, "8"},
+		{`eval("")`, "null"},
+		{`type(1)`, "INTEGER"},
+		{`type("a")`, "STRING"},
+		{`type(func() {})`, "FUNCTION"},
+		{`type(len)`, "BUILTIN"},
+		{`params(func(a, bc) { a })`, `[a, bc]`},
+		{`eval("let m = macro(x) { x }; params(m)")`, `[x]`},
+		{`is_builtin(len)`, "true"},
+		{`is_builtin(func() {})`, "false"},
+		{`let a = 1; let b = 2; bindings()`, "[a, b]"},
+		{`let a = 1; let f = func(p) { let q = 2; bindings() }; f(0)`, "[a, f, p, q]"},
+		{`bindings(new_scope())`, "[]"},
+	}
+
+	for _, testCase := range testCases {
+		evaluated := testEval(testCase.input)
+		if evaluated == nil || evaluated.Inspect() != testCase.expected {
+			t.Errorf("%s should be %s, got %T (%+v)", testCase.input, testCase.expected, evaluated, evaluated)
+		}
+	}
+
+	errorCases := []struct {
+		input           string
+		expectedKind    string
+		expectedMessage string
+	}{
+		{`eval("let = 1")`, object.SYNTAX_ERROR, "expected next token to be IDENTIFIER, got = instead; no prefix parse function for = found"},
+		{`eval("missing")`, object.NAME_ERROR, "identifier not found: missing"},
+		{`eval("1", 2)`, object.TYPE_ERROR, "second argument to `eval` must be SCOPE, got INTEGER"},
+		{`let s = new_scope(); let hidden = 1; eval("hidden", s)`, object.NAME_ERROR, "identifier not found: hidden"},
+		{`params(len)`, object.TYPE_ERROR, "argument to `params` must be FUNCTION or MACRO, got BUILTIN"},
+	}
+
+	for _, testCase := range errorCases {
+		errorObject, ok := testEval(testCase.input).(*object.Error)
+		if !ok {
+			t.Errorf("No error object returned for %q", testCase.input)
+			continue
+		}
+		if errorObject.Kind != testCase.expectedKind || errorObject.Message != testCase.expectedMessage {
+			t.Errorf("Wrong error, expected %s %q, got %s %q", testCase.expectedKind, testCase.expectedMessage,
+				errorObject.Kind, errorObject.Message)
+		}
+	}
+
+	caught := testEval(`try { eval("(") } catch (e) { e.kind }`)
+	if caught.Inspect() != object.SYNTAX_ERROR {
+		t.Errorf("syntax errors from eval should be catchable, got %s", caught.Inspect())
+	}
+}
+
+func testIterableCases(t *testing.T, testCases []TestCase) {
+	for _, testCase := range testCases {
+		evaluated := testEval(testCase.input)
+		switch expected := testCase.expected.(type) {
+		case int:
+			testIntegerObject(t, int64(expected), evaluated)
+		case []int:
+			array, ok := evaluated.(*object.Array)
+			if !ok {
+				t.Errorf("object is not an array for %q, got %T (%+v)", testCase.input, evaluated, evaluated)
+				continue
+			}
+			if len(array.Elements) != len(expected) {
+				t.Errorf("wrong number of elements for %q, expected %d, got %d", testCase.input, len(expected), len(array.Elements))
+				continue
+			}
+			for index, element := range expected {
+				testIntegerObject(t, int64(element), array.Elements[index])
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestHashes(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{`{"b": 1, "a": 2, 3: true, false: "no"}`, "{b: 1, a: 2, 3: true, false: no}"},
+		{`{}`, "{}"},
+		{`{"a": 1, "a": 2}`, "{a: 2}"},
+		{`let key = "k"; {key: 1 + 1}[key]`, "2"},
+		{`{1: "one"}[1]`, "one"},
+		{`{true: "yes"}[true]`, "yes"},
+		{`{"a": 1}["missing"]`, "null"},
+		{`len({"a": 1, "b": 2})`, "2"},
+		{`list({"x": 1, "y": 2})`, "[x, y]"},
+		{`let total = 0; let h = {"x": 1, "y": 2}; for key in h { total = total + h[key] }; total`, "3"},
+		{`type({})`, "HASH"},
+	}
+
+	for _, testCase := range testCases {
+		evaluated := testEval(testCase.input)
+		if evaluated == nil || evaluated.Inspect() != testCase.expected {
+			t.Errorf("%s should be %s, got %T (%+v)", testCase.input, testCase.expected, evaluated, evaluated)
+		}
+	}
+
+	errorCases := []struct {
+		input    string
+		expected string
+	}{
+		{`{[1]: 2}`, "unusable as hash key: ARRAY"},
+		{`{"a": 1}[func() {}]`, "unusable as hash key: FUNCTION"},
+		{`{"a": missing}`, "identifier not found: missing"},
+	}
+
+	for _, testCase := range errorCases {
+		testErrorObject(t, testEval(testCase.input), testCase.expected)
+	}
+}
+
+func TestCollectionBuiltins(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{`map([1, 2, 3], func(x) { x * 2 })`, "[2, 4, 6]"},
+		{`filter([1, 2, 3, 4], func(x) { x > 2 })`, "[3, 4]"},
+		{`reduce([1, 2, 3], 0, func(total, x) { total + x })`, "6"},
+		{`reduce([], "empty", func(total, x) { total + x })`, "empty"},
+		{`reduce(range(4), [], push)`, "[0, 1, 2, 3]"},
+		{`any([1, 2, 3], func(x) { x > 2 })`, "true"},
+		{`any([], func(x) { true })`, "false"},
+		{`every([1, 2, 3], func(x) { x > 0 })`, "true"},
+		{`every([1, 2, 3], func(x) { x > 1 })`, "false"},
+		{`every([], func(x) { false })`, "true"},
+		{`all([1, 2, 3], func(x) { x > 0 })`, "true"},
+		{`all(range(1, 4), func(x) { x > 1 })`, "false"},
+		{`find([1, 5, 7], func(x) { x > 4 })`, "5"},
+		{`find([1], func(x) { x > 4 })`, "null"},
+		{`find(count(), func(x) { x * x > 50 })`, "8"},
+		{`sort([3, 1, 2])`, "[1, 2, 3]"},
+		{`sort(["pear", "apple", "fig"])`, "[apple, fig, pear]"},
+		{`sort([1, 2.5, rational(1, 2)])`, "[1/2, 1, 2.5]"},
+		{`sort([3, 1, 2], func(a, b) { a > b })`, "[3, 2, 1]"},
+		{`sort([[2, "b"], [1, "a"], [2, "a"]], func(a, b) { a[0] < b[0] })`, "[[1, a], [2, b], [2, a]]"},
+		{`let xs = [2, 1]; sort(xs); xs`, "[2, 1]"},
+		{`zip([1, 2, 3], ["a", "b"])`, "[[1, a], [2, b]]"},
+		{`zip(count(), ["a", "b"], [true, false])`, "[[0, a, true], [1, b, false]]"},
+		{`zip([])`, "[]"},
+		{`flatten([[1, 2], 3, [], [[4]]])`, "[1, 2, 3, [4]]"},
+		{`group_by([1, 2, 3, 4, 5], func(x) { x > 2 })`, "{false: [1, 2], true: [3, 4, 5]}"},
+		{`group_by(["ant", "bee", "cat", "ape"], func(w) { first(list(w)) })`, "{a: [ant, ape], b: [bee], c: [cat]}"},
+		{`"apple" < "banana"`, "true"},
+		{`"b" > "c"`, "false"},
+	}
+
+	for _, testCase := range testCases {
+		evaluated := testEval(testCase.input)
+		if evaluated == nil || evaluated.Inspect() != testCase.expected {
+			t.Errorf("%s should be %s, got %T (%+v)", testCase.input, testCase.expected, evaluated, evaluated)
+		}
+	}
+
+	// errors from the function given to a builtin stop it and come back out
+	errorCases := []struct {
+		input    string
+		expected string
+	}{
+		{`map([1, 2], func(x) { x + "a" })`, "type mismatch: INTEGER + STRING"},
+		{`filter([1], func(x) { missing })`, "identifier not found: missing"},
+		{`reduce([1, 2], 0, func(total, x) { throw "stop" })`, "stop"},
+		{`reduce([1], 0)`, "invalid number of arguments to `reduce`, expected 3, got 2"},
+		{`any([1], func(x) { len(x) })`, "argument to `len` not supported, got INTEGER"},
+		{`every([1], func(x) { 1 / 0 })`, "division by zero"},
+		{`every([1])`, "invalid number of arguments to `every`, expected 2, got 1"},
+		{`all([1], func(x) { 1 / 0 })`, "division by zero"},
+		{`all([1], func(x) { true }, 3)`, "invalid number of arguments to `all`, expected 1 or 2, got 3"},
+		{`find(5, func(x) { true })`, "INTEGER is not iterable"},
+		{`sort([[1], [2]])`, "unknown operator: ARRAY < ARRAY"},
+		{`sort([2, 1], func(a, b) { a.x })`, "field access not supported: INTEGER.x"},
+		{`zip([1], 2)`, "INTEGER is not iterable"},
+		{`zip()`, "invalid number of arguments to `zip`, expected at least 1, got 0"},
+		{`group_by([1], func(x) { [x] })`, "unusable as hash key: ARRAY"},
+		{`group_by([1], 2)`, "not a function: INTEGER"},
+		{`map([1, 2], func(a, b) { a })`, "invalid number of arguments to <anonymous>, expected 2, got 1"},
+		{`reduce([1], 0, func(a) { a })`, "invalid number of arguments to <anonymous>, expected 1, got 2"},
+		{`let less = func(a) { true }; sort([2, 1], less)`, "invalid number of arguments to less, expected 1, got 2"},
+		{`filter([1], func() { true })`, "invalid number of arguments to <anonymous>, expected 0, got 1"},
+		{`let add = func(a, b) { a + b }; add(1)`, "invalid number of arguments to add, expected 2, got 1"},
+		{`let add = func(a, b) { a + b }; add(1, 2, 3)`, "invalid number of arguments to add, expected 2, got 3"},
+	}
+
+	for _, testCase := range errorCases {
+		testErrorObject(t, testEval(testCase.input), testCase.expected)
+	}
+
+	caught := testEval(`let message = ""; try { map([1], func(a, b) { a }) } catch (err) { message = err.message }; message`)
+	if caught == nil || caught.Inspect() != "invalid number of arguments to <anonymous>, expected 2, got 1" {
+		t.Errorf("a wrong arity callback should be catchable, got %+v", caught)
+	}
+}
+
+func TestStringsModule(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{`strings.split("a,b,,c", ",")`, `[a, b, , c]`},
+		{`strings.split("héllo", "")`, `[h, é, l, l, o]`},
+		{`strings.join(["a", "b", "c"], ", ")`, `a, b, c`},
+		{`strings.join([], "-")`, ``},
+		{`strings.trim("  padded   ")`, `padded`},
+		{`strings.trim("xxhixx", "x")`, `hi`},
+		{`strings.replace("a-b-c", "-", "+")`, `a+b+c`},
+		{`strings.replace("a-b-c", "-", "+", 1)`, `a+b-c`},
+		{`strings.contains("haystack", "st")`, `true`},
+		{`strings.contains("haystack", "needle")`, `false`},
+		{`strings.starts_with("plug", "pl")`, `true`},
+		{`strings.ends_with("plug", "pl")`, `false`},
+		{`strings.upper("héllo")`, `HÉLLO`},
+		{`strings.lower("ÉCOLE")`, `école`},
+		{`strings.repeat("ab", 3)`, `ababab`},
+		{`strings.repeat("ab", 0)`, ``},
+		{`strings.pad_left("7", 3, "0")`, `007`},
+		{`strings.pad_left("é", 3)`, `  é`},
+		{`strings.pad_right("ab", 4, "·")`, `ab··`},
+		{`strings.pad_right("long", 2)`, `long`},
+		{`strings.index_of("héllo", "l")`, `2`},
+		{`strings.index_of("abc", "z")`, `-1`},
+	}
+
+	for _, testCase := range testCases {
+		evaluated := testEval(`import "std/strings" as strings; ` + testCase.input)
+		if evaluated == nil || evaluated.Inspect() != testCase.expected {
+			t.Errorf("%s should be %q, got %T (%+v)", testCase.input, testCase.expected, evaluated, evaluated)
+		}
+	}
+
+	errorCases := []struct {
+		input    string
+		expected string
+	}{
+		{`strings.split("a")`, "invalid number of arguments to `split`, expected 2, got 1"},
+		{`strings.split(1, ",")`, "argument 1 to `split` must be STRING, got INTEGER"},
+		{`strings.join(["a", 1], "")`, "elements joined by `join` must be STRING, got INTEGER"},
+		{`strings.trim("a", "b", "c")`, "invalid number of arguments to `trim`, expected 1 to 2, got 3"},
+		{`strings.replace("a", "a", "b", "1")`, "argument 4 to `replace` must be INTEGER, got STRING"},
+		{`strings.repeat("a", -1)`, "count of `repeat` must not be negative, got -1"},
+		{`strings.repeat("ab", 4611686018427387904)`, "result of `repeat` would be longer than 268435456 bytes"},
+		{`strings.pad_left("a", 3000000000000)`, "result of `pad_left` would be longer than 268435456 bytes"},
+		{`strings.pad_right("a", 200000000, "é")`, "result of `pad_right` would be longer than 268435456 bytes"},
+		{`strings.pad_left("a", 3, "ab")`, "fill of `pad_left` must be a single character, got \"ab\""},
+		{`strings.pad_right("a", "3")`, "argument 2 to `pad_right` must be INTEGER, got STRING"},
+		{`strings.missing`, "module strings has no export missing"},
+	}
+
+	for _, testCase := range errorCases {
+		testErrorObject(t, testEval(`import "std/strings" as strings; `+testCase.input), testCase.expected)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{`format("no verbs")`, `no verbs`},
+		{`format("%d + %d = %d", 1, 2, 3)`, `1 + 2 = 3`},
+		{`format("%05d|%-4d|%x|%X|%o|%b", 42, 7, 255, 255, 8, 5)`, `00042|7   |ff|FF|10|101`},
+		{`format("%d", 123456789012345678901234567890)`, `123456789012345678901234567890`},
+		{`format("%c%c", 80, 233)`, `Pé`},
+		{`format("%.2f", 3.14159)`, `3.14`},
+		{`format("%.30f", 0.1)`, `0.100000000000000000000000000000`},
+		{`format("%.3f", rational(1, 3))`, `0.333`},
+		{`format("%8.1f|", 2)`, `     2.0|`},
+		{`format("%e", 1234.5)`, `1.234500e+03`},
+		{`format("%s and %v", [1, "a"], rational(1, 2))`, `[1, a] and 1/2`},
+		{`format("%q", "quoted")`, `"quoted"`},
+		{`format("%-6s|%6s", "é", "é")`, `é     |     é`},
+		{`format("%t", false)`, `false`},
+		{`format("100%%")`, `100%`},
+		{`struct P { x
+ func __str__() { "P" + str(self.x) }
+}
+		format("%s", P(1))`, `P1`},
+	}
+
+	for _, testCase := range testCases {
+		evaluated := testEval(testCase.input)
+		if evaluated == nil || evaluated.Inspect() != testCase.expected {
+			t.Errorf("%s should be %q, got %T (%+v)", testCase.input, testCase.expected, evaluated, evaluated)
+		}
+	}
+
+	errorCases := []struct {
+		input    string
+		expected string
+	}{
+		{`format()`, "invalid number of arguments to `format`, expected at least 1, got 0"},
+		{`format(1)`, "argument 1 to `format` must be STRING, got INTEGER"},
+		{`format("%d")`, "`format` has more verbs than the 0 values given"},
+		{`format("%d", 1, 2)`, "`format` has 1 verbs but was given 2 values"},
+		{`format("%d", "1")`, "%d in `format` needs an INTEGER, got STRING"},
+		{`format("%c", 12345678901234567890)`, "%c in `format` needs an INTEGER, got BIG_INT"},
+		{`format("%f", "1")`, "%f in `format` needs a number, got STRING"},
+		{`format("%t", 1)`, "%t in `format` needs a BOOLEAN, got INTEGER"},
+		{`format("%z", 1)`, "unknown verb %z in `format`"},
+		{`format("%5", 1)`, "`format` string ends in the middle of a verb"},
+	}
+
+	for _, testCase := range errorCases {
+		testErrorObject(t, testEval(testCase.input), testCase.expected)
+	}
+}
+
+func TestMathModule(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{`math.PI`, "3.14159265358979323846"},
+		{`math.E`, "2.71828182845904523536"},
+		{`math.abs(-3)`, "3"},
+		{`math.abs(-9223372036854775807 - 1)`, "9223372036854775808"},
+		{`math.abs(-2.50)`, "2.50"},
+		{`math.abs(rational(-1, 3))`, "1/3"},
+		{`math.abs(4)`, "4"},
+		{`math.min(3, 1.5, 2)`, "1.5"},
+		{`math.max([1, 7, rational(15, 2)])`, "15/2"},
+		{`math.max(range(5))`, "4"},
+		{`math.min(2, 2.0)`, "2"},
+		{`math.pow(2, 10)`, "1024"},
+		{`math.pow(2, 100)`, "1267650600228229401496703205376"},
+		{`math.pow(2, -2)`, "1/4"},
+		{`math.pow(1.5, 2)`, "2.25"},
+		{`math.pow(2.0, -1)`, "0.5"},
+		{`math.pow(rational(2, 3), -2)`, "9/4"},
+		{`math.pow(4, 0.5)`, "2"},
+		{`math.pow(-8, 3.0)`, "-512"},
+		{`math.pow(0, 0)`, "1"},
+		{`math.pow(1, 100000000000)`, "1"},
+		{`math.pow(-1, 100000000001)`, "-1"},
+		{`len(str(math.pow(3, 100000)))`, "47713"},
+		{`math.sqrt(2)`, "1.4142135623730950488"},
+		{`math.sqrt(16)`, "4"},
+		{`math.sqrt(2.25)`, "1.5"},
+		{`math.sqrt(rational(1, 4))`, "0.5"},
+		{`math.floor(-2.5)`, "-3"},
+		{`math.floor(rational(7, 2))`, "3"},
+		{`math.ceil(2.1)`, "3"},
+		{`math.ceil(-2.1)`, "-2"},
+		{`math.floor(5)`, "5"},
+		{`math.round(2.5)`, "2"},
+		{`math.round(3.5)`, "4"},
+		{`math.round(123456789012345678901.5)`, "123456789012345678902"},
+		{`math.sin(0)`, "0"},
+		{`math.cos(0)`, "1"},
+		{`math.sin(math.PI)`, "0.00000000000000012246"},
+		{`math.atan2(1, 1)`, "0.7853981633974483"},
+		{`math.asin(1)`, "1.5707963267948966"},
+		{`math.log(1)`, "0"},
+		{`math.log(8, 2)`, "3"},
+		{`math.log2(1024)`, "10"},
+		{`math.log10(1000)`, "3"},
+		{`math.exp(0)`, "1"},
+		{`math.gcd(12, -18)`, "6"},
+		{`math.gcd(0, 0)`, "0"},
+		{`math.gcd(2 * 9223372036854775807, 9223372036854775807)`, "9223372036854775807"},
+		{`math.lcm(4, 6)`, "12"},
+		{`math.lcm(0, 6)`, "0"},
+	}
+
+	for _, testCase := range testCases {
+		evaluated := testEval(`import "std/math" as math; ` + testCase.input)
+		if evaluated == nil || evaluated.Inspect() != testCase.expected {
+			t.Errorf("%s should be %s, got %T (%+v)", testCase.input, testCase.expected, evaluated, evaluated)
+		}
+	}
+
+	errorCases := []struct {
+		input           string
+		expectedKind    string
+		expectedMessage string
+	}{
+		{`math.sqrt(-1)`, object.DOMAIN_ERROR, "`sqrt` is only defined for numbers >= 0, got -1"},
+		{`math.log(0)`, object.DOMAIN_ERROR, "`log` is only defined for numbers > 0, got 0"},
+		{`math.log(8, 1)`, object.DOMAIN_ERROR, "base of `log` must be > 0 and not 1, got 1"},
+		{`math.log10(-5)`, object.DOMAIN_ERROR, "`log10` is only defined for numbers > 0, got -5"},
+		{`math.acos(1.5)`, object.DOMAIN_ERROR, "`acos` is only defined for numbers from -1 to 1, got 1.5"},
+		{`math.pow(0, -1)`, object.DOMAIN_ERROR, "`pow` of 0 to a negative power"},
+		{`math.pow(-8, 0.5)`, object.DOMAIN_ERROR, "`pow` of a negative number to a fractional power"},
+		{`math.pow(2, 99999999999999999999)`, object.DOMAIN_ERROR, "exponent of `pow` is too large, got 99999999999999999999"},
+		{`math.pow(2, 100000000000)`, object.DOMAIN_ERROR, "result of `pow` is too large, got 2 to the power of 100000000000"},
+		{`math.pow(rational(1, 3), -9223372036854775807 - 1)`, object.DOMAIN_ERROR, "result of `pow` is too large, got 1/3 to the power of -9223372036854775808"},
+		{`math.pow(0.5, 100000000000)`, object.DOMAIN_ERROR, "result of `pow` is too large, got 0.5 to the power of 100000000000"},
+		{`math.exp(1000)`, object.DOMAIN_ERROR, "result of `exp` is out of range"},
+		{`math.sqrt("4")`, object.TYPE_ERROR, "arguments to `sqrt` must be numbers, got STRING"},
+		{`math.gcd(1.5, 2)`, object.TYPE_ERROR, "arguments to `gcd` must be integers, got DECIMAL"},
+		{`math.min()`, object.ARGUMENT_ERROR, "`min` needs at least one number"},
+		{`math.max([])`, object.ARGUMENT_ERROR, "`max` needs at least one number"},
+		{`math.max(1, "2")`, object.TYPE_ERROR, "arguments to `max` must be numbers, got STRING"},
+		{`math.atan2(1)`, object.ARGUMENT_ERROR, "invalid number of arguments to `atan2`, expected 2, got 1"},
+	}
+
+	for _, testCase := range errorCases {
+		evaluated := testEval(`import "std/math" as math; ` + testCase.input)
+		err, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%s should fail, got %T (%+v)", testCase.input, evaluated, evaluated)
+			continue
+		}
+		if err.Kind != testCase.expectedKind || err.Message != testCase.expectedMessage {
+			t.Errorf("%s should fail with %s %q, got %s %q", testCase.input, testCase.expectedKind, testCase.expectedMessage, err.Kind, err.Message)
+		}
+	}
+
+	// results that are not exact follow the interpreter's decimal places
+	runtime := object.NewRuntime()
+	runtime.DecimalPlaces = 5
+	program := testParse(`import "std/math" as math; [math.sqrt(2), math.sin(1)]`)
+	evaluated := Eval(program, object.NewEnvironmentWithRuntime(runtime))
+	if evaluated.Inspect() != "[1.41421, 0.84147]" {
+		t.Errorf("results should have 5 places, got %s", evaluated.Inspect())
+	}
+}
+
+func TestRandomModule(t *testing.T) {
+	run := func(runtime *object.Runtime, input string) object.Object {
+		program := testParse(`import "std/random" as random; ` + input)
+		return Eval(program, object.NewEnvironmentWithRuntime(runtime))
+	}
+	draws := `[random.int(1, 100), random.choice(["a", "b", "c"]), random.shuffle([1, 2, 3, 4, 5]), random.sample([0, 1, 2, 3, 4, 5, 6, 7, 8, 9], 3)]`
+
+	// the same seed gives the same results, whatever other interpreters do
+	first, second := object.NewRuntime(), object.NewRuntime()
+	run(first, "random.seed(42)")
+	run(second, "random.seed(42)")
+	run(object.NewRuntime(), "random.seed(7); random.int(1, 10)")
+	drawn := run(first, draws)
+	if isError(drawn) {
+		t.Fatalf("drawing should succeed, got %s", drawn.Inspect())
+	}
+	expected := drawn.Inspect()
+	run(object.NewRuntime(), "random.int(1, 10)")
+	if got := run(second, draws).Inspect(); got != expected {
+		t.Errorf("seeded interpreters should agree, got %s and %s", expected, got)
+	}
+	if reseeded := run(first, "random.seed(42); "+draws).Inspect(); reseeded != expected {
+		t.Errorf("reseeding should repeat the results, got %s and %s", expected, reseeded)
+	}
+
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{`let ok = true; for i in range(200) { let n = random.int(-2, 2); if (n < -2) { ok = false }; if (n > 2) { ok = false } }; ok`, "true"},
+		{`random.int(5, 5)`, "5"},
+		{`let n = random.int(99999999999999999999, 99999999999999999999); n`, "99999999999999999999"},
+		{`random.choice([7])`, "7"},
+		{`sort(random.shuffle([3, 1, 2]))`, "[1, 2, 3]"},
+		{`let xs = [1, 2, 3]; random.shuffle(xs); xs`, "[1, 2, 3]"},
+		{`len(random.sample([1, 2, 3, 4], 2))`, "2"},
+		{`sort(random.sample([1, 2, 3], 3))`, "[1, 2, 3]"},
+		{`random.sample([1, 2], 0)`, "[]"},
+		{`random.seed(1)`, "null"},
+	}
+
+	for _, testCase := range testCases {
+		evaluated := run(object.NewRuntime(), testCase.input)
+		if evaluated == nil || evaluated.Inspect() != testCase.expected {
+			t.Errorf("%s should be %s, got %T (%+v)", testCase.input, testCase.expected, evaluated, evaluated)
+		}
+	}
+
+	errorCases := []struct {
+		input    string
+		expected string
+	}{
+		{`random.int(3, 1)`, "lower bound of `int` must not be above the upper bound, got 3 and 1"},
+		{`random.int(1, 2.5)`, "arguments to `int` must be integers, got DECIMAL"},
+		{`random.choice([])`, "cannot choose from an empty array"},
+		{`random.choice("abc")`, "argument to `choice` must be ARRAY, got STRING"},
+		{`random.sample([1, 2], 3)`, "cannot sample 3 elements from an array of 2"},
+		{`random.seed("a")`, "argument to `seed` must be INTEGER, got STRING"},
+	}
+
+	for _, testCase := range errorCases {
+		testErrorObject(t, run(object.NewRuntime(), testCase.input), testCase.expected)
+	}
+}
+
+func TestJSONModule(t *testing.T) {
+	// Plug strings have no escapes, so JSON text is bound to `text` instead
+	run := func(input, text string) object.Object {
+		program := testParse(`import "std/json" as json; ` + input)
+		env := object.NewEnvironment()
+		env.Set("text", &object.String{Value: text})
+		return Eval(program, env)
+	}
+
+	testCases := []struct {
+		input    string
+		text     string
+		expected string
+	}{
+		{`json.encode([1, "two", true, false, 2.50, 99999999999999999999])`, "", `[1,"two",true,false,2.50,99999999999999999999]`},
+		{`json.encode({"b": 1, "a": [{}, []]})`, "", `{"b":1,"a":[{},[]]}`},
+		{`json.encode(text)`, `<a & "b">`, `"<a & \"b\">"`},
+		{`json.encode(find([], func(x) { true }))`, "", `null`},
+		{"struct P { x, y }\njson.encode(P(1, [2]))", "", `{"x":1,"y":[2]}`},
+		{`json.encode({"a": [1, 2]}, 2)`, "", "{\n  \"a\": [\n    1,\n    2\n  ]\n}"},
+		{`json.encode([1], "X")`, "", "[\nX1\n]"},
+		{`json.encode([], 2)`, "", "[]"},
+		{`let xs = [1]; json.encode([xs, xs])`, "", "[[1],[1]]"},
+		{`json.decode(text)`, `[1, 2.5, true, null, "x"]`, `[1, 2.5, true, null, x]`},
+		{`json.decode(text)`, ` {"b": {"c": []}, "a": 1, "a": 2} `, `{b: {c: []}, a: 2}`},
+		{`json.decode(text)["k"]`, `{"k": 1}`, "1"},
+		{`type(json.decode(text))`, "12345678901234567890123", "BIG_INT"},
+		{`json.decode(text)`, "1.5e3", "1500"},
+		{`json.decode(text)`, "-25e-3", "-0.025"},
+		{`type(json.decode(text))`, "2.0", "DECIMAL"},
+		{`json.encode(json.decode(text)) == text`, `{"a":[1,{"b":null}],"c":"d\u00e9"}`, "false"},
+		{`json.encode(json.decode(text)) == text`, `{"a":[1,{"b":null}],"c":"\\"}`, "true"},
+	}
+
+	for _, testCase := range testCases {
+		evaluated := run(testCase.input, testCase.text)
+		if evaluated == nil || evaluated.Inspect() != testCase.expected {
+			t.Errorf("%s should be %s, got %T (%+v)", testCase.input, testCase.expected, evaluated, evaluated)
+		}
+	}
+
+	errorCases := []struct {
+		input    string
+		text     string
+		expected string
+	}{
+		{`json.encode(func(x) { x })`, "", "cannot encode FUNCTION as JSON"},
+		{`json.encode([len])`, "", "cannot encode BUILTIN as JSON"},
+		{`json.encode({1: 2})`, "", "JSON object keys must be STRING, got INTEGER"},
+		{`json.encode(rational(1, 3))`, "", "cannot encode RATIONAL as JSON"},
+		{"struct Node { next }\nlet n = Node(1)\nn.next = [n]\njson.encode(n)", "", "cannot encode INSTANCE as JSON, it contains itself"},
+		{`json.encode(1, -1)`, "", "indent of `encode` must not be negative, got -1"},
+		{`json.encode(1, 4611686018427387904)`, "", "indent of `encode` must not be longer than 268435456, got 4611686018427387904"},
+		{`json.decode(text)`, "[1, 2", "invalid JSON: unexpected end of JSON input"},
+		{`json.decode(text)`, "", "invalid JSON: unexpected end of JSON input"},
+		{`json.decode(text)`, "1 2", "invalid JSON: unexpected data after the value"},
+		{`json.decode(text)`, "{1: 2}", "invalid JSON: object member name must be a string"},
+		{`json.decode(text)`, "1e99999", "invalid JSON: exponent of 1e99999 is too large"},
+		{`json.decode(1)`, "", "argument to `decode` must be STRING, got INTEGER"},
+	}
+
+	for _, testCase := range errorCases {
+		testErrorObject(t, run(testCase.input, testCase.text), testCase.expected)
+	}
+}
+
+func TestFSModule(t *testing.T) {
+	dir, outside := t.TempDir(), t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(dir, "escape")); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(roots []string, input string) object.Object {
+		runtime := object.NewRuntime()
+		runtime.FileRoots = roots
+		program := testParse(`import "std/fs" as fs; ` + input)
+		env := object.NewEnvironmentWithRuntime(runtime)
+		env.Set("dir", &object.String{Value: dir})
+		env.Set("outside", &object.String{Value: outside})
+		return Eval(program, env)
+	}
+
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{`fs.write(dir + "/a.txt", "one"); fs.append(dir + "/a.txt", " two"); fs.read(dir + "/a.txt")`, "one two"},
+		{`fs.write(dir + "/a.txt", "three"); fs.read(dir + "/a.txt")`, "three"},
+		{`fs.append(dir + "/b.txt", "new"); fs.read(dir + "/b.txt")`, "new"},
+		{`[fs.exists(dir + "/a.txt"), fs.exists(dir + "/missing")]`, "[true, false]"},
+		{`fs.mkdir(dir + "/sub/deeper"); fs.exists(dir + "/sub/deeper")`, "true"},
+		{`fs.list(dir)`, "[a.txt, b.txt, escape, sub]"},
+		{`fs.remove(dir + "/sub/deeper"); fs.remove(dir + "/b.txt"); fs.list(dir)`, "[a.txt, escape, sub]"},
+		{`fs.read(dir + "/escape/secret.txt")`, "secret"},
+	}
+
+	for _, testCase := range testCases {
+		evaluated := run(nil, testCase.input)
+		if evaluated == nil || evaluated.Inspect() != testCase.expected {
+			t.Errorf("%s should be %s, got %T (%+v)", testCase.input, testCase.expected, evaluated, evaluated)
+		}
+	}
+
+	sandboxed := []struct {
+		input    string
+		expected string
+	}{
+		{`fs.read(dir + "/a.txt")`, "three"},
+		{`fs.write(dir + "/new/../c.txt", "c"); fs.read(dir + "/c.txt")`, "c"},
+		{`fs.exists(dir + "/sub")`, "true"},
+	}
+
+	for _, testCase := range sandboxed {
+		evaluated := run([]string{dir}, testCase.input)
+		if evaluated == nil || evaluated.Inspect() != testCase.expected {
+			t.Errorf("sandboxed %s should be %s, got %T (%+v)", testCase.input, testCase.expected, evaluated, evaluated)
+		}
+	}
+
+	// a dangling link would create its target outside the sandbox
+	if err := os.Symlink(filepath.Join(outside, "pwned.txt"), filepath.Join(dir, "dangling")); err != nil {
+		t.Fatal(err)
+	}
+
+	outsideMessage := "`%s` is not allowed outside the permitted directories, got %q"
+	errorCases := []struct {
+		roots    []string
+		input    string
+		expected string
+	}{
+		{[]string{dir}, `fs.read(outside + "/secret.txt")`, fmt.Sprintf(outsideMessage, "read", outside+"/secret.txt")},
+		{[]string{dir}, `fs.read(dir + "/escape/secret.txt")`, fmt.Sprintf(outsideMessage, "read", dir+"/escape/secret.txt")},
+		{[]string{dir}, `fs.write(dir + "/../x.txt", "x")`, fmt.Sprintf(outsideMessage, "write", dir+"/../x.txt")},
+		{[]string{dir}, `fs.write(dir + "/dangling", "x")`, fmt.Sprintf(outsideMessage, "write", dir+"/dangling")},
+		{[]string{dir}, `fs.append(dir + "/dangling", "x")`, fmt.Sprintf(outsideMessage, "append", dir+"/dangling")},
+		{[]string{}, `fs.exists(dir)`, fmt.Sprintf(outsideMessage, "exists", dir)},
+		{nil, `fs.read(dir + "/missing")`, fmt.Sprintf("`read` failed for %q: no such file or directory", dir+"/missing")},
+		{nil, `fs.remove(dir + "/sub/missing")`, fmt.Sprintf("`remove` failed for %q: no such file or directory", dir+"/sub/missing")},
+		{nil, `fs.list(dir + "/a.txt")`, fmt.Sprintf("`list` failed for %q: not a directory", dir+"/a.txt")},
+		{nil, `fs.write(dir, "x")`, fmt.Sprintf("`write` failed for %q: is a directory", dir)},
+		{nil, `fs.read(1)`, "path given to `read` must be STRING, got INTEGER"},
+		{nil, `fs.write(dir + "/a.txt", 1)`, "content given to `write` must be STRING, got INTEGER"},
+	}
+
+	for _, testCase := range errorCases {
+		testErrorObject(t, run(testCase.roots, testCase.input), testCase.expected)
+	}
+	if _, err := os.Lstat(filepath.Join(outside, "pwned.txt")); !os.IsNotExist(err) {
+		t.Errorf("writing through a dangling link should not create its target, got %v", err)
+	}
+	if err, ok := run(nil, `fs.read(dir + "/missing")`).(*object.Error); !ok || err.Kind != object.IO_ERROR {
+		t.Errorf("a failed read should be an IOError, got %+v", err)
+	}
+
+	// removing a link leaves what it points to
+	if evaluated := run([]string{dir}, `fs.remove(dir + "/escape"); fs.exists(outside + "/secret.txt")`); !isError(evaluated) {
+		t.Errorf("checking outside the sandbox should fail, got %s", evaluated.Inspect())
+	}
+	if evaluated := run(nil, `fs.exists(outside + "/secret.txt")`); evaluated != TRUE {
+		t.Errorf("removing a link should keep its target, got %s", evaluated.Inspect())
+	}
+}
+
+func TestInputAndExit(t *testing.T) {
+	run := func(stdin, input string) object.Object {
+		runtime := object.NewRuntime()
+		runtime.Stdin = strings.NewReader(stdin)
+		program := testParse(input)
+		return Eval(program, object.NewEnvironmentWithRuntime(runtime))
+	}
+
+	testCases := []struct {
+		stdin    string
+		input    string
+		expected string
+	}{
+		{"one\ntwo\n", "[input(), input(), input()]", "[one, two, null]"},
+		{"last", "[input(), input()]", "[last, null]"},
+		{"a\r\nb\n\nc", "read_lines()", "[a, b, , c]"},
+		{"first\nsecond\nthird\n", "let head = input(); [head, read_lines()]", "[first, [second, third]]"},
+		{"", "read_lines()", "[]"},
+		{"", "let done = false; try { exit(1) } catch { done = true }; done", "Error: exit status 1"},
+	}
+
+	for _, testCase := range testCases {
+		evaluated := run(testCase.stdin, testCase.input)
+		if evaluated == nil || evaluated.Inspect() != testCase.expected {
+			t.Errorf("%s should be %s, got %T (%+v)", testCase.input, testCase.expected, evaluated, evaluated)
+		}
+	}
+
+	exits := []struct {
+		input  string
+		status int
+	}{
+		{"exit()", 0},
+		{"exit(3); 1", 3},
+		{"let stop = func() { for i in range(10) { if (i > 2) { exit(i) } } }; stop(); 0", 3},
+		{"let xs = map([1], func(x) { exit(4) }); xs", 4},
+	}
+
+	for _, testCase := range exits {
+		evaluated := run("", testCase.input)
+		err, ok := evaluated.(*object.Error)
+		if !ok || err.Kind != object.EXIT || err.Status != testCase.status {
+			t.Errorf("%s should exit with %d, got %T (%+v)", testCase.input, testCase.status, evaluated, evaluated)
+		}
+	}
+
+	// finally blocks run on the way out
+	env := object.NewEnvironment()
+	program := testParse("let ran = false; try { exit(2) } finally { ran = true }")
+	if err, ok := Eval(program, env).(*object.Error); !ok || err.Status != 2 {
+		t.Errorf("exit inside try should still exit, got %+v", err)
+	}
+	if ran, _ := env.Get("ran"); ran != TRUE {
+		t.Errorf("finally should run when exiting, got %+v", ran)
+	}
+
+	errorCases := []struct {
+		input    string
+		expected string
+	}{
+		{`exit("1")`, "argument to `exit` must be INTEGER, got STRING"},
+		{`exit(256)`, "status given to `exit` must be from 0 to 255, got 256"},
+		{`read_lines(1)`, "invalid number of arguments to `read_lines`, expected 0, got 1"},
+		{`input(1, 2)`, "invalid number of arguments to `input`, expected 0 or 1, got 2"},
+	}
+
+	for _, testCase := range errorCases {
+		testErrorObject(t, run("", testCase.input), testCase.expected)
+	}
+}
+
+func TestOutputStreams(t *testing.T) {
+	var stdout, stderr strings.Builder
+	runtime := object.NewRuntime()
+	runtime.Stdin = strings.NewReader("Ada\n")
+	runtime.Stdout = &stdout
+	runtime.Stderr = &stderr
+
+	input := `print("a", 1); eprint("warning"); let name = input("name: "); print([name]); eprint()`
+	evaluated := Eval(testParse(input), object.NewEnvironmentWithRuntime(runtime))
+	if evaluated != NULL {
+		t.Fatalf("printing should give null, got %+v", evaluated)
+	}
+	if stdout.String() != "a\n1\nname: [Ada]\n" {
+		t.Errorf("wrong standard output, got %q", stdout.String())
+	}
+	if stderr.String() != "warning\n" {
+		t.Errorf("wrong standard error, got %q", stderr.String())
+	}
+}
+
+func TestModules(t *testing.T) {
+	root, err := ioutil.TempDir("", "plug-modules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	files := map[string]string{
+		"lib/util.plug":    "export let double = func(x) { x * 2 }; let hidden = 1; export struct Pair { a, b }",
+		"lib/counter.plug": `import "util.plug" as util; export let count = util.double(1)`,
+		"lib/a.plug":       `import "b.plug" as b; export let x = 1`,
+		"lib/b.plug":       `import "a.plug" as a`,
+		"lib/broken.plug":  "let = 1",
+		"lib/failing.plug": "export let x = 1 / 0",
+		"path/shared.plug": "export let answer = 42",
+		"lib/slow.plug":    "let count = func(n) { if (n == 0) { 0 } else { 1 + count(n - 1) } }; export let value = count(2000)",
+	}
+	for name, source := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(source), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run := func(input string) (object.Object, *object.Runtime) {
+		runtime := object.NewRuntime()
+		runtime.ModulePath = []string{filepath.Join(root, "path")}
+		program := testParse(input)
+		return Eval(program, object.NewFileEnvironment(runtime, filepath.Join(root, "main.plug"))), runtime
+	}
+
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{`import "lib/util.plug" as util; util.double(21)`, "42"},
+		{`import "lib/util.plug" as util; util.Pair(1, 2).b`, "2"},
+		{`import "lib/counter.plug" as counter; counter.count`, "2"},
+		{`import "shared.plug" as shared; shared.answer`, "42"},
+		{`import "std/list" as list; list.sum([1, 2])`, "3"},
+		{`import "lib/util.plug" as util; util`, "module util"},
+		{`import "lib/util.plug" as util; type(util)`, "MODULE"},
+	}
+
+	for _, testCase := range testCases {
+		evaluated, _ := run(testCase.input)
+		if evaluated == nil || evaluated.Inspect() != testCase.expected {
+			t.Errorf("%s should be %s, got %T (%+v)", testCase.input, testCase.expected, evaluated, evaluated)
+		}
+	}
+
+	// a module is evaluated once however many times it is imported
+	evaluated, runtime := run(`import "lib/util.plug" as one; import "lib/counter.plug" as two; import "lib/util.plug" as three; one == three`)
+	if evaluated != TRUE {
+		t.Errorf("importing a file twice should give the same module, got %+v", evaluated)
+	}
+	_, utilCached := runtime.Module(filepath.Join(root, "lib", "util.plug"))
+	_, counterCached := runtime.Module(filepath.Join(root, "lib", "counter.plug"))
+	if !utilCached || !counterCached {
+		t.Errorf("imported modules should be cached")
+	}
+
+	// functions spawned together can import the same file, it is no cycle
+	evaluated, _ = run(`let load = func() { import "lib/slow.plug" as slow; slow.value }; wait([spawn load(), spawn load(), spawn load()])`)
+	if evaluated == nil || evaluated.Inspect() != "[2000, 2000, 2000]" {
+		t.Errorf("concurrent imports should all get the module, got %T (%+v)", evaluated, evaluated)
+	}
+
+	errorCases := []struct {
+		input           string
+		expectedKind    string
+		expectedMessage string
+	}{
+		{`import "lib/util.plug" as util; util.hidden`, object.NAME_ERROR, "module util has no export hidden"},
+		{`import "missing.plug" as missing`, object.NAME_ERROR, `cannot find module "missing.plug"`},
+		{`import "std/missing" as missing`, object.NAME_ERROR, `cannot find module "std/missing"`},
+		{`import "lib/a.plug" as a`, object.RUNTIME_ERROR, "import cycle: "},
+		{`import "lib/broken.plug" as broken`, object.SYNTAX_ERROR, "broken.plug: expected next token to be IDENTIFIER"},
+		{`import "lib/failing.plug" as failing`, object.RUNTIME_ERROR, "division by zero"},
+	}
+
+	for _, testCase := range errorCases {
+		evaluated, _ := run(testCase.input)
+		err, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%s should fail, got %T (%+v)", testCase.input, evaluated, evaluated)
+			continue
+		}
+		if err.Kind != testCase.expectedKind || !strings.Contains(err.Message, testCase.expectedMessage) {
+			t.Errorf("%s should fail with %s %q, got %s %q", testCase.input, testCase.expectedKind, testCase.expectedMessage, err.Kind, err.Message)
+		}
+	}
+
+	evaluated, _ = run(`import "lib/a.plug" as a`)
+	a, b := filepath.Join(root, "lib", "a.plug"), filepath.Join(root, "lib", "b.plug")
+	chain := strings.Join(displayPaths([]string{a, b, a}), " -> ")
+	if err, ok := evaluated.(*object.Error); !ok || !strings.HasSuffix(err.Message, chain) {
+		t.Errorf("the cycle error should show the import chain, got %+v", evaluated)
+	}
+}
+
+func testEval(input string) object.Object {
+	return Eval(testParse(input), object.NewEnvironment())
+}
+
+// testParse parses a test's input, which is expected to parse: evaluating
+// what the parser managed to make of broken input would test nothing.
+func testParse(input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		panic(fmt.Sprintf("parser errors in %q: %s", input, strings.Join(p.Errors(), "; ")))
+	}
+	return program
+}
+
+func testIntegerCases(testCases []IntegerTestCase, t *testing.T) {
+	for _, testCase := range testCases {
+		testIntegerObject(t, testCase.expected, testEval(testCase.input))
+	}
+}
+
+func testNullObject(t *testing.T, obj object.Object) bool {
+	if obj != NULL {
+		t.Errorf("object is not null, got %T (%+v)", obj, obj)
+		return false
+	}
+	return true
+}
+
+func testErrorObject(t *testing.T, evaluated object.Object, expectedMessage string) bool {
+	err, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Errorf("no error object returned, got %T (%+v)", evaluated, evaluated)
+		return false
+	}
+	if err.Message != expectedMessage {
+		t.Errorf("wrong error message, expected %q, got %q", expectedMessage, err.Message)
+		return false
+	}
+
 	return true
 }
 
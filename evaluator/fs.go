@@ -0,0 +1,231 @@
+package evaluator
+
+import (
+	"errors"
+	"github.com/noculture/plug/object"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The fs module, imported as "std/fs". Relative paths are taken from the
+// working directory. When the host sets the runtime's FileRoots, paths that
+// lead outside those directories, through symbolic links too, are refused.
+
+// sandboxedPath checks a path argument and returns the file it refers to
+// once symbolic links are followed. A link at the end of the path is only
+// followed when followLast is set, so removing a link leaves its target.
+func sandboxedPath(env *object.Environment, name string, arg object.Object, followLast bool) (string, *object.Error) {
+	path, ok := arg.(*object.String)
+	if !ok {
+		return "", newError(object.TYPE_ERROR, "path given to `%s` must be STRING, got %s", name, arg.Type())
+	}
+
+	real, err := realPath(path.Value)
+	if !followLast {
+		real, err = filepath.Abs(path.Value)
+		last := filepath.Base(real)
+		if err == nil {
+			real, err = realPath(filepath.Dir(real))
+			real = filepath.Join(real, last)
+		}
+	}
+	if err != nil {
+		return "", fsError(name, path.Value, err)
+	}
+
+	roots := env.Runtime().FileRoots
+	if roots == nil {
+		return real, nil
+	}
+	for _, root := range roots {
+		root, err := realPath(root)
+		if err != nil {
+			continue
+		}
+		relative, err := filepath.Rel(root, real)
+		if err == nil && relative != ".." && !strings.HasPrefix(relative, ".."+string(filepath.Separator)) {
+			return real, nil
+		}
+	}
+	return "", newError(object.IO_ERROR, "`%s` is not allowed outside the permitted directories, got %q", name, path.Value)
+}
+
+// maxLinks bounds the dangling symbolic links realPath follows, so links
+// leading to each other are an error rather than an endless loop.
+const maxLinks = 255
+
+// realPath makes a path absolute and follows the symbolic links in the part
+// of it that exists, so files yet to be created can be checked as well. A
+// dangling link is followed to where it leads, since creating a file
+// through it would create the file there.
+func realPath(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	missing := ""
+	for links := 0; ; {
+		real, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			return filepath.Join(real, missing), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+
+		if info, statErr := os.Lstat(path); statErr == nil && info.Mode()&fs.ModeSymlink != 0 {
+			if links++; links > maxLinks {
+				return "", &fs.PathError{Op: "open", Path: path, Err: errors.New("too many levels of symbolic links")}
+			}
+			target, linkErr := os.Readlink(path)
+			if linkErr != nil {
+				return "", linkErr
+			}
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(filepath.Dir(path), target)
+			}
+			path = target
+			continue
+		}
+
+		parent := filepath.Dir(path)
+		if parent == path {
+			return "", err
+		}
+		missing = filepath.Join(filepath.Base(path), missing)
+		path = parent
+	}
+}
+
+// fsError reports a failed operation on the path as the program gave it.
+func fsError(name, path string, err error) *object.Error {
+	var pathError *fs.PathError
+	if errors.As(err, &pathError) {
+		err = pathError.Err
+	}
+	return newError(object.IO_ERROR, "`%s` failed for %q: %s", name, path, err)
+}
+
+// pathArgument checks a builtin got the expected number of arguments and
+// returns the path given as the first.
+func pathArgument(env *object.Environment, name string, args []object.Object, expected int) (string, *object.Error) {
+	if len(args) != expected {
+		return "", newError(object.ARGUMENT_ERROR, "invalid number of arguments to `%s`, expected %d, got %d", name, expected, len(args))
+	}
+	return sandboxedPath(env, name, args[0], true)
+}
+
+func readBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	path, err := pathArgument(env, "read", args, 1)
+	if err != nil {
+		return err
+	}
+
+	content, readErr := os.ReadFile(path)
+	if readErr != nil {
+		return fsError("read", args[0].(*object.String).Value, readErr)
+	}
+	return &object.String{Value: string(content)}
+}
+
+// writeBuiltin replaces the content of a file, creating it when needed.
+func writeBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	return writeFile(env, "write", args, os.O_TRUNC)
+}
+
+// appendBuiltin adds to the end of a file, creating it when needed.
+func appendBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	return writeFile(env, "append", args, os.O_APPEND)
+}
+
+func writeFile(env *object.Environment, name string, args []object.Object, mode int) object.Object {
+	path, err := pathArgument(env, name, args, 2)
+	if err != nil {
+		return err
+	}
+	content, ok := args[1].(*object.String)
+	if !ok {
+		return newError(object.TYPE_ERROR, "content given to `%s` must be STRING, got %s", name, args[1].Type())
+	}
+
+	// the path was checked with its links followed, a link put in its place
+	// since must not lead the write anywhere else
+	file, openErr := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|openNoFollow|mode, 0644)
+	if openErr != nil {
+		return fsError(name, args[0].(*object.String).Value, openErr)
+	}
+	_, writeErr := file.WriteString(content.Value)
+	if closeErr := file.Close(); writeErr == nil {
+		writeErr = closeErr
+	}
+	if writeErr != nil {
+		return fsError(name, args[0].(*object.String).Value, writeErr)
+	}
+	return NULL
+}
+
+func existsBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	path, err := pathArgument(env, "exists", args, 1)
+	if err != nil {
+		return err
+	}
+
+	_, statErr := os.Stat(path)
+	if errors.Is(statErr, fs.ErrNotExist) {
+		return FALSE
+	}
+	if statErr != nil {
+		return fsError("exists", args[0].(*object.String).Value, statErr)
+	}
+	return TRUE
+}
+
+// fsListBuiltin returns the names in a directory, sorted.
+func fsListBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	path, err := pathArgument(env, "list", args, 1)
+	if err != nil {
+		return err
+	}
+
+	entries, readErr := os.ReadDir(path)
+	if readErr != nil {
+		return fsError("list", args[0].(*object.String).Value, readErr)
+	}
+	names := make([]string, len(entries))
+	for index, entry := range entries {
+		names[index] = entry.Name()
+	}
+	return stringsOf(names)
+}
+
+// mkdirBuiltin creates a directory along with any parents it is missing.
+func mkdirBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	path, err := pathArgument(env, "mkdir", args, 1)
+	if err != nil {
+		return err
+	}
+
+	if mkdirErr := os.MkdirAll(path, 0755); mkdirErr != nil {
+		return fsError("mkdir", args[0].(*object.String).Value, mkdirErr)
+	}
+	return NULL
+}
+
+// removeBuiltin deletes a file or an empty directory.
+func removeBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `remove`, expected 1, got %d", len(args))
+	}
+	path, err := sandboxedPath(env, "remove", args[0], false)
+	if err != nil {
+		return err
+	}
+
+	if removeErr := os.Remove(path); removeErr != nil {
+		return fsError("remove", args[0].(*object.String).Value, removeErr)
+	}
+	return NULL
+}
@@ -0,0 +1,9 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package evaluator
+
+import "syscall"
+
+// openNoFollow makes opening a file fail when it is a symbolic link.
+const openNoFollow = syscall.O_NOFOLLOW
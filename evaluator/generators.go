@@ -0,0 +1,80 @@
+package evaluator
+
+import (
+	"github.com/noculture/plug/object"
+	"github.com/noculture/plug/token"
+	"runtime"
+)
+
+// A generator runs its function body on its own goroutine, handing control
+// back and forth with the consumer so only one of them runs at a time. The
+// body starts on the first call to Next and pauses at every yield.
+type generator struct {
+	resume   chan<- bool // true to run to the next yield, false to abandon the body
+	values   <-chan object.Object
+	finished bool
+}
+
+func newGenerator(function *object.Function, args []object.Object, call token.Token, depth int) *object.Iterator {
+	// the body only holds the channels, so it never keeps the generator alive
+	resume, values := make(chan bool), make(chan object.Object)
+
+	go func() {
+		defer close(values)
+
+		if !<-resume {
+			return
+		}
+
+		env := createFunctionScope(function, args, depth)
+		env.SetYield(func(value object.Object) {
+			values <- value
+			if !<-resume {
+				// nobody will ask for more values, stop the body where it is
+				runtime.Goexit()
+			}
+		})
+
+		result := Eval(function.Body, env)
+		if err, ok := result.(*object.Error); ok {
+			frame := object.Frame{Function: function.Name, Line: call.Line, Column: call.Column, Arguments: len(args)}
+			err.Stack = append(err.Stack, frame)
+			values <- err
+		}
+	}()
+
+	gen := &generator{resume: resume, values: values}
+
+	// a generator that is dropped before it is exhausted would otherwise
+	// leave its goroutine waiting forever. The finalizer is on the generator
+	// rather than the iterator, since map and the others keep only its Next
+	runtime.SetFinalizer(gen, (*generator).abandon)
+
+	return &object.Iterator{Next: gen.next}
+}
+
+func (gen *generator) next() (object.Object, bool) {
+	if gen.finished {
+		return nil, false
+	}
+
+	gen.resume <- true
+	value, ok := <-gen.values
+	if !ok || isError(value) {
+		gen.finished = true
+	}
+	if !ok {
+		return nil, false
+	}
+
+	return value, true
+}
+
+func (gen *generator) abandon() {
+	if gen.finished {
+		return
+	}
+
+	gen.finished = true
+	gen.resume <- false
+}
@@ -0,0 +1,103 @@
+package evaluator
+
+import (
+	"github.com/noculture/plug/object"
+	"io"
+)
+
+// Builtins for talking to whatever runs the program: printing, reading
+// standard input and ending the program with an exit status. The streams
+// are the runtime's, so hosts decide where they lead.
+
+// printBuiltin writes each argument on a line of its own to standard output.
+func printBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	return printLines(env, "print", args, env.Runtime().WriteOut)
+}
+
+// eprintBuiltin is print for standard error.
+func eprintBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	return printLines(env, "eprint", args, env.Runtime().WriteErr)
+}
+
+func printLines(env *object.Environment, name string, args []object.Object, write func(string) error) object.Object {
+	for _, arg := range args {
+		text := toString(env, arg)
+		if isError(text) {
+			return text
+		}
+		if err := write(text.Inspect() + "\n"); err != nil {
+			return newError(object.IO_ERROR, "`%s` failed: %s", name, err)
+		}
+	}
+	return NULL
+}
+
+// inputBuiltin reads a line of standard input, after printing the prompt
+// when one is given. It returns null once the input is exhausted.
+func inputBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) > 1 {
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `input`, expected 0 or 1, got %d", len(args))
+	}
+	if len(args) == 1 {
+		prompt := toString(env, args[0])
+		if isError(prompt) {
+			return prompt
+		}
+		if err := env.Runtime().WriteOut(prompt.Inspect()); err != nil {
+			return newError(object.IO_ERROR, "`input` failed: %s", err)
+		}
+	}
+
+	line, err := env.Runtime().ReadLine()
+	if err == io.EOF {
+		return NULL
+	}
+	if err != nil {
+		return newError(object.IO_ERROR, "`input` failed: %s", err)
+	}
+	return &object.String{Value: line}
+}
+
+// readLinesBuiltin reads the rest of standard input as an array of lines.
+func readLinesBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `read_lines`, expected 0, got %d", len(args))
+	}
+
+	lines := []string{}
+	for {
+		line, err := env.Runtime().ReadLine()
+		if err == io.EOF {
+			return stringsOf(lines)
+		}
+		if err != nil {
+			return newError(object.IO_ERROR, "`read_lines` failed: %s", err)
+		}
+		lines = append(lines, line)
+	}
+}
+
+// exitBuiltin ends the program with the given status, 0 when there is none.
+// It unwinds like an error that no try statement catches, so finally blocks
+// still run on the way out.
+func exitBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) > 1 {
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `exit`, expected 0 or 1, got %d", len(args))
+	}
+
+	status := 0
+	if len(args) == 1 {
+		code, ok := args[0].(*object.Integer)
+		if !ok {
+			return newError(object.TYPE_ERROR, "argument to `exit` must be INTEGER, got %s", args[0].Type())
+		}
+		if code.Value < 0 || code.Value > 255 {
+			return newError(object.ARGUMENT_ERROR, "status given to `exit` must be from 0 to 255, got %d", code.Value)
+		}
+		status = int(code.Value)
+	}
+
+	exit := newError(object.EXIT, "exit status %d", status)
+	exit.Status = status
+	return exit
+}
@@ -0,0 +1,203 @@
+package evaluator
+
+import (
+	"github.com/noculture/plug/object"
+	"github.com/noculture/plug/token"
+)
+
+// Builtins producing and consuming iterators. Given an iterator they stay
+// lazy and return another iterator, given an array or string they return an array.
+
+func rangeBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) < 1 || len(args) > 3 {
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `range`, expected 1 to 3, got %d", len(args))
+	}
+
+	var bounds []int64
+	for _, arg := range args {
+		integer, ok := arg.(*object.Integer)
+		if !ok {
+			return newError(object.TYPE_ERROR, "arguments to `range` must be INTEGER, got %s", arg.Type())
+		}
+		bounds = append(bounds, integer.Value)
+	}
+
+	start, end, step := int64(0), bounds[0], int64(1)
+	if len(bounds) > 1 {
+		start, end = bounds[0], bounds[1]
+	}
+	if len(bounds) > 2 {
+		step = bounds[2]
+	}
+	if step == 0 {
+		return newError(object.ARGUMENT_ERROR, "step of `range` must not be 0")
+	}
+
+	current := start
+	return &object.Iterator{Next: func() (object.Object, bool) {
+		if (step > 0 && current >= end) || (step < 0 && current <= end) {
+			return nil, false
+		}
+		value := current
+		current += step
+		return &object.Integer{Value: value}, true
+	}}
+}
+
+func countBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) > 2 {
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `count`, expected 0 to 2, got %d", len(args))
+	}
+
+	start, step := int64(0), int64(1)
+	for index, arg := range args {
+		integer, ok := arg.(*object.Integer)
+		if !ok {
+			return newError(object.TYPE_ERROR, "arguments to `count` must be INTEGER, got %s", arg.Type())
+		}
+		if index == 0 {
+			start = integer.Value
+		} else {
+			step = integer.Value
+		}
+	}
+
+	// counts up forever, consumers decide when to stop
+	current := start
+	return &object.Iterator{Next: func() (object.Object, bool) {
+		value := current
+		current += step
+		return &object.Integer{Value: value}, true
+	}}
+}
+
+func mapBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `map`, expected 2, got %d", len(args))
+	}
+
+	iterator := iteratorOf(env, args[0])
+	if isError(iterator) {
+		return iterator
+	}
+	next := iterator.(*object.Iterator).Next
+	function := args[1]
+
+	mapped := &object.Iterator{Next: func() (object.Object, bool) {
+		element, ok := next()
+		if !ok || isError(element) {
+			return element, ok
+		}
+		return applyFunction(function, []object.Object{element}, token.Token{}, env), true
+	}}
+
+	return lazyOrCollected(env, args[0], mapped)
+}
+
+func filterBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `filter`, expected 2, got %d", len(args))
+	}
+
+	iterator := iteratorOf(env, args[0])
+	if isError(iterator) {
+		return iterator
+	}
+	next := iterator.(*object.Iterator).Next
+	predicate := args[1]
+
+	filtered := &object.Iterator{Next: func() (object.Object, bool) {
+		for {
+			element, ok := next()
+			if !ok || isError(element) {
+				return element, ok
+			}
+			keep := applyFunction(predicate, []object.Object{element}, token.Token{}, env)
+			if isError(keep) {
+				return keep, true
+			}
+			if isTruthy(keep) {
+				return element, true
+			}
+		}
+	}}
+
+	return lazyOrCollected(env, args[0], filtered)
+}
+
+func takeBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `take`, expected 2, got %d", len(args))
+	}
+
+	limit, ok := args[1].(*object.Integer)
+	if !ok {
+		return newError(object.TYPE_ERROR, "second argument to `take` must be INTEGER, got %s", args[1].Type())
+	}
+
+	iterator := iteratorOf(env, args[0])
+	if isError(iterator) {
+		return iterator
+	}
+	next := iterator.(*object.Iterator).Next
+
+	taken := int64(0)
+	limited := &object.Iterator{Next: func() (object.Object, bool) {
+		if taken >= limit.Value {
+			return nil, false
+		}
+		taken++
+		return next()
+	}}
+
+	return lazyOrCollected(env, args[0], limited)
+}
+
+func listBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `list`, expected 1, got %d", len(args))
+	}
+
+	return collect(env, args[0])
+}
+
+func nextBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `next`, expected 1, got %d", len(args))
+	}
+
+	iterator, ok := args[0].(*object.Iterator)
+	if !ok {
+		return newError(object.TYPE_ERROR, "argument to `next` must be ITERATOR, got %s", args[0].Type())
+	}
+
+	element, ok := iterator.Next()
+	if !ok {
+		return NULL
+	}
+	return element
+}
+
+// collect materialises every element of an iterable into an array.
+func collect(env *object.Environment, iterable object.Object) object.Object {
+	elements := []object.Object{}
+
+	err := iterate(env, iterable, func(element object.Object) object.Object {
+		elements = append(elements, element)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+// lazyOrCollected keeps results lazy when the source was an iterator and
+// collects them into an array otherwise.
+func lazyOrCollected(env *object.Environment, source object.Object, result *object.Iterator) object.Object {
+	if source.Type() == object.ITERATOR {
+		return result
+	}
+	return collect(env, result)
+}
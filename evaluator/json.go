@@ -0,0 +1,245 @@
+package evaluator
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/noculture/plug/object"
+	"io"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// The json module, imported as "std/json". Objects decode to hashes with
+// their keys in the order they were written, numbers without a fraction or
+// exponent to integers and the rest to decimals, so no digits are lost.
+
+func encodeBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `encode`, expected 1 or 2, got %d", len(args))
+	}
+
+	indent := ""
+	if len(args) == 2 {
+		switch width := args[1].(type) {
+		case *object.Integer:
+			if width.Value < 0 {
+				return newError(object.ARGUMENT_ERROR, "indent of `encode` must not be negative, got %d", width.Value)
+			}
+			if width.Value > maxStringLength {
+				return newError(object.ARGUMENT_ERROR, "indent of `encode` must not be longer than %d, got %d", maxStringLength, width.Value)
+			}
+			indent = strings.Repeat(" ", int(width.Value))
+		case *object.String:
+			indent = width.Value
+		default:
+			return newError(object.TYPE_ERROR, "indent of `encode` must be INTEGER or STRING, got %s", args[1].Type())
+		}
+	}
+
+	encoder := &jsonEncoder{visiting: map[object.Object]bool{}}
+	if err := encoder.encode(args[0]); err != nil {
+		return err
+	}
+	if indent == "" {
+		return &object.String{Value: encoder.out.String()}
+	}
+
+	var indented bytes.Buffer
+	_ = json.Indent(&indented, encoder.out.Bytes(), "", indent)
+	return &object.String{Value: indented.String()}
+}
+
+// jsonEncoder writes compact JSON, visiting holds the arrays, hashes and
+// instances being written so one that contains itself is caught.
+type jsonEncoder struct {
+	out      bytes.Buffer
+	visiting map[object.Object]bool
+}
+
+func (encoder *jsonEncoder) encode(value object.Object) *object.Error {
+	switch value := value.(type) {
+	case *object.Null:
+		encoder.out.WriteString("null")
+	case *object.Boolean:
+		encoder.out.WriteString(strconv.FormatBool(value.Value))
+	case *object.Integer, *object.BigInt, *object.Decimal:
+		encoder.out.WriteString(value.Inspect())
+	case *object.String:
+		encoder.string(value.Value)
+	case *object.Array:
+		return encoder.nested(value, func() *object.Error {
+			encoder.out.WriteByte('[')
+			for index, element := range value.Elements {
+				if index > 0 {
+					encoder.out.WriteByte(',')
+				}
+				if err := encoder.encode(element); err != nil {
+					return err
+				}
+			}
+			encoder.out.WriteByte(']')
+			return nil
+		})
+	case *object.Hash:
+		return encoder.nested(value, func() *object.Error {
+			encoder.out.WriteByte('{')
+			for index, pair := range value.Pairs() {
+				key, ok := pair.Key.(*object.String)
+				if !ok {
+					return newError(object.TYPE_ERROR, "JSON object keys must be STRING, got %s", pair.Key.Type())
+				}
+				if index > 0 {
+					encoder.out.WriteByte(',')
+				}
+				encoder.string(key.Value)
+				encoder.out.WriteByte(':')
+				if err := encoder.encode(pair.Value); err != nil {
+					return err
+				}
+			}
+			encoder.out.WriteByte('}')
+			return nil
+		})
+	case *object.Instance:
+		return encoder.nested(value, func() *object.Error {
+			encoder.out.WriteByte('{')
+			for index, name := range value.Struct.Fields {
+				if index > 0 {
+					encoder.out.WriteByte(',')
+				}
+				field, _ := value.Field(name)
+				encoder.string(name)
+				encoder.out.WriteByte(':')
+				if err := encoder.encode(field); err != nil {
+					return err
+				}
+			}
+			encoder.out.WriteByte('}')
+			return nil
+		})
+	default:
+		return newError(object.TYPE_ERROR, "cannot encode %s as JSON", value.Type())
+	}
+	return nil
+}
+
+func (encoder *jsonEncoder) nested(value object.Object, write func() *object.Error) *object.Error {
+	if encoder.visiting[value] {
+		return newError(object.ARGUMENT_ERROR, "cannot encode %s as JSON, it contains itself", value.Type())
+	}
+	encoder.visiting[value] = true
+	defer delete(encoder.visiting, value)
+	return write()
+}
+
+func (encoder *jsonEncoder) string(value string) {
+	quoted := json.NewEncoder(&encoder.out)
+	quoted.SetEscapeHTML(false)
+	_ = quoted.Encode(value)
+	// Encode ends every value with a newline
+	encoder.out.Truncate(encoder.out.Len() - 1)
+}
+
+func decodeBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `decode`, expected 1, got %d", len(args))
+	}
+	text, ok := args[0].(*object.String)
+	if !ok {
+		return newError(object.TYPE_ERROR, "argument to `decode` must be STRING, got %s", args[0].Type())
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(text.Value))
+	decoder.UseNumber()
+	value, err := decodeValue(decoder)
+	if err == nil {
+		if _, err = decoder.Token(); err == io.EOF {
+			return value
+		}
+		if err == nil {
+			err = errors.New("unexpected data after the value")
+		}
+	}
+	if err == io.EOF {
+		err = errors.New("unexpected end of JSON input")
+	}
+	return newError(object.ARGUMENT_ERROR, "invalid JSON: %s", err)
+}
+
+func decodeValue(decoder *json.Decoder) (object.Object, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch token := token.(type) {
+	case nil:
+		return NULL, nil
+	case bool:
+		return referenceBoolObject(token), nil
+	case string:
+		return &object.String{Value: token}, nil
+	case json.Number:
+		return decodeNumber(string(token))
+	case json.Delim:
+		if token == '[' {
+			elements := []object.Object{}
+			for decoder.More() {
+				element, err := decodeValue(decoder)
+				if err != nil {
+					return nil, err
+				}
+				elements = append(elements, element)
+			}
+			_, err := decoder.Token()
+			return &object.Array{Elements: elements}, err
+		}
+
+		hash := object.NewHash()
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			hash.Set(&object.String{Value: key.(string)}, value)
+		}
+		_, err := decoder.Token()
+		return hash, err
+	}
+	return nil, errors.New("unexpected token")
+}
+
+// decodeNumber reads a number the decoder has already checked, applying
+// any exponent to the decimal's scale.
+func decodeNumber(text string) (object.Object, error) {
+	mantissa, exponent := strings.ToLower(text), ""
+	hasExponent := false
+	if index := strings.IndexByte(mantissa, 'e'); index >= 0 {
+		mantissa, exponent, hasExponent = mantissa[:index], mantissa[index+1:], true
+	}
+	if !hasExponent && !strings.Contains(mantissa, ".") {
+		value, _ := new(big.Int).SetString(mantissa, 10)
+		return normalizeInteger(value), nil
+	}
+
+	decimal, _ := object.ParseDecimal(mantissa)
+	if hasExponent {
+		shift, err := strconv.ParseInt(exponent, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("exponent of %s is too large", text)
+		}
+		decimal.Scale -= int(shift)
+		if decimal.Scale < 0 {
+			decimal.Unscaled.Mul(decimal.Unscaled, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(-decimal.Scale)), nil))
+			decimal.Scale = 0
+		}
+	}
+	return decimal, nil
+}
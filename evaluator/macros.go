@@ -0,0 +1,167 @@
+package evaluator
+
+import (
+	"fmt"
+	"github.com/noculture/plug/ast"
+	"github.com/noculture/plug/object"
+	"github.com/noculture/plug/token"
+)
+
+const (
+	quoteFunction   = "quote"
+	unquoteFunction = "unquote"
+)
+
+// isSpecialCall reports whether a call names one of the forms that work on
+// syntax instead of values.
+func isSpecialCall(call *ast.CallExpression, name string) bool {
+	identifier, ok := call.Function.(*ast.Identifier)
+	return ok && identifier.Value == name
+}
+
+// quote turns its argument into a Quote without evaluating it, apart from
+// calls to unquote inside it, which are evaluated and spliced back in.
+func quote(call *ast.CallExpression, env *object.Environment) object.Object {
+	if len(call.Arguments) != 1 {
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `quote`, expected 1, got %d", len(call.Arguments))
+	}
+
+	// Modify rewrites in place, and the same quote can be evaluated again
+	var failure object.Object
+	node := ast.Modify(ast.Copy(call.Arguments[0]), func(node ast.Node) ast.Node {
+		unquote, ok := node.(*ast.CallExpression)
+		if !ok || !isSpecialCall(unquote, unquoteFunction) || len(unquote.Arguments) != 1 || failure != nil {
+			return node
+		}
+
+		value := Eval(unquote.Arguments[0], env)
+		if isError(value) {
+			failure = value
+			return node
+		}
+		replacement, ok := objectToNode(value, unquote.Token)
+		if !ok {
+			failure = newError(object.TYPE_ERROR, "cannot unquote %s into syntax", value.Type())
+			return node
+		}
+		return replacement
+	})
+	if failure != nil {
+		return failure
+	}
+
+	return &object.Quote{Node: node}
+}
+
+// objectToNode turns a value back into syntax that evaluates to it.
+func objectToNode(obj object.Object, position token.Token) (ast.Node, bool) {
+	at := func(tokenType token.Type, literal string) token.Token {
+		return token.Token{Type: tokenType, Literal: literal, Line: position.Line, Column: position.Column}
+	}
+
+	switch obj := obj.(type) {
+	case *object.Quote:
+		// a copy, so syntax spliced in twice is never shared
+		return ast.Copy(obj.Node), true
+	case *object.Integer:
+		return &ast.IntegerLiteral{Token: at(token.INT, fmt.Sprintf("%d", obj.Value)), Value: obj.Value}, true
+	case *object.BigInt:
+		return &ast.IntegerLiteral{Token: at(token.INT, obj.Inspect()), Big: obj.Value}, true
+	case *object.Decimal:
+		return &ast.DecimalLiteral{Token: at(token.DECIMAL, obj.Inspect())}, true
+	case *object.String:
+		return &ast.StringLiteral{Token: at(token.STRING, obj.Value), Value: obj.Value}, true
+	case *object.Boolean:
+		if obj.Value {
+			return &ast.Boolean{Token: at(token.TRUE, "true"), Value: true}, true
+		}
+		return &ast.Boolean{Token: at(token.FALSE, "false"), Value: false}, true
+	default:
+		return nil, false
+	}
+}
+
+// DefineMacros binds every top-level `let name = macro(...) {...}` in env and
+// removes those statements from the program.
+func DefineMacros(program *ast.Program, env *object.Environment) {
+	var statements []ast.Statement
+
+	for _, statement := range program.Statements {
+		let, ok := statement.(*ast.LetStatement)
+		if !ok {
+			statements = append(statements, statement)
+			continue
+		}
+		literal, ok := let.Value.(*ast.MacroLiteral)
+		if !ok {
+			statements = append(statements, statement)
+			continue
+		}
+		env.Set(let.Name.Value, &object.Macro{Parameters: literal.Parameters, Body: literal.Body, Env: env})
+	}
+
+	program.Statements = statements
+}
+
+// ExpandMacros replaces every call to a macro defined in env with the syntax
+// the macro returns when called with its arguments quoted. It runs between
+// parsing and evaluation, after DefineMacros.
+func ExpandMacros(program ast.Node, env *object.Environment) (ast.Node, *object.Error) {
+	var failure *object.Error
+
+	expanded := ast.Modify(program, func(node ast.Node) ast.Node {
+		call, ok := node.(*ast.CallExpression)
+		if !ok || failure != nil {
+			return node
+		}
+		macro, ok := macroCalled(call, env)
+		if !ok {
+			return node
+		}
+		if len(call.Arguments) != len(macro.Parameters) {
+			failure = withPosition(newError(object.ARGUMENT_ERROR, "macro %s expects %d arguments, got %d",
+				call.Function.String(), len(macro.Parameters), len(call.Arguments)), call.Token).(*object.Error)
+			return node
+		}
+
+		scope := object.NewEnclosedEvironment(macro.Env)
+		for index, parameter := range macro.Parameters {
+			scope.Set(parameter.Value, &object.Quote{Node: call.Arguments[index]})
+		}
+
+		result := unwrapReturnValue(Eval(ast.Copy(macro.Body), scope))
+		if err, ok := result.(*object.Error); ok {
+			failure = withPosition(err, call.Token).(*object.Error)
+			return node
+		}
+		quoted, ok := result.(*object.Quote)
+		if !ok {
+			failure = withPosition(newError(object.TYPE_ERROR, "macro %s must return QUOTE, got %s",
+				call.Function.String(), typeOf(result)), call.Token).(*object.Error)
+			return node
+		}
+		return quoted.Node
+	})
+
+	return expanded, failure
+}
+
+func macroCalled(call *ast.CallExpression, env *object.Environment) (*object.Macro, bool) {
+	identifier, ok := call.Function.(*ast.Identifier)
+	if !ok {
+		return nil, false
+	}
+	value, ok := env.Get(identifier.Value)
+	if !ok {
+		return nil, false
+	}
+	macro, ok := value.(*object.Macro)
+	return macro, ok
+}
+
+func typeOf(obj object.Object) object.Type {
+	if obj == nil {
+		return object.NULL
+	}
+	return obj.Type()
+}
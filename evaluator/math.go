@@ -0,0 +1,306 @@
+package evaluator
+
+import (
+	"github.com/noculture/plug/object"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// The math module, imported as "std/math". Functions with exact answers keep
+// the type of their arguments, the rest work in floating point and return a
+// decimal rounded to the interpreter's DecimalPlaces. Arguments outside a
+// function's domain give a DomainError instead of NaN or infinity.
+
+// mathConstant parses one of the module's constants, given to 20 places.
+func mathConstant(text string) *object.Decimal {
+	constant, _ := object.ParseDecimal(text)
+	return constant
+}
+
+// numberArguments checks a math builtin got the expected number of arguments
+// and that all of them are numbers.
+func numberArguments(name string, args []object.Object, expected int) *object.Error {
+	if len(args) != expected {
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `%s`, expected %d, got %d", name, expected, len(args))
+	}
+	for _, arg := range args {
+		if !isNumber(arg) {
+			return newError(object.TYPE_ERROR, "arguments to `%s` must be numbers, got %s", name, arg.Type())
+		}
+	}
+	return nil
+}
+
+func domainError(format string, a ...interface{}) *object.Error {
+	return newError(object.DOMAIN_ERROR, format, a...)
+}
+
+func toFloat(obj object.Object) float64 {
+	value, _ := toRat(obj).Float64()
+	return value
+}
+
+// fromFloat turns the result of a floating point calculation into a decimal.
+func fromFloat(env *object.Environment, name string, value float64) object.Object {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return domainError("result of `%s` is out of range", name)
+	}
+
+	decimal, _ := object.ParseDecimal(strconv.FormatFloat(value, 'f', -1, 64))
+	runtime := env.Runtime()
+	if decimal.Scale > runtime.DecimalPlaces {
+		decimal = decimal.Round(runtime.DecimalPlaces, runtime.Rounding)
+	}
+	return decimal.Trim(0)
+}
+
+// floatFunction makes a math builtin of one argument from a Go function,
+// valid reports whether the argument is in its domain.
+func floatFunction(name string, function func(float64) float64, valid func(float64) bool, domain string) object.BuiltinFunction {
+	return func(env *object.Environment, args ...object.Object) object.Object {
+		if err := numberArguments(name, args, 1); err != nil {
+			return err
+		}
+		value := toFloat(args[0])
+		if valid != nil && !valid(value) {
+			return domainError("`%s` is only defined for %s, got %s", name, domain, args[0].Inspect())
+		}
+		return fromFloat(env, name, function(value))
+	}
+}
+
+func absBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if err := numberArguments("abs", args, 1); err != nil {
+		return err
+	}
+	if toRat(args[0]).Sign() < 0 {
+		return evalMinusPrefixOperator(args[0])
+	}
+	return args[0]
+}
+
+func minBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	return extreme(env, "min", args, -1)
+}
+
+func maxBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	return extreme(env, "max", args, 1)
+}
+
+// extreme returns the smallest or largest of its arguments, or of the
+// elements of a single iterable argument. Ties go to the first.
+func extreme(env *object.Environment, name string, args []object.Object, sign int) object.Object {
+	values := args
+	if len(args) == 1 {
+		collected := collect(env, args[0])
+		if isError(collected) {
+			return collected
+		}
+		values = collected.(*object.Array).Elements
+	}
+	if len(values) == 0 {
+		return newError(object.ARGUMENT_ERROR, "`%s` needs at least one number", name)
+	}
+
+	var best object.Object
+	for _, value := range values {
+		if !isNumber(value) {
+			return newError(object.TYPE_ERROR, "arguments to `%s` must be numbers, got %s", name, value.Type())
+		}
+		if best == nil || toRat(value).Cmp(toRat(best)) == sign {
+			best = value
+		}
+	}
+	return best
+}
+
+// powBuiltin raises to integer powers exactly, integers to negative powers
+// give rationals. Other powers are calculated in floating point.
+func powBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if err := numberArguments("pow", args, 2); err != nil {
+		return err
+	}
+	base, exponent := args[0], args[1]
+	zero := toRat(base).Sign() == 0
+
+	power, ok := exponent.(*object.Integer)
+	if !ok && isInteger(exponent) {
+		return domainError("exponent of `pow` is too large, got %s", exponent.Inspect())
+	}
+	if zero && toRat(exponent).Sign() < 0 {
+		return domainError("`pow` of 0 to a negative power")
+	}
+	if !ok {
+		if toRat(base).Sign() < 0 && !toRat(exponent).IsInt() {
+			return domainError("`pow` of a negative number to a fractional power")
+		}
+		return fromFloat(env, "pow", math.Pow(toFloat(base), toFloat(exponent)))
+	}
+
+	magnitude := big.NewInt(power.Value)
+	magnitude.Abs(magnitude)
+	if bits := powerBits(base); bits > 0 && magnitude.Cmp(big.NewInt(maxPowerBits/bits)) > 0 {
+		return domainError("result of `pow` is too large, got %s to the power of %d", base.Inspect(), power.Value)
+	}
+	switch base := base.(type) {
+	case *object.Integer, *object.BigInt:
+		result := new(big.Int).Exp(toBigInt(base), magnitude, nil)
+		if power.Value < 0 {
+			return &object.Rational{Value: new(big.Rat).SetFrac(big.NewInt(1), result)}
+		}
+		return normalizeInteger(result)
+	case *object.Decimal:
+		result := &object.Decimal{Unscaled: new(big.Int).Exp(base.Unscaled, magnitude, nil), Scale: base.Scale * int(magnitude.Int64())}
+		if power.Value < 0 {
+			runtime := env.Runtime()
+			return toDecimal(&object.Integer{Value: 1}).Quo(result, runtime.DecimalPlaces, runtime.Rounding)
+		}
+		return result
+	default:
+		value := toRat(base)
+		numerator := new(big.Int).Exp(value.Num(), magnitude, nil)
+		denominator := new(big.Int).Exp(value.Denom(), magnitude, nil)
+		if power.Value < 0 {
+			numerator, denominator = denominator, numerator
+		}
+		return &object.Rational{Value: new(big.Rat).SetFrac(numerator, denominator)}
+	}
+}
+
+// maxPowerBits bounds the size of the exact powers pow builds, so a huge
+// exponent is an error instead of the interpreter running out of memory.
+const maxPowerBits = 1 << 24
+
+// powerBits is about how many bits each power of the base adds to the
+// numbers pow builds, 0 when they never grow.
+func powerBits(base object.Object) int64 {
+	switch base := base.(type) {
+	case *object.Decimal:
+		return int64(base.Unscaled.BitLen()) + int64(base.Scale)
+	default:
+		value := toRat(base)
+		bits := value.Num().BitLen()
+		if denominator := value.Denom().BitLen(); denominator > bits {
+			bits = denominator
+		}
+		if bits <= 1 {
+			// the powers of 0, 1 and -1 stay the same size
+			return 0
+		}
+		return int64(bits)
+	}
+}
+
+// sqrtBuiltin calculates square roots to the interpreter's DecimalPlaces
+// without going through floating point.
+func sqrtBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if err := numberArguments("sqrt", args, 1); err != nil {
+		return err
+	}
+	if toRat(args[0]).Sign() < 0 {
+		return domainError("`sqrt` is only defined for numbers >= 0, got %s", args[0].Inspect())
+	}
+
+	runtime := env.Runtime()
+	precision := uint(runtime.DecimalPlaces*4 + 64)
+	root := new(big.Float).SetPrec(precision).SetRat(toRat(args[0]))
+	root.Sqrt(root)
+	exact, _ := root.Rat(nil)
+	return object.DecimalFromRat(exact, runtime.DecimalPlaces, runtime.Rounding).Trim(0)
+}
+
+func floorBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	return toInteger("floor", args, object.RoundFloor)
+}
+
+func ceilBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	return toInteger("ceil", args, object.RoundCeiling)
+}
+
+// mathRoundBuiltin rounds to the nearest integer the way the interpreter's
+// rounding mode says, unlike the `round` builtin which rounds to places.
+func mathRoundBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	return toInteger("round", args, env.Runtime().Rounding)
+}
+
+func toInteger(name string, args []object.Object, mode object.RoundingMode) object.Object {
+	if err := numberArguments(name, args, 1); err != nil {
+		return err
+	}
+	if isInteger(args[0]) {
+		return args[0]
+	}
+	return normalizeInteger(object.DecimalFromRat(toRat(args[0]), 0, mode).Unscaled)
+}
+
+func atan2Builtin(env *object.Environment, args ...object.Object) object.Object {
+	if err := numberArguments("atan2", args, 2); err != nil {
+		return err
+	}
+	return fromFloat(env, "atan2", math.Atan2(toFloat(args[0]), toFloat(args[1])))
+}
+
+// logBuiltin is the natural logarithm, or the logarithm to the base given
+// as the second argument.
+func logBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) == 1 {
+		return floatFunction("log", math.Log, positive, "numbers > 0")(env, args...)
+	}
+	if err := numberArguments("log", args, 2); err != nil {
+		return err
+	}
+
+	value, base := toFloat(args[0]), toFloat(args[1])
+	if value <= 0 {
+		return domainError("`log` is only defined for numbers > 0, got %s", args[0].Inspect())
+	}
+	if base <= 0 || base == 1 {
+		return domainError("base of `log` must be > 0 and not 1, got %s", args[1].Inspect())
+	}
+	return fromFloat(env, "log", math.Log(value)/math.Log(base))
+}
+
+func positive(value float64) bool {
+	return value > 0
+}
+
+func unitInterval(value float64) bool {
+	return value >= -1 && value <= 1
+}
+
+func gcdBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	a, b, err := integerPair("gcd", args)
+	if err != nil {
+		return err
+	}
+	return normalizeInteger(new(big.Int).GCD(nil, nil, a, b))
+}
+
+// lcmBuiltin is never negative, and 0 when either argument is.
+func lcmBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	a, b, err := integerPair("lcm", args)
+	if err != nil {
+		return err
+	}
+	if a.Sign() == 0 || b.Sign() == 0 {
+		return &object.Integer{Value: 0}
+	}
+	gcd := new(big.Int).GCD(nil, nil, a, b)
+	return normalizeInteger(new(big.Int).Mul(new(big.Int).Quo(a, gcd), b))
+}
+
+// integerPair returns the absolute values of two integer arguments.
+func integerPair(name string, args []object.Object) (*big.Int, *big.Int, *object.Error) {
+	if len(args) != 2 {
+		return nil, nil, newError(object.ARGUMENT_ERROR, "invalid number of arguments to `%s`, expected 2, got %d", name, len(args))
+	}
+	for _, arg := range args {
+		if !isInteger(arg) {
+			return nil, nil, newError(object.TYPE_ERROR, "arguments to `%s` must be integers, got %s", name, arg.Type())
+		}
+	}
+	a := new(big.Int).Abs(toBigInt(args[0]))
+	b := new(big.Int).Abs(toBigInt(args[1]))
+	return a, b, nil
+}
@@ -0,0 +1,162 @@
+package evaluator
+
+import (
+	"github.com/noculture/plug/ast"
+	"github.com/noculture/plug/lexer"
+	"github.com/noculture/plug/object"
+	"github.com/noculture/plug/parser"
+	"github.com/noculture/plug/stdlib"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// evalImportStatement binds the module for the imported file, evaluating
+// the file the first time any part of the interpreter imports it.
+func evalImportStatement(statement *ast.ImportStatement, env *object.Environment) object.Object {
+	path, ok := resolveImport(statement.Path.Value, env)
+	if !ok {
+		return withPosition(newError(object.NAME_ERROR, "cannot find module %q", statement.Path.Value), statement.Token)
+	}
+
+	module := importModule(path, env)
+	if isError(module) {
+		return withPosition(module, statement.Token)
+	}
+
+	env.Set(statement.Name.Value, module)
+	return nil
+}
+
+// stdlibPrefix starts the names of imports from the standard library, which
+// is compiled in rather than read from files.
+const stdlibPrefix = "std/"
+
+// resolveImport finds the file an import refers to, first relative to the
+// importing file and then in each directory of the module path. Standard
+// library modules resolve to their own name.
+func resolveImport(name string, env *object.Environment) (string, bool) {
+	if strings.HasPrefix(name, stdlibPrefix) {
+		_, native := nativeModules[strings.TrimPrefix(name, stdlibPrefix)]
+		_, ok := stdlib.Source(strings.TrimPrefix(name, stdlibPrefix))
+		return name, native || ok
+	}
+	if filepath.IsAbs(name) {
+		return name, isFile(name)
+	}
+
+	directories := []string{"."}
+	if file := env.File(); file != "" {
+		directories[0] = filepath.Dir(file)
+	}
+	directories = append(directories, env.Runtime().ModulePath...)
+
+	for _, directory := range directories {
+		path, err := filepath.Abs(filepath.Join(directory, name))
+		if err == nil && isFile(path) {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// importModule returns the cached module for a file or evaluates the file
+// in a scope of its own and collects what it exports. A file already on the
+// chain of imports that led to the importer is an import cycle.
+func importModule(path string, importer *object.Environment) object.Object {
+	runtime := importer.Runtime()
+	if module, ok := runtime.Module(path); ok {
+		return module
+	}
+
+	chain := importer.Imports()
+	for index, importing := range chain {
+		if importing == path {
+			cycle := append(append([]string{}, chain[index:]...), path)
+			return newError(object.RUNTIME_ERROR, "import cycle: %s", strings.Join(displayPaths(cycle), " -> "))
+		}
+	}
+
+	if functions, ok := nativeModules[strings.TrimPrefix(path, stdlibPrefix)]; ok && strings.HasPrefix(path, stdlibPrefix) {
+		module := &object.Module{Name: filepath.Base(path), Path: path, Exports: map[string]object.Object{}}
+		for name, function := range functions {
+			module.Exports[name] = function
+		}
+		return runtime.AddModule(path, module)
+	}
+
+	source, env, ok := loadModule(path, runtime)
+	if !ok {
+		return newError(object.RUNTIME_ERROR, "cannot read module %s", displayPath(path))
+	}
+	env.SetImports(append(append([]string{}, chain...), path))
+
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return newError(object.SYNTAX_ERROR, "in %s: %s", displayPath(path), strings.Join(p.Errors(), "; "))
+	}
+
+	DefineMacros(program, env)
+	expanded, expandErr := ExpandMacros(program, env)
+	if expandErr != nil {
+		return expandErr
+	}
+
+	if result := Eval(expanded, env); isError(result) {
+		return result
+	}
+
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	module := &object.Module{Name: name, Path: path, Exports: map[string]object.Object{}}
+	for _, statement := range program.Statements {
+		if export, ok := statement.(*ast.ExportStatement); ok {
+			value, _ := env.Get(export.Name())
+			module.Exports[export.Name()] = value
+		}
+	}
+	return runtime.AddModule(path, module)
+}
+
+// loadModule reads the source of a module along with the scope to evaluate
+// it in.
+func loadModule(path string, runtime *object.Runtime) (string, *object.Environment, bool) {
+	if strings.HasPrefix(path, stdlibPrefix) {
+		source, ok := stdlib.Source(strings.TrimPrefix(path, stdlibPrefix))
+		return source, object.NewEnvironmentWithRuntime(runtime), ok
+	}
+
+	source, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", nil, false
+	}
+	return string(source), object.NewFileEnvironment(runtime, path), true
+}
+
+// displayPath shortens a path to be relative to the working directory when
+// that makes it no longer.
+func displayPath(path string) string {
+	directory, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	relative, err := filepath.Rel(directory, path)
+	if err != nil || len(relative) > len(path) {
+		return path
+	}
+	return relative
+}
+
+func displayPaths(paths []string) []string {
+	displayed := make([]string, len(paths))
+	for index, path := range paths {
+		displayed[index] = displayPath(path)
+	}
+	return displayed
+}
@@ -0,0 +1,334 @@
+package evaluator
+
+import (
+	"github.com/noculture/plug/object"
+	"math"
+	"math/big"
+)
+
+func isInteger(obj object.Object) bool {
+	return obj.Type() == object.INTEGER || obj.Type() == object.BIG_INT
+}
+
+func isNumber(obj object.Object) bool {
+	return isInteger(obj) || obj.Type() == object.DECIMAL || obj.Type() == object.RATIONAL
+}
+
+// evalIntegerInfixExpression works on 64 bit integers for as long as results
+// fit and redoes the operation with arbitrary precision when they do not.
+func evalIntegerInfixExpression(operator string, left, right object.Object) object.Object {
+	leftInteger, leftOk := left.(*object.Integer)
+	rightInteger, rightOk := right.(*object.Integer)
+	if !leftOk || !rightOk {
+		return evalBigIntInfixExpression(operator, toBigInt(left), toBigInt(right))
+	}
+
+	leftValue, rightValue := leftInteger.Value, rightInteger.Value
+
+	switch operator {
+	case "+":
+		if sum := leftValue + rightValue; (sum > leftValue) == (rightValue > 0) {
+			return &object.Integer{Value: sum}
+		}
+	case "-":
+		if difference := leftValue - rightValue; (difference < leftValue) == (rightValue > 0) {
+			return &object.Integer{Value: difference}
+		}
+	case "*":
+		if leftValue == 0 || rightValue == 0 {
+			return &object.Integer{Value: 0}
+		}
+		product := leftValue * rightValue
+		if product/rightValue == leftValue && !(leftValue == -1 && rightValue == math.MinInt64) &&
+			!(rightValue == -1 && leftValue == math.MinInt64) {
+			return &object.Integer{Value: product}
+		}
+	case "/":
+		if rightValue == 0 {
+			return newError(object.RUNTIME_ERROR, "division by zero")
+		}
+		if !(leftValue == math.MinInt64 && rightValue == -1) {
+			return &object.Integer{Value: leftValue / rightValue}
+		}
+	case ">":
+		return referenceBoolObject(leftValue > rightValue)
+	case "<":
+		return referenceBoolObject(leftValue < rightValue)
+	case "==":
+		return referenceBoolObject(leftValue == rightValue)
+	case "!=":
+		return referenceBoolObject(leftValue != rightValue)
+	default:
+		return newError(object.TYPE_ERROR, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+
+	// the result overflowed
+	return evalBigIntInfixExpression(operator, big.NewInt(leftValue), big.NewInt(rightValue))
+}
+
+func evalBigIntInfixExpression(operator string, left, right *big.Int) object.Object {
+	switch operator {
+	case "+":
+		return normalizeInteger(new(big.Int).Add(left, right))
+	case "-":
+		return normalizeInteger(new(big.Int).Sub(left, right))
+	case "*":
+		return normalizeInteger(new(big.Int).Mul(left, right))
+	case "/":
+		if right.Sign() == 0 {
+			return newError(object.RUNTIME_ERROR, "division by zero")
+		}
+		// Quo truncates toward zero like division of 64 bit integers does
+		return normalizeInteger(new(big.Int).Quo(left, right))
+	case ">":
+		return referenceBoolObject(left.Cmp(right) > 0)
+	case "<":
+		return referenceBoolObject(left.Cmp(right) < 0)
+	case "==":
+		return referenceBoolObject(left.Cmp(right) == 0)
+	case "!=":
+		return referenceBoolObject(left.Cmp(right) != 0)
+	default:
+		return newError(object.TYPE_ERROR, "unknown operator: %s %s %s", object.BIG_INT, operator, object.BIG_INT)
+	}
+}
+
+func evalMinusPrefixOperator(expression object.Object) object.Object {
+	switch expression := expression.(type) {
+	case *object.Integer:
+		if expression.Value == math.MinInt64 {
+			return normalizeInteger(new(big.Int).Neg(big.NewInt(expression.Value)))
+		}
+		return &object.Integer{Value: -expression.Value}
+	case *object.BigInt:
+		return normalizeInteger(new(big.Int).Neg(expression.Value))
+	case *object.Decimal:
+		return expression.Neg()
+	case *object.Rational:
+		return &object.Rational{Value: new(big.Rat).Neg(expression.Value)}
+	default:
+		return newError(object.TYPE_ERROR, "unknown operator: -%s", expression.Type())
+	}
+}
+
+func toBigInt(obj object.Object) *big.Int {
+	if integer, ok := obj.(*object.Integer); ok {
+		return big.NewInt(integer.Value)
+	}
+	return obj.(*object.BigInt).Value
+}
+
+// normalizeInteger keeps values that fit in 64 bits as plain integers so
+// arbitrary precision is only paid for where it is needed.
+func normalizeInteger(value *big.Int) object.Object {
+	if value.IsInt64() {
+		return &object.Integer{Value: value.Int64()}
+	}
+	return &object.BigInt{Value: value}
+}
+
+// evalNumberInfixExpression handles arithmetic involving decimals or
+// rationals. Integers mixed with either take the other operand's type, and
+// decimals mixed with rationals become rationals so nothing is rounded.
+func evalNumberInfixExpression(operator string, left, right object.Object, env *object.Environment) object.Object {
+	if left.Type() == object.RATIONAL || right.Type() == object.RATIONAL {
+		return evalRationalInfixExpression(operator, toRat(left), toRat(right))
+	}
+
+	leftValue, rightValue := toDecimal(left), toDecimal(right)
+
+	switch operator {
+	case "+":
+		return leftValue.Add(rightValue)
+	case "-":
+		return leftValue.Sub(rightValue)
+	case "*":
+		return leftValue.Mul(rightValue)
+	case "/":
+		if rightValue.Unscaled.Sign() == 0 {
+			return newError(object.RUNTIME_ERROR, "division by zero")
+		}
+		runtime := env.Runtime()
+		return leftValue.Quo(rightValue, runtime.DecimalPlaces, runtime.Rounding)
+	case ">":
+		return referenceBoolObject(leftValue.Cmp(rightValue) > 0)
+	case "<":
+		return referenceBoolObject(leftValue.Cmp(rightValue) < 0)
+	case "==":
+		return referenceBoolObject(leftValue.Cmp(rightValue) == 0)
+	case "!=":
+		return referenceBoolObject(leftValue.Cmp(rightValue) != 0)
+	default:
+		return newError(object.TYPE_ERROR, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func evalRationalInfixExpression(operator string, left, right *big.Rat) object.Object {
+	switch operator {
+	case "+":
+		return &object.Rational{Value: new(big.Rat).Add(left, right)}
+	case "-":
+		return &object.Rational{Value: new(big.Rat).Sub(left, right)}
+	case "*":
+		return &object.Rational{Value: new(big.Rat).Mul(left, right)}
+	case "/":
+		if right.Sign() == 0 {
+			return newError(object.RUNTIME_ERROR, "division by zero")
+		}
+		return &object.Rational{Value: new(big.Rat).Quo(left, right)}
+	case ">":
+		return referenceBoolObject(left.Cmp(right) > 0)
+	case "<":
+		return referenceBoolObject(left.Cmp(right) < 0)
+	case "==":
+		return referenceBoolObject(left.Cmp(right) == 0)
+	case "!=":
+		return referenceBoolObject(left.Cmp(right) != 0)
+	default:
+		return newError(object.TYPE_ERROR, "unknown operator: %s %s %s", object.RATIONAL, operator, object.RATIONAL)
+	}
+}
+
+// toDecimal converts an integer or decimal to a decimal.
+func toDecimal(obj object.Object) *object.Decimal {
+	if decimal, ok := obj.(*object.Decimal); ok {
+		return decimal
+	}
+	return &object.Decimal{Unscaled: toBigInt(obj), Scale: 0}
+}
+
+// toRat converts any number to an exact rational.
+func toRat(obj object.Object) *big.Rat {
+	switch obj := obj.(type) {
+	case *object.Rational:
+		return obj.Value
+	case *object.Decimal:
+		return obj.Rat()
+	default:
+		return new(big.Rat).SetInt(toBigInt(obj))
+	}
+}
+
+// roundingMode reads an optional rounding mode argument, falling back to the
+// interpreter's default.
+func roundingMode(env *object.Environment, name string, args []object.Object, index int) (object.RoundingMode, *object.Error) {
+	if len(args) <= index {
+		return env.Runtime().Rounding, nil
+	}
+	text, ok := args[index].(*object.String)
+	if !ok {
+		return 0, newError(object.TYPE_ERROR, "rounding mode given to `%s` must be STRING, got %s", name, args[index].Type())
+	}
+	mode, ok := object.RoundingModes[text.Value]
+	if !ok {
+		return 0, newError(object.ARGUMENT_ERROR, "unknown rounding mode %q", text.Value)
+	}
+	return mode, nil
+}
+
+// decimalBuiltin converts integers, rationals and strings to decimals.
+// Rationals that do not terminate are rounded like decimal division.
+func decimalBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `decimal`, expected 1, got %d", len(args))
+	}
+
+	switch argument := args[0].(type) {
+	case *object.Decimal:
+		return argument
+	case *object.Integer, *object.BigInt:
+		return toDecimal(argument)
+	case *object.Rational:
+		runtime := env.Runtime()
+		return object.DecimalFromRat(argument.Value, runtime.DecimalPlaces, runtime.Rounding).Trim(0)
+	case *object.String:
+		decimal, ok := object.ParseDecimal(argument.Value)
+		if !ok {
+			return newError(object.ARGUMENT_ERROR, "could not parse %q as decimal", argument.Value)
+		}
+		return decimal
+	default:
+		return newError(object.TYPE_ERROR, "argument to `decimal` not supported, got %s", args[0].Type())
+	}
+}
+
+// rationalBuiltin builds a rational from a numerator and denominator, from
+// any single number, or from a string such as "1/3".
+func rationalBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	switch len(args) {
+	case 1:
+		if text, ok := args[0].(*object.String); ok {
+			value, ok := new(big.Rat).SetString(text.Value)
+			if !ok {
+				return newError(object.ARGUMENT_ERROR, "could not parse %q as rational", text.Value)
+			}
+			return &object.Rational{Value: value}
+		}
+		if !isNumber(args[0]) {
+			return newError(object.TYPE_ERROR, "argument to `rational` not supported, got %s", args[0].Type())
+		}
+		return &object.Rational{Value: new(big.Rat).Set(toRat(args[0]))}
+	case 2:
+		if !isInteger(args[0]) || !isInteger(args[1]) {
+			return newError(object.TYPE_ERROR, "arguments to `rational` must be integers, got %s and %s", args[0].Type(), args[1].Type())
+		}
+		denominator := toBigInt(args[1])
+		if denominator.Sign() == 0 {
+			return newError(object.RUNTIME_ERROR, "division by zero")
+		}
+		return &object.Rational{Value: new(big.Rat).SetFrac(toBigInt(args[0]), denominator)}
+	default:
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `rational`, expected 1 or 2, got %d", len(args))
+	}
+}
+
+// maxRoundPlaces bounds the places round gives a decimal, more would take
+// the interpreter too long to build.
+const maxRoundPlaces = 1 << 20
+
+// roundBuiltin rounds any number to a decimal with exactly the given number
+// of places, so str(round(x, 2)) formats amounts of money.
+func roundBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 2 && len(args) != 3 {
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `round`, expected 2 or 3, got %d", len(args))
+	}
+	if !isNumber(args[0]) {
+		return newError(object.TYPE_ERROR, "first argument to `round` must be a number, got %s", args[0].Type())
+	}
+	places, ok := args[1].(*object.Integer)
+	if !ok || places.Value < 0 {
+		return newError(object.ARGUMENT_ERROR, "places given to `round` must be a non-negative INTEGER, got %s", args[1].Inspect())
+	}
+	if places.Value > maxRoundPlaces {
+		return newError(object.ARGUMENT_ERROR, "places given to `round` must be at most %d, got %d", maxRoundPlaces, places.Value)
+	}
+	mode, err := roundingMode(env, "round", args, 2)
+	if err != nil {
+		return err
+	}
+
+	if rational, ok := args[0].(*object.Rational); ok {
+		return object.DecimalFromRat(rational.Value, int(places.Value), mode)
+	}
+	return toDecimal(args[0]).Round(int(places.Value), mode)
+}
+
+func numeratorBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `numerator`, expected 1, got %d", len(args))
+	}
+	if !isNumber(args[0]) {
+		return newError(object.TYPE_ERROR, "argument to `numerator` must be a number, got %s", args[0].Type())
+	}
+	return normalizeInteger(new(big.Int).Set(toRat(args[0]).Num()))
+}
+
+func denominatorBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `denominator`, expected 1, got %d", len(args))
+	}
+	if !isNumber(args[0]) {
+		return newError(object.TYPE_ERROR, "argument to `denominator` must be a number, got %s", args[0].Type())
+	}
+	return normalizeInteger(new(big.Int).Set(toRat(args[0]).Denom()))
+}
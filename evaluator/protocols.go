@@ -0,0 +1,213 @@
+package evaluator
+
+import (
+	"github.com/noculture/plug/object"
+	"github.com/noculture/plug/token"
+)
+
+// Structs take part in the language's operators and builtins by defining
+// methods with these names. The evaluator tries them before its own rules.
+const (
+	addMethod    = "__add__"
+	subMethod    = "__sub__"
+	mulMethod    = "__mul__"
+	divMethod    = "__div__"
+	eqMethod     = "__eq__"
+	ltMethod     = "__lt__"
+	gtMethod     = "__gt__"
+	indexMethod  = "__index__"
+	lenMethod    = "__len__"
+	iterMethod   = "__iter__"
+	stringMethod = "__str__"
+)
+
+var operatorMethods = map[string]string{
+	"+":  addMethod,
+	"-":  subMethod,
+	"*":  mulMethod,
+	"/":  divMethod,
+	"==": eqMethod,
+	"<":  ltMethod,
+	">":  gtMethod,
+}
+
+// callMethod calls the named method when obj is an instance whose struct
+// defines it. The second result reports whether there was a method to call.
+func callMethod(env *object.Environment, obj object.Object, name string, call token.Token, args ...object.Object) (object.Object, bool) {
+	instance, ok := obj.(*object.Instance)
+	if !ok {
+		return nil, false
+	}
+
+	method, ok := boundMethod(instance, name)
+	if !ok {
+		return nil, false
+	}
+
+	return applyFunction(method, args, call, env), true
+}
+
+// evalOverloadedInfix dispatches an infix operator to the left operand's
+// method. Comparisons fall back to the right operand with the operator
+// flipped, must return a boolean, and `!=` is the negation of `__eq__`.
+func evalOverloadedInfix(operator string, left, right object.Object, call token.Token, env *object.Environment) (object.Object, bool) {
+	switch operator {
+	case "==":
+		return compareWith(env, eqMethod, left, right, call)
+	case "!=":
+		result, ok := compareWith(env, eqMethod, left, right, call)
+		if !ok || isError(result) {
+			return result, ok
+		}
+		return referenceBoolObject(!isTruthy(result)), true
+	case "<":
+		if result, ok := compareWith(env, ltMethod, left, right, call); ok {
+			return result, true
+		}
+		return compareWith(env, gtMethod, right, left, call)
+	case ">":
+		if result, ok := compareWith(env, gtMethod, left, right, call); ok {
+			return result, true
+		}
+		return compareWith(env, ltMethod, right, left, call)
+	}
+
+	name, ok := operatorMethods[operator]
+	if !ok {
+		return nil, false
+	}
+	return callMethod(env, left, name, call, right)
+}
+
+// compareWith calls a comparison method and checks that it returned a boolean.
+func compareWith(env *object.Environment, name string, obj, other object.Object, call token.Token) (object.Object, bool) {
+	result, ok := callMethod(env, obj, name, call, other)
+	if !ok || isError(result) {
+		return result, ok
+	}
+	if typeOf(result) != object.BOOLEAN {
+		return newError(object.TYPE_ERROR, "%s must return BOOLEAN, got %s", name, typeOf(result)), true
+	}
+	return result, true
+}
+
+// toString converts a value to the text print and str show for it.
+func toString(env *object.Environment, obj object.Object) object.Object {
+	result, ok := callMethod(env, obj, stringMethod, token.Token{})
+	if !ok {
+		return &object.String{Value: obj.Inspect()}
+	}
+	if isError(result) {
+		return result
+	}
+	if typeOf(result) != object.STRING {
+		return newError(object.TYPE_ERROR, "%s must return STRING, got %s", stringMethod, typeOf(result))
+	}
+	return result
+}
+
+// iteratorOf returns an Iterator over the elements of arrays, strings, channels,
+// iterators, the keys of hashes and instances that define __iter__, or an
+// error for anything else.
+func iteratorOf(env *object.Environment, iterable object.Object) object.Object {
+	switch iterable := iterable.(type) {
+	case *object.Iterator:
+		return iterable
+	case *object.Array:
+		index := 0
+		return &object.Iterator{Next: func() (object.Object, bool) {
+			if index >= len(iterable.Elements) {
+				return nil, false
+			}
+			index++
+			return iterable.Elements[index-1], true
+		}}
+	case *object.Hash:
+		// the keys, in the order they were added
+		pairs := iterable.Pairs()
+		index := 0
+		return &object.Iterator{Next: func() (object.Object, bool) {
+			if index >= len(pairs) {
+				return nil, false
+			}
+			index++
+			return pairs[index-1].Key, true
+		}}
+	case *object.Channel:
+		// receives until the channel is closed
+		return &object.Iterator{Next: func() (object.Object, bool) {
+			value, ok := <-iterable.Channel
+			return value, ok
+		}}
+	case *object.String:
+		characters := []rune(iterable.Value)
+		index := 0
+		return &object.Iterator{Next: func() (object.Object, bool) {
+			if index >= len(characters) {
+				return nil, false
+			}
+			index++
+			return &object.String{Value: string(characters[index-1])}, true
+		}}
+	}
+
+	elements, ok := callMethod(env, iterable, iterMethod, token.Token{})
+	if !ok {
+		return newError(object.TYPE_ERROR, "%s is not iterable", iterable.Type())
+	}
+	if isError(elements) {
+		return elements
+	}
+	if elements.Type() == object.INSTANCE {
+		return newError(object.TYPE_ERROR, "%s must not return an INSTANCE", iterMethod)
+	}
+	return iteratorOf(env, elements)
+}
+
+// iterate calls visit with every element of an iterable in order and stops
+// early when visit returns something other than nil. It returns an error if
+// the value cannot be iterated or fails part way, or whatever visit stopped with.
+func iterate(env *object.Environment, iterable object.Object, visit func(object.Object) object.Object) object.Object {
+	iterator := iteratorOf(env, iterable)
+	if isError(iterator) {
+		return iterator
+	}
+
+	next := iterator.(*object.Iterator).Next
+	for {
+		element, ok := next()
+		if !ok {
+			return nil
+		}
+		if isError(element) {
+			return element
+		}
+		if stop := visit(element); stop != nil {
+			return stop
+		}
+	}
+}
+
+// length is the value `len` reports for arrays, strings, hashes and instances.
+func length(env *object.Environment, obj object.Object) object.Object {
+	switch obj := obj.(type) {
+	case *object.Array:
+		return &object.Integer{Value: int64(len(obj.Elements))}
+	case *object.Hash:
+		return &object.Integer{Value: int64(obj.Len())}
+	case *object.String:
+		return &object.Integer{Value: int64(len(obj.Value))}
+	}
+
+	result, ok := callMethod(env, obj, lenMethod, token.Token{})
+	if !ok {
+		return newError(object.TYPE_ERROR, "argument to `len` not supported, got %s", obj.Type())
+	}
+	if isError(result) {
+		return result
+	}
+	if typeOf(result) != object.INTEGER {
+		return newError(object.TYPE_ERROR, "%s must return INTEGER, got %s", lenMethod, typeOf(result))
+	}
+	return result
+}
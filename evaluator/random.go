@@ -0,0 +1,106 @@
+package evaluator
+
+import (
+	"github.com/noculture/plug/object"
+	"math/big"
+)
+
+// The random module, imported as "std/random". Every interpreter draws from
+// its own source, so seeding one makes its results repeatable without
+// changing what any other interpreter gets.
+
+func seedBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `seed`, expected 1, got %d", len(args))
+	}
+	seed, ok := args[0].(*object.Integer)
+	if !ok {
+		return newError(object.TYPE_ERROR, "argument to `seed` must be INTEGER, got %s", args[0].Type())
+	}
+
+	env.Runtime().Random().Seed(seed.Value)
+	return NULL
+}
+
+// randomIntBuiltin returns an integer from lo to hi, both included.
+func randomIntBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `int`, expected 2, got %d", len(args))
+	}
+	for _, arg := range args {
+		if !isInteger(arg) {
+			return newError(object.TYPE_ERROR, "arguments to `int` must be integers, got %s", arg.Type())
+		}
+	}
+
+	low, high := toBigInt(args[0]), toBigInt(args[1])
+	if low.Cmp(high) > 0 {
+		return newError(object.ARGUMENT_ERROR, "lower bound of `int` must not be above the upper bound, got %s and %s", low, high)
+	}
+
+	span := new(big.Int).Sub(high, low)
+	span.Add(span, big.NewInt(1))
+	offset := new(big.Int).Rand(env.Runtime().Random(), span)
+	return normalizeInteger(offset.Add(offset, low))
+}
+
+func choiceBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `choice`, expected 1, got %d", len(args))
+	}
+	array, ok := args[0].(*object.Array)
+	if !ok {
+		return newError(object.TYPE_ERROR, "argument to `choice` must be ARRAY, got %s", args[0].Type())
+	}
+	if len(array.Elements) == 0 {
+		return newError(object.ARGUMENT_ERROR, "cannot choose from an empty array")
+	}
+
+	return array.Elements[env.Runtime().Random().Intn(len(array.Elements))]
+}
+
+// shuffleBuiltin returns the elements in a random order, the array it was
+// given is left as it was.
+func shuffleBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `shuffle`, expected 1, got %d", len(args))
+	}
+	array, ok := args[0].(*object.Array)
+	if !ok {
+		return newError(object.TYPE_ERROR, "argument to `shuffle` must be ARRAY, got %s", args[0].Type())
+	}
+
+	elements := append([]object.Object{}, array.Elements...)
+	env.Runtime().Random().Shuffle(len(elements), func(i, j int) {
+		elements[i], elements[j] = elements[j], elements[i]
+	})
+	return &object.Array{Elements: elements}
+}
+
+// sampleBuiltin picks k elements at different positions, in the order they
+// were picked.
+func sampleBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `sample`, expected 2, got %d", len(args))
+	}
+	array, ok := args[0].(*object.Array)
+	if !ok {
+		return newError(object.TYPE_ERROR, "first argument to `sample` must be ARRAY, got %s", args[0].Type())
+	}
+	count, ok := args[1].(*object.Integer)
+	if !ok {
+		return newError(object.TYPE_ERROR, "second argument to `sample` must be INTEGER, got %s", args[1].Type())
+	}
+	if count.Value < 0 || count.Value > int64(len(array.Elements)) {
+		return newError(object.ARGUMENT_ERROR, "cannot sample %d elements from an array of %d", count.Value, len(array.Elements))
+	}
+
+	// a partial shuffle, only the first count positions are settled
+	random := env.Runtime().Random()
+	elements := append([]object.Object{}, array.Elements...)
+	for i := 0; i < int(count.Value); i++ {
+		j := i + random.Intn(len(elements)-i)
+		elements[i], elements[j] = elements[j], elements[i]
+	}
+	return &object.Array{Elements: elements[:count.Value]}
+}
@@ -0,0 +1,136 @@
+package evaluator
+
+import (
+	"github.com/noculture/plug/ast"
+	"github.com/noculture/plug/lexer"
+	"github.com/noculture/plug/object"
+	"github.com/noculture/plug/parser"
+	"strings"
+)
+
+// evalBuiltin parses and evaluates source in the caller's scope, or in the
+// scope given as the second argument. Bindings the source makes stay in that
+// scope, and macros it defines can be used by later calls in the same scope.
+func evalBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `eval`, expected 1 or 2, got %d", len(args))
+	}
+
+	source, ok := args[0].(*object.String)
+	if !ok {
+		return newError(object.TYPE_ERROR, "first argument to `eval` must be STRING, got %s", args[0].Type())
+	}
+	scope := env
+	if len(args) == 2 {
+		chosen, ok := args[1].(*object.Scope)
+		if !ok {
+			return newError(object.TYPE_ERROR, "second argument to `eval` must be SCOPE, got %s", args[1].Type())
+		}
+		scope = chosen.Env
+	}
+
+	p := parser.New(lexer.New(source.Value))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return newError(object.SYNTAX_ERROR, "%s", strings.Join(p.Errors(), "; "))
+	}
+
+	DefineMacros(program, scope)
+	expanded, err := ExpandMacros(program, scope)
+	if err != nil {
+		return err
+	}
+
+	result := Eval(expanded, scope)
+	if result == nil {
+		return NULL
+	}
+	return result
+}
+
+// scopeBuiltin returns the caller's scope, or the scope a function was
+// defined in and closes over.
+func scopeBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	switch len(args) {
+	case 0:
+		return &object.Scope{Env: env}
+	case 1:
+		function, ok := args[0].(*object.Function)
+		if !ok {
+			return newError(object.TYPE_ERROR, "argument to `scope` must be FUNCTION, got %s", args[0].Type())
+		}
+		return &object.Scope{Env: function.Env}
+	default:
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `scope`, expected 0 or 1, got %d", len(args))
+	}
+}
+
+// newScopeBuiltin returns an empty top-level scope of the same interpreter,
+// where only builtins are visible.
+func newScopeBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `new_scope`, expected 0, got %d", len(args))
+	}
+	return &object.Scope{Env: object.NewEnvironmentWithRuntime(env.Runtime())}
+}
+
+// bindingsBuiltin lists the names visible from the caller's scope or the
+// given one, in sorted order. Builtins are not included.
+func bindingsBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	scope := env
+	switch len(args) {
+	case 0:
+	case 1:
+		chosen, ok := args[0].(*object.Scope)
+		if !ok {
+			return newError(object.TYPE_ERROR, "argument to `bindings` must be SCOPE, got %s", args[0].Type())
+		}
+		scope = chosen.Env
+	default:
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `bindings`, expected 0 or 1, got %d", len(args))
+	}
+
+	names := scope.Names()
+	elements := make([]object.Object, len(names))
+	for index, name := range names {
+		elements[index] = &object.String{Value: name}
+	}
+	return &object.Array{Elements: elements}
+}
+
+func typeBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `type`, expected 1, got %d", len(args))
+	}
+	return &object.String{Value: string(args[0].Type())}
+}
+
+// paramsBuiltin lists the parameter names of a function or macro.
+func paramsBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `params`, expected 1, got %d", len(args))
+	}
+
+	var parameters []*ast.Identifier
+	switch function := args[0].(type) {
+	case *object.Function:
+		parameters = function.Parameters
+	case *object.Macro:
+		parameters = function.Parameters
+	default:
+		return newError(object.TYPE_ERROR, "argument to `params` must be FUNCTION or MACRO, got %s", args[0].Type())
+	}
+
+	elements := make([]object.Object, len(parameters))
+	for index, parameter := range parameters {
+		elements[index] = &object.String{Value: parameter.Value}
+	}
+	return &object.Array{Elements: elements}
+}
+
+func isBuiltinBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `is_builtin`, expected 1, got %d", len(args))
+	}
+	return referenceBoolObject(args[0].Type() == object.BUILTIN)
+}
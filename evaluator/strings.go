@@ -0,0 +1,322 @@
+package evaluator
+
+import (
+	"fmt"
+	"github.com/noculture/plug/object"
+	"math/big"
+	"strings"
+	"unicode/utf8"
+)
+
+// The strings module, imported as "std/strings". Positions and widths are
+// counted in characters rather than bytes.
+
+// maxStringLength bounds the strings repeat and padding build, so a huge
+// count is an error instead of the interpreter running out of memory.
+const maxStringLength = 1 << 28
+
+// stringArguments checks that a builtin got the number of arguments it
+// expects and that the first ones are strings, returning their values.
+func stringArguments(name string, args []object.Object, minimum, maximum, strs int) ([]string, *object.Error) {
+	if len(args) < minimum || len(args) > maximum {
+		if minimum == maximum {
+			return nil, newError(object.ARGUMENT_ERROR, "invalid number of arguments to `%s`, expected %d, got %d", name, minimum, len(args))
+		}
+		return nil, newError(object.ARGUMENT_ERROR, "invalid number of arguments to `%s`, expected %d to %d, got %d", name, minimum, maximum, len(args))
+	}
+
+	var values []string
+	for index, arg := range args {
+		if index >= strs {
+			break
+		}
+		str, ok := arg.(*object.String)
+		if !ok {
+			return nil, newError(object.TYPE_ERROR, "argument %d to `%s` must be STRING, got %s", index+1, name, arg.Type())
+		}
+		values = append(values, str.Value)
+	}
+	return values, nil
+}
+
+func stringsOf(values []string) *object.Array {
+	elements := make([]object.Object, len(values))
+	for index, value := range values {
+		elements[index] = &object.String{Value: value}
+	}
+	return &object.Array{Elements: elements}
+}
+
+// splitBuiltin splits around every separator, or into characters when the
+// separator is empty.
+func splitBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	values, err := stringArguments("split", args, 2, 2, 2)
+	if err != nil {
+		return err
+	}
+	return stringsOf(strings.Split(values[0], values[1]))
+}
+
+func joinBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `join`, expected 2, got %d", len(args))
+	}
+	array, ok := args[0].(*object.Array)
+	if !ok {
+		return newError(object.TYPE_ERROR, "argument 1 to `join` must be ARRAY, got %s", args[0].Type())
+	}
+	separator, ok := args[1].(*object.String)
+	if !ok {
+		return newError(object.TYPE_ERROR, "argument 2 to `join` must be STRING, got %s", args[1].Type())
+	}
+
+	parts := make([]string, len(array.Elements))
+	for index, element := range array.Elements {
+		str, ok := element.(*object.String)
+		if !ok {
+			return newError(object.TYPE_ERROR, "elements joined by `join` must be STRING, got %s", element.Type())
+		}
+		parts[index] = str.Value
+	}
+	return &object.String{Value: strings.Join(parts, separator.Value)}
+}
+
+// trimBuiltin removes white space from both ends, or any of the given
+// characters when there is a second argument.
+func trimBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	values, err := stringArguments("trim", args, 1, 2, 2)
+	if err != nil {
+		return err
+	}
+	if len(values) == 2 {
+		return &object.String{Value: strings.Trim(values[0], values[1])}
+	}
+	return &object.String{Value: strings.TrimSpace(values[0])}
+}
+
+// replaceBuiltin replaces every occurrence, or only the first n.
+func replaceBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	values, err := stringArguments("replace", args, 3, 4, 3)
+	if err != nil {
+		return err
+	}
+
+	limit := -1
+	if len(args) == 4 {
+		count, ok := args[3].(*object.Integer)
+		if !ok {
+			return newError(object.TYPE_ERROR, "argument 4 to `replace` must be INTEGER, got %s", args[3].Type())
+		}
+		limit = int(count.Value)
+	}
+	return &object.String{Value: strings.Replace(values[0], values[1], values[2], limit)}
+}
+
+func containsBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	values, err := stringArguments("contains", args, 2, 2, 2)
+	if err != nil {
+		return err
+	}
+	return referenceBoolObject(strings.Contains(values[0], values[1]))
+}
+
+func startsWithBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	values, err := stringArguments("starts_with", args, 2, 2, 2)
+	if err != nil {
+		return err
+	}
+	return referenceBoolObject(strings.HasPrefix(values[0], values[1]))
+}
+
+func endsWithBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	values, err := stringArguments("ends_with", args, 2, 2, 2)
+	if err != nil {
+		return err
+	}
+	return referenceBoolObject(strings.HasSuffix(values[0], values[1]))
+}
+
+func upperBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	values, err := stringArguments("upper", args, 1, 1, 1)
+	if err != nil {
+		return err
+	}
+	return &object.String{Value: strings.ToUpper(values[0])}
+}
+
+func lowerBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	values, err := stringArguments("lower", args, 1, 1, 1)
+	if err != nil {
+		return err
+	}
+	return &object.String{Value: strings.ToLower(values[0])}
+}
+
+func repeatBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	values, err := stringArguments("repeat", args, 2, 2, 1)
+	if err != nil {
+		return err
+	}
+	count, ok := args[1].(*object.Integer)
+	if !ok {
+		return newError(object.TYPE_ERROR, "argument 2 to `repeat` must be INTEGER, got %s", args[1].Type())
+	}
+	if count.Value < 0 {
+		return newError(object.ARGUMENT_ERROR, "count of `repeat` must not be negative, got %d", count.Value)
+	}
+	if count.Value > 0 && int64(len(values[0])) > maxStringLength/count.Value {
+		return newError(object.ARGUMENT_ERROR, "result of `repeat` would be longer than %d bytes", maxStringLength)
+	}
+	return &object.String{Value: strings.Repeat(values[0], int(count.Value))}
+}
+
+func padLeftBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	return pad("pad_left", args, true)
+}
+
+func padRightBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	return pad("pad_right", args, false)
+}
+
+// pad fills a string out to a width with spaces or the given character.
+func pad(name string, args []object.Object, left bool) object.Object {
+	values, err := stringArguments(name, args, 2, 3, 1)
+	if err != nil {
+		return err
+	}
+	width, ok := args[1].(*object.Integer)
+	if !ok {
+		return newError(object.TYPE_ERROR, "argument 2 to `%s` must be INTEGER, got %s", name, args[1].Type())
+	}
+
+	fill := " "
+	if len(args) == 3 {
+		str, ok := args[2].(*object.String)
+		if !ok {
+			return newError(object.TYPE_ERROR, "argument 3 to `%s` must be STRING, got %s", name, args[2].Type())
+		}
+		if utf8.RuneCountInString(str.Value) != 1 {
+			return newError(object.ARGUMENT_ERROR, "fill of `%s` must be a single character, got %q", name, str.Value)
+		}
+		fill = str.Value
+	}
+
+	missing := width.Value - int64(utf8.RuneCountInString(values[0]))
+	if missing <= 0 {
+		return args[0]
+	}
+	if missing > maxStringLength/int64(len(fill)) {
+		return newError(object.ARGUMENT_ERROR, "result of `%s` would be longer than %d bytes", name, maxStringLength)
+	}
+	if left {
+		return &object.String{Value: strings.Repeat(fill, int(missing)) + values[0]}
+	}
+	return &object.String{Value: values[0] + strings.Repeat(fill, int(missing))}
+}
+
+// indexOfBuiltin returns the character position of the first occurrence, or -1.
+func indexOfBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	values, err := stringArguments("index_of", args, 2, 2, 2)
+	if err != nil {
+		return err
+	}
+
+	index := strings.Index(values[0], values[1])
+	if index < 0 {
+		return &object.Integer{Value: -1}
+	}
+	return &object.Integer{Value: int64(utf8.RuneCountInString(values[0][:index]))}
+}
+
+// formatBuiltin formats its arguments the way Go's fmt.Sprintf does. Each verb
+// takes the Plug value converted to what it formats: integers for %d, %x, %o,
+// %b and %c, any number for %f, %e and %g, booleans for %t, and the text
+// `str` gives for a value for %s, %q and %v.
+func formatBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) == 0 {
+		return newError(object.ARGUMENT_ERROR, "invalid number of arguments to `format`, expected at least 1, got 0")
+	}
+	layout, ok := args[0].(*object.String)
+	if !ok {
+		return newError(object.TYPE_ERROR, "argument 1 to `format` must be STRING, got %s", args[0].Type())
+	}
+
+	var out strings.Builder
+	values := args[1:]
+	used := 0
+	text := layout.Value
+
+	for index := 0; index < len(text); index++ {
+		if text[index] != '%' {
+			out.WriteByte(text[index])
+			continue
+		}
+
+		// the verb is the first letter after the flags, width and precision
+		end := index + 1
+		for end < len(text) && strings.IndexByte("+-# 0123456789.", text[end]) >= 0 {
+			end++
+		}
+		if end == len(text) {
+			return newError(object.ARGUMENT_ERROR, "`format` string ends in the middle of a verb")
+		}
+		spec, verb := text[index:end+1], text[end]
+		index = end
+
+		if verb == '%' {
+			out.WriteByte('%')
+			continue
+		}
+		if used == len(values) {
+			return newError(object.ARGUMENT_ERROR, "`format` has more verbs than the %d values given", len(values))
+		}
+
+		value, err := formatValue(env, verb, values[used])
+		if err != nil {
+			return err
+		}
+		used++
+		out.WriteString(fmt.Sprintf(spec, value))
+	}
+
+	if used != len(values) {
+		return newError(object.ARGUMENT_ERROR, "`format` has %d verbs but was given %d values", used, len(values))
+	}
+	return &object.String{Value: out.String()}
+}
+
+// formatValue converts a Plug value to the Go value a verb formats.
+func formatValue(env *object.Environment, verb byte, value object.Object) (interface{}, object.Object) {
+	switch verb {
+	case 'd', 'x', 'X', 'o', 'b', 'c':
+		switch value := value.(type) {
+		case *object.Integer:
+			return value.Value, nil
+		case *object.BigInt:
+			if verb != 'c' {
+				return value.Value, nil
+			}
+		}
+		return nil, newError(object.TYPE_ERROR, "%%%c in `format` needs an INTEGER, got %s", verb, value.Type())
+	case 'f', 'F', 'e', 'E', 'g', 'G':
+		if !isNumber(value) {
+			return nil, newError(object.TYPE_ERROR, "%%%c in `format` needs a number, got %s", verb, value.Type())
+		}
+		// exact enough that decimals print the digits they hold
+		return new(big.Float).SetPrec(256).SetRat(toRat(value)), nil
+	case 't':
+		boolean, ok := value.(*object.Boolean)
+		if !ok {
+			return nil, newError(object.TYPE_ERROR, "%%t in `format` needs a BOOLEAN, got %s", value.Type())
+		}
+		return boolean.Value, nil
+	case 's', 'q', 'v':
+		text := toString(env, value)
+		if isError(text) {
+			return nil, text
+		}
+		return text.(*object.String).Value, nil
+	default:
+		return nil, newError(object.ARGUMENT_ERROR, "unknown verb %%%c in `format`", verb)
+	}
+}
@@ -7,10 +7,12 @@ type Lexer struct {
 	currentPosition int
 	readPosition    int
 	currentChar     byte
+	line            int
+	column          int
 }
 
 func New(input string) *Lexer {
-	lexer := &Lexer{input: input}
+	lexer := &Lexer{input: input, line: 1}
 	lexer.readChar()
 	return lexer
 }
@@ -19,6 +21,7 @@ func (lexer *Lexer) NextToken() token.Token {
 	var tok token.Token
 
 	lexer.skipWhitespace()
+	line, column := lexer.line, lexer.column
 
 	switch lexer.currentChar {
 	case '=':
@@ -27,6 +30,11 @@ func (lexer *Lexer) NextToken() token.Token {
 			lexer.readChar()
 			literal := string(character) + string(lexer.currentChar)
 			tok = token.Token{Type: token.EQ, Literal: literal}
+		} else if lexer.peekChar() == '>' {
+			character := lexer.currentChar
+			lexer.readChar()
+			literal := string(character) + string(lexer.currentChar)
+			tok = token.Token{Type: token.ARROW, Literal: literal}
 		} else {
 			tok = newToken(token.ASSIGN, lexer.currentChar)
 		}
@@ -65,6 +73,10 @@ func (lexer *Lexer) NextToken() token.Token {
 		tok = newToken(token.RBRACKET, lexer.currentChar)
 	case ',':
 		tok = newToken(token.COMMA, lexer.currentChar)
+	case ':':
+		tok = newToken(token.COLON, lexer.currentChar)
+	case '.':
+		tok = newToken(token.DOT, lexer.currentChar)
 	case ';':
 		tok = newToken(token.SEMICOLON, lexer.currentChar)
 	case '"':
@@ -77,10 +89,11 @@ func (lexer *Lexer) NextToken() token.Token {
 		if isLetter(lexer.currentChar) {
 			tok.Literal = lexer.readIdentifier()
 			tok.Type = token.LookUpIdentifier(tok.Literal)
+			tok.Line, tok.Column = line, column
 			return tok
 		} else if isDigit(lexer.currentChar) {
-			tok.Type = token.INT
-			tok.Literal = lexer.readNumber()
+			tok.Type, tok.Literal = lexer.readNumber()
+			tok.Line, tok.Column = line, column
 			return tok
 		} else {
 			tok = newToken(token.ILLEGAL, lexer.currentChar)
@@ -88,6 +101,7 @@ func (lexer *Lexer) NextToken() token.Token {
 	}
 
 	lexer.readChar()
+	tok.Line, tok.Column = line, column
 	return tok
 }
 
@@ -106,6 +120,13 @@ func (lexer *Lexer) peekChar() byte {
 }
 
 func (lexer *Lexer) readChar() {
+	// keep track of where we are so tokens can report their position
+	if lexer.currentChar == '\n' {
+		lexer.line++
+		lexer.column = 0
+	}
+	lexer.column++
+
 	if lexer.readPosition >= len(lexer.input) {
 		lexer.currentChar = 0
 	} else {
@@ -128,20 +149,31 @@ func isDigit(character byte) bool {
 	return '0' <= character && character <= '9'
 }
 
+// readIdentifier reads a letter followed by any letters and digits.
 func (lexer *Lexer) readIdentifier() string {
 	position := lexer.currentPosition
-	for isLetter(lexer.currentChar) {
+	for isLetter(lexer.currentChar) || isDigit(lexer.currentChar) {
 		lexer.readChar()
 	}
 	return lexer.input[position:lexer.currentPosition]
 }
 
-func (lexer *Lexer) readNumber() string {
+// readNumber reads an integer, or a decimal when the digits are followed by
+// a dot and more digits.
+func (lexer *Lexer) readNumber() (token.Type, string) {
 	position := lexer.currentPosition
+	numberType := token.Type(token.INT)
 	for isDigit(lexer.currentChar) {
 		lexer.readChar()
 	}
-	return lexer.input[position:lexer.currentPosition]
+	if lexer.currentChar == '.' && isDigit(lexer.peekChar()) {
+		numberType = token.DECIMAL
+		lexer.readChar()
+		for isDigit(lexer.currentChar) {
+			lexer.readChar()
+		}
+	}
+	return numberType, lexer.input[position:lexer.currentPosition]
 }
 
 func (lexer *Lexer) readString() string {
@@ -29,6 +29,12 @@ if (5 < 10) {
 "foobar"
 "foo bar"
 [1, 2];
+struct point.x
+enum match =>
+spawn async await macro
+import export as
+log10 x_2
+12.50 x.y:
 `
 
 	tests := []struct {
@@ -116,6 +122,27 @@ if (5 < 10) {
 		{token.INT, "2"},
 		{token.RBRACKET, "]"},
 		{token.SEMICOLON, ";"},
+		{token.STRUCT, "struct"},
+		{token.IDENTIFIER, "point"},
+		{token.DOT, "."},
+		{token.IDENTIFIER, "x"},
+		{token.ENUM, "enum"},
+		{token.MATCH, "match"},
+		{token.ARROW, "=>"},
+		{token.SPAWN, "spawn"},
+		{token.ASYNC, "async"},
+		{token.AWAIT, "await"},
+		{token.MACRO, "macro"},
+		{token.IMPORT, "import"},
+		{token.EXPORT, "export"},
+		{token.AS, "as"},
+		{token.IDENTIFIER, "log10"},
+		{token.IDENTIFIER, "x_2"},
+		{token.DECIMAL, "12.50"},
+		{token.IDENTIFIER, "x"},
+		{token.DOT, "."},
+		{token.IDENTIFIER, "y"},
+		{token.COLON, ":"},
 		{token.EOF, ""},
 	}
 
@@ -135,3 +162,44 @@ if (5 < 10) {
 		}
 	}
 }
+
+func TestTokenPositions(t *testing.T) {
+	input := `let x = 5;
+  try {
+	throw x;
+}`
+
+	tests := []struct {
+		expectedType   token.Type
+		expectedLine   int
+		expectedColumn int
+	}{
+		{token.LET, 1, 1},
+		{token.IDENTIFIER, 1, 5},
+		{token.ASSIGN, 1, 7},
+		{token.INT, 1, 9},
+		{token.SEMICOLON, 1, 10},
+		{token.TRY, 2, 3},
+		{token.LBRACE, 2, 7},
+		{token.THROW, 3, 2},
+		{token.IDENTIFIER, 3, 8},
+		{token.SEMICOLON, 3, 9},
+		{token.RBRACE, 4, 1},
+	}
+
+	lexer := New(input)
+
+	for index, testToken := range tests {
+		resultToken := lexer.NextToken()
+
+		if resultToken.Type != testToken.expectedType {
+			t.Fatalf("tests[%d] - tokentype is wrong, expected=%q, got=%q",
+				index, testToken.expectedType, resultToken.Type)
+		}
+
+		if resultToken.Line != testToken.expectedLine || resultToken.Column != testToken.expectedColumn {
+			t.Fatalf("tests[%d] - position is wrong, expected=%d:%d, got=%d:%d", index,
+				testToken.expectedLine, testToken.expectedColumn, resultToken.Line, resultToken.Column)
+		}
+	}
+}
@@ -2,8 +2,14 @@ package main
 
 import (
 	"fmt"
+	"github.com/noculture/plug/checker"
+	"github.com/noculture/plug/lexer"
+	"github.com/noculture/plug/object"
+	"github.com/noculture/plug/parser"
 	"github.com/noculture/plug/repl"
 	"github.com/noculture/plug/scanner"
+	"io"
+	"io/ioutil"
 	"log"
 	"os"
 	"os/user"
@@ -16,16 +22,55 @@ func main() {
 			panic(err)
 		}
 		fmt.Printf("Hello %s! This is the Plug programming language!\n", person.Username)
-		repl.Start(os.Stdin, os.Stdout)
+		os.Exit(repl.Start(os.Stdin, os.Stdout))
+	} else if os.Args[1] == "check" {
+		os.Exit(check(os.Args[2:], os.Stdout))
 	} else {
 		filename := os.Args[1]
 		file, err := os.Open(filename)
-		defer file.Close()
-
 		if err != nil {
 			log.Fatal("unable to read file")
 		}
 
-		scanner.Start(file, os.Stdout)
+		status := scanner.StartFile(filename, os.Args[2:], file, object.NewRuntime())
+		file.Close()
+		os.Exit(status)
+	}
+}
+
+// check type checks each file without running it, reporting every problem
+// as file:line:column. It returns the exit status, 1 if anything was found.
+func check(filenames []string, out io.Writer) int {
+	if len(filenames) == 0 {
+		_, _ = io.WriteString(out, "usage: plug check FILE...\n")
+		return 2
 	}
+
+	status := 0
+	for _, filename := range filenames {
+		source, err := ioutil.ReadFile(filename)
+		if err != nil {
+			_, _ = fmt.Fprintf(out, "%s: unable to read file\n", filename)
+			status = 1
+			continue
+		}
+
+		p := parser.New(lexer.New(string(source)))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			for _, message := range p.Errors() {
+				_, _ = fmt.Fprintf(out, "%s: %s\n", filename, message)
+			}
+			status = 1
+			continue
+		}
+
+		// the checker's messages start with the line and column
+		for _, problem := range checker.Check(program) {
+			_, _ = fmt.Fprintf(out, "%s:%s\n", filename, problem)
+			status = 1
+		}
+	}
+
+	return status
 }
@@ -0,0 +1,205 @@
+package object
+
+import (
+	"math/big"
+	"strings"
+)
+
+// RoundingMode decides which way a number is rounded when digits have to
+// be dropped.
+type RoundingMode int
+
+const (
+	RoundHalfEven RoundingMode = iota // to the nearest, ties to the even neighbour
+	RoundHalfUp                       // to the nearest, ties away from zero
+	RoundHalfDown                     // to the nearest, ties toward zero
+	RoundUp                           // away from zero
+	RoundDown                         // toward zero
+	RoundCeiling                      // toward positive infinity
+	RoundFloor                        // toward negative infinity
+)
+
+// RoundingModes maps the names Plug programs use to rounding modes.
+var RoundingModes = map[string]RoundingMode{
+	"half_even": RoundHalfEven,
+	"half_up":   RoundHalfUp,
+	"half_down": RoundHalfDown,
+	"up":        RoundUp,
+	"down":      RoundDown,
+	"ceiling":   RoundCeiling,
+	"floor":     RoundFloor,
+}
+
+// Decimal is an exact base 10 number, Unscaled × 10^-Scale. The scale is
+// part of the value as written, so 2.50 keeps both places when printed.
+type Decimal struct {
+	Unscaled *big.Int
+	Scale    int
+}
+
+// ParseDecimal reads a number such as "12", "-0.05" or "3.14159".
+func ParseDecimal(text string) (*Decimal, bool) {
+	digits := strings.TrimPrefix(strings.TrimPrefix(text, "-"), "+")
+	whole, fraction := digits, ""
+	if point := strings.Index(digits, "."); point >= 0 {
+		whole, fraction = digits[:point], digits[point+1:]
+	}
+	if whole == "" && fraction == "" {
+		return nil, false
+	}
+	for _, character := range whole + fraction {
+		if character < '0' || character > '9' {
+			return nil, false
+		}
+	}
+
+	unscaled, ok := new(big.Int).SetString(whole+fraction, 10)
+	if !ok {
+		return nil, false
+	}
+	if strings.HasPrefix(text, "-") {
+		unscaled.Neg(unscaled)
+	}
+	return &Decimal{Unscaled: unscaled, Scale: len(fraction)}, true
+}
+
+// DecimalFromRat rounds a rational number to the given number of places.
+func DecimalFromRat(value *big.Rat, scale int, mode RoundingMode) *Decimal {
+	numerator := new(big.Int).Mul(value.Num(), pow10(scale))
+	return &Decimal{Unscaled: roundQuotient(numerator, value.Denom(), mode), Scale: scale}
+}
+
+func (d *Decimal) Type() Type { return DECIMAL }
+func (d *Decimal) Inspect() string {
+	digits := new(big.Int).Abs(d.Unscaled).String()
+	if d.Scale > 0 {
+		if len(digits) <= d.Scale {
+			digits = strings.Repeat("0", d.Scale-len(digits)+1) + digits
+		}
+		digits = digits[:len(digits)-d.Scale] + "." + digits[len(digits)-d.Scale:]
+	}
+	if d.Unscaled.Sign() < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+// Rat returns the exact value of the decimal as a rational number.
+func (d *Decimal) Rat() *big.Rat {
+	return new(big.Rat).SetFrac(d.Unscaled, pow10(d.Scale))
+}
+
+func (d *Decimal) Add(other *Decimal) *Decimal {
+	scale := maxInt(d.Scale, other.Scale)
+	return &Decimal{Unscaled: new(big.Int).Add(d.rescaled(scale), other.rescaled(scale)), Scale: scale}
+}
+
+func (d *Decimal) Sub(other *Decimal) *Decimal {
+	scale := maxInt(d.Scale, other.Scale)
+	return &Decimal{Unscaled: new(big.Int).Sub(d.rescaled(scale), other.rescaled(scale)), Scale: scale}
+}
+
+func (d *Decimal) Mul(other *Decimal) *Decimal {
+	return &Decimal{Unscaled: new(big.Int).Mul(d.Unscaled, other.Unscaled), Scale: d.Scale + other.Scale}
+}
+
+// Quo divides exactly when the quotient fits in the given number of places
+// and rounds to that many places when it does not. The result keeps at
+// least the places of either operand.
+func (d *Decimal) Quo(other *Decimal, places int, mode RoundingMode) *Decimal {
+	quotient := DecimalFromRat(new(big.Rat).Quo(d.Rat(), other.Rat()), places, mode)
+	return quotient.Trim(maxInt(d.Scale, other.Scale))
+}
+
+func (d *Decimal) Neg() *Decimal {
+	return &Decimal{Unscaled: new(big.Int).Neg(d.Unscaled), Scale: d.Scale}
+}
+
+func (d *Decimal) Cmp(other *Decimal) int {
+	scale := maxInt(d.Scale, other.Scale)
+	return d.rescaled(scale).Cmp(other.rescaled(scale))
+}
+
+// Round returns the decimal with exactly the given number of places,
+// padding with zeros or rounding as needed.
+func (d *Decimal) Round(scale int, mode RoundingMode) *Decimal {
+	if scale >= d.Scale {
+		return &Decimal{Unscaled: d.rescaled(scale), Scale: scale}
+	}
+	return &Decimal{Unscaled: roundQuotient(d.Unscaled, pow10(d.Scale-scale), mode), Scale: scale}
+}
+
+// rescaled returns the unscaled value for a scale at least as large as d's.
+func (d *Decimal) rescaled(scale int) *big.Int {
+	return new(big.Int).Mul(d.Unscaled, pow10(scale-d.Scale))
+}
+
+// Trim drops trailing zeros from the fraction, keeping at least minimum places.
+func (d *Decimal) Trim(minimum int) *Decimal {
+	unscaled, scale := new(big.Int).Set(d.Unscaled), d.Scale
+	ten, remainder := big.NewInt(10), new(big.Int)
+	for scale > minimum {
+		quotient, _ := new(big.Int).QuoRem(unscaled, ten, remainder)
+		if remainder.Sign() != 0 {
+			break
+		}
+		unscaled, scale = quotient, scale-1
+	}
+	return &Decimal{Unscaled: unscaled, Scale: scale}
+}
+
+// roundQuotient divides numerator by denominator, rounding the way mode says.
+func roundQuotient(numerator, denominator *big.Int, mode RoundingMode) *big.Int {
+	quotient, remainder := new(big.Int).QuoRem(numerator, denominator, new(big.Int))
+	if remainder.Sign() == 0 {
+		return quotient
+	}
+
+	sign := numerator.Sign() * denominator.Sign()
+	// compare the dropped part with one half
+	half := new(big.Int).Abs(remainder)
+	half.Mul(half, big.NewInt(2))
+	half.Sub(half, new(big.Int).Abs(denominator))
+
+	var away bool
+	switch mode {
+	case RoundHalfEven:
+		away = half.Sign() > 0 || (half.Sign() == 0 && quotient.Bit(0) == 1)
+	case RoundHalfUp:
+		away = half.Sign() >= 0
+	case RoundHalfDown:
+		away = half.Sign() > 0
+	case RoundUp:
+		away = true
+	case RoundDown:
+		away = false
+	case RoundCeiling:
+		away = sign > 0
+	case RoundFloor:
+		away = sign < 0
+	}
+
+	if away {
+		quotient.Add(quotient, big.NewInt(int64(sign)))
+	}
+	return quotient
+}
+
+func pow10(exponent int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exponent)), nil)
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// Rational is an exact fraction, always kept in lowest terms.
+type Rational struct {
+	Value *big.Rat
+}
+
+func (r *Rational) Type() Type      { return RATIONAL }
+func (r *Rational) Inspect() string { return r.Value.RatString() }
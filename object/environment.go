@@ -1,23 +1,155 @@
 package object
 
+import (
+	"sort"
+	"sync"
+)
+
+// Environment is safe for concurrent use, spawned functions share the scopes
+// they close over with the code that spawned them.
 type Environment struct {
-	store map[string]Object
-	outer *Environment
+	mu      sync.RWMutex
+	store   map[string]Object
+	outer   *Environment
+	runtime *Runtime
+	depth   int      // the number of function calls this scope is nested in
+	file    string   // the file whose top level this scope is, if any
+	imports []string // the imports that led to evaluating this scope, outermost first
+
+	// hands a value to whoever is consuming the generator running in this scope
+	yield func(Object)
+	// suspends the async function running in this scope until a promise settles
+	await func(*Promise) Object
 }
 
 func NewEnvironment() *Environment {
+	return NewEnvironmentWithRuntime(NewRuntime())
+}
+
+func NewEnvironmentWithRuntime(runtime *Runtime) *Environment {
 	s := make(map[string]Object)
-	return &Environment{store: s, outer: nil}
+	return &Environment{store: s, outer: nil, runtime: runtime}
+}
+
+// NewFileEnvironment creates the top-level scope of a file, imports made
+// from it are resolved relative to the file's directory.
+func NewFileEnvironment(runtime *Runtime, file string) *Environment {
+	env := NewEnvironmentWithRuntime(runtime)
+	env.file = file
+	return env
 }
 
 func NewEnclosedEvironment(outer *Environment) *Environment {
-	env := NewEnvironment()
+	env := NewEnvironmentWithRuntime(outer.runtime)
 	env.outer = outer
+	env.depth = outer.depth
+	return env
+}
+
+// NewCallEnvironment creates the scope for a function call. The outer scope
+// is where the function was defined, the depth comes from where it was called.
+func NewCallEnvironment(outer *Environment, depth int) *Environment {
+	env := NewEnclosedEvironment(outer)
+	env.depth = depth
 	return env
 }
 
+// SetYield makes this the scope of a running generator, yield statements
+// evaluated in it pass their value to the given function.
+func (env *Environment) SetYield(yield func(Object)) {
+	env.yield = yield
+}
+
+// Yield passes a value out of the closest enclosing generator, it reports
+// false when the scope is not inside one.
+func (env *Environment) Yield(value Object) bool {
+	for scope := env; scope != nil; scope = scope.outer {
+		if scope.yield != nil {
+			scope.yield(value)
+			return true
+		}
+	}
+	return false
+}
+
+// SetAwait makes this the scope of a running async function, await
+// expressions evaluated in it suspend through the given function.
+func (env *Environment) SetAwait(await func(*Promise) Object) {
+	env.await = await
+}
+
+// Await suspends the closest enclosing async function until the promise
+// settles and returns its result. It reports false when the scope is not
+// inside an async function.
+func (env *Environment) Await(promise *Promise) (Object, bool) {
+	for scope := env; scope != nil; scope = scope.outer {
+		if scope.await != nil {
+			return scope.await(promise), true
+		}
+	}
+	return nil, false
+}
+
+// Names returns every name visible from this scope, in sorted order.
+func (env *Environment) Names() []string {
+	seen := map[string]bool{}
+	for scope := env; scope != nil; scope = scope.outer {
+		scope.mu.RLock()
+		for name := range scope.store {
+			seen[name] = true
+		}
+		scope.mu.RUnlock()
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// File returns the file the scope belongs to, or "" for source that was not
+// read from a file.
+func (env *Environment) File() string {
+	for scope := env; scope != nil; scope = scope.outer {
+		if scope.file != "" {
+			return scope.file
+		}
+	}
+	return ""
+}
+
+// SetImports records the chain of imports that led to evaluating this
+// top-level scope, outermost first and ending with its own file.
+func (env *Environment) SetImports(chain []string) {
+	env.imports = chain
+}
+
+// Imports returns the chain of imports that led to the scope, which an
+// import of any file in it would complete into a cycle.
+func (env *Environment) Imports() []string {
+	for scope := env; scope != nil; scope = scope.outer {
+		if scope.imports != nil {
+			return scope.imports
+		}
+	}
+	return nil
+}
+
+func (env *Environment) Runtime() *Runtime {
+	return env.runtime
+}
+
+func (env *Environment) Depth() int {
+	return env.depth
+}
+
 func (env *Environment) Get(name string) (Object, bool) {
+	env.mu.RLock()
 	object, ok := env.store[name]
+	env.mu.RUnlock()
+
 	if !ok && env.outer != nil {
 		// check the parent scope
 		object, ok = env.outer.Get(name)
@@ -25,7 +157,28 @@ func (env *Environment) Get(name string) (Object, bool) {
 	return object, ok
 }
 
+// Assign rebinds an existing name in the closest scope that defines it.
+func (env *Environment) Assign(name string, value Object) bool {
+	env.mu.Lock()
+	_, ok := env.store[name]
+	if ok {
+		env.store[name] = value
+	}
+	env.mu.Unlock()
+
+	if ok {
+		return true
+	}
+	if env.outer != nil {
+		return env.outer.Assign(name, value)
+	}
+	return false
+}
+
 func (env *Environment) Set(name string, value Object) Object {
+	env.mu.Lock()
 	env.store[name] = value
+	env.mu.Unlock()
+
 	return value
 }
@@ -0,0 +1,172 @@
+package object
+
+import (
+	"sync"
+	"time"
+)
+
+// EventLoop runs the callbacks behind async functions and timers one at a
+// time, on whichever goroutine is waiting for a promise to settle. With a
+// virtual clock timers fire in order without any real waiting, so programs
+// run the same way every time.
+type EventLoop struct {
+	mu      sync.Mutex
+	virtual bool
+	start   time.Time
+	now     time.Duration // only advanced by a virtual clock
+	ready   []func()
+	timers  []timer // sorted by when they are due, ties in the order they were added
+
+	rejected []*Promise // rejected before anything handled them
+	stopped  *Error     // why the loop stopped for good, if it has
+}
+
+type timer struct {
+	due      time.Duration
+	callback func()
+}
+
+func NewEventLoop(virtual bool) *EventLoop {
+	return &EventLoop{virtual: virtual, start: time.Now()}
+}
+
+// NewPromise creates a promise whose rejection the loop reports from
+// Unhandled when nothing handles it.
+func (loop *EventLoop) NewPromise() *Promise {
+	return &Promise{loop: loop}
+}
+
+func (loop *EventLoop) reject(promise *Promise) {
+	loop.mu.Lock()
+	defer loop.mu.Unlock()
+
+	loop.rejected = append(loop.rejected, promise)
+}
+
+// Unhandled returns the errors of the loop's promises that were rejected
+// without anything handling them so far, each of them once.
+func (loop *EventLoop) Unhandled() []*Error {
+	loop.mu.Lock()
+	rejected := loop.rejected
+	loop.rejected = nil
+	loop.mu.Unlock()
+
+	var errors []*Error
+	for _, promise := range rejected {
+		promise.mu.Lock()
+		if !promise.handled {
+			promise.handled = true
+			errors = append(errors, promise.result.(*Error))
+		}
+		promise.mu.Unlock()
+	}
+	return errors
+}
+
+// Now is the time elapsed on the loop's clock since it was created.
+func (loop *EventLoop) Now() time.Duration {
+	loop.mu.Lock()
+	defer loop.mu.Unlock()
+
+	return loop.elapsed()
+}
+
+func (loop *EventLoop) elapsed() time.Duration {
+	if loop.virtual {
+		return loop.now
+	}
+	return time.Since(loop.start)
+}
+
+// Schedule queues a callback to run once the callbacks before it have.
+func (loop *EventLoop) Schedule(callback func()) {
+	loop.mu.Lock()
+	loop.ready = append(loop.ready, callback)
+	loop.mu.Unlock()
+}
+
+// After queues a callback to run once the delay has passed on the loop's clock.
+func (loop *EventLoop) After(delay time.Duration, callback func()) {
+	loop.mu.Lock()
+	defer loop.mu.Unlock()
+
+	due := loop.elapsed() + delay
+	index := len(loop.timers)
+	for index > 0 && loop.timers[index-1].due > due {
+		index--
+	}
+	loop.timers = append(loop.timers, timer{})
+	copy(loop.timers[index+1:], loop.timers[index:])
+	loop.timers[index] = timer{due: due, callback: callback}
+}
+
+// Stop stops the loop for good because of an error that ends the program
+// wherever it happens, such as a call to exit. Work still queued never runs.
+func (loop *EventLoop) Stop(err *Error) {
+	loop.mu.Lock()
+	defer loop.mu.Unlock()
+
+	if loop.stopped == nil {
+		loop.stopped = err
+	}
+}
+
+// Stopped returns the error the loop was stopped with, or nil.
+func (loop *EventLoop) Stopped() *Error {
+	loop.mu.Lock()
+	defer loop.mu.Unlock()
+
+	return loop.stopped
+}
+
+// RunUntil runs queued callbacks, then due timers, until done reports true.
+// It reports false if the loop ran out of work or was stopped before that.
+func (loop *EventLoop) RunUntil(done func() bool) bool {
+	for !done() {
+		callback, ok := loop.next()
+		if !ok {
+			return false
+		}
+		callback()
+	}
+	return true
+}
+
+// Run runs callbacks and timers until the loop is out of work.
+func (loop *EventLoop) Run() {
+	loop.RunUntil(func() bool { return false })
+}
+
+func (loop *EventLoop) next() (func(), bool) {
+	loop.mu.Lock()
+
+	if loop.stopped != nil {
+		loop.mu.Unlock()
+		return nil, false
+	}
+
+	if len(loop.ready) > 0 {
+		callback := loop.ready[0]
+		loop.ready = loop.ready[1:]
+		loop.mu.Unlock()
+		return callback, true
+	}
+
+	if len(loop.timers) == 0 {
+		loop.mu.Unlock()
+		return nil, false
+	}
+
+	next := loop.timers[0]
+	loop.timers = loop.timers[1:]
+	wait := next.due - loop.elapsed()
+	if loop.virtual && wait > 0 {
+		loop.now = next.due
+	}
+	loop.mu.Unlock()
+
+	if !loop.virtual && wait > 0 {
+		time.Sleep(wait)
+	}
+	return next.callback, true
+}
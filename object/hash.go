@@ -0,0 +1,111 @@
+package object
+
+import (
+	"bytes"
+	"hash/fnv"
+	"strings"
+)
+
+// HashKey identifies a key of a Hash. Keys of different types never collide,
+// keys of the same type and value always match.
+type HashKey struct {
+	Type  Type
+	Value uint64
+}
+
+// Hashable is implemented by the values that can be used as keys of a Hash.
+type Hashable interface {
+	HashKey() HashKey
+}
+
+func (integer *Integer) HashKey() HashKey {
+	return HashKey{Type: integer.Type(), Value: uint64(integer.Value)}
+}
+
+func (bool *Boolean) HashKey() HashKey {
+	if bool.Value {
+		return HashKey{Type: bool.Type(), Value: 1}
+	}
+	return HashKey{Type: bool.Type(), Value: 0}
+}
+
+func (str *String) HashKey() HashKey {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(str.Value))
+	return HashKey{Type: str.Type(), Value: h.Sum64()}
+}
+
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+// Hash maps keys to values and remembers the order keys were first added in,
+// which is the order it is printed and iterated in. Hashes are not changed
+// once they are built, so they can be shared between spawned functions.
+type Hash struct {
+	buckets map[HashKey][]int // the positions in pairs of the keys with each hash key
+	pairs   []HashPair
+}
+
+func NewHash() *Hash {
+	return &Hash{buckets: map[HashKey][]int{}}
+}
+
+// Set adds or replaces the value for a key while the hash is being built.
+func (hash *Hash) Set(key Hashable, value Object) {
+	pair := HashPair{Key: key.(Object), Value: value}
+	if index, ok := hash.find(key); ok {
+		hash.pairs[index] = pair
+		return
+	}
+
+	hashKey := key.HashKey()
+	hash.buckets[hashKey] = append(hash.buckets[hashKey], len(hash.pairs))
+	hash.pairs = append(hash.pairs, pair)
+}
+
+func (hash *Hash) Get(key Hashable) (Object, bool) {
+	if index, ok := hash.find(key); ok {
+		return hash.pairs[index].Value, true
+	}
+	return nil, false
+}
+
+// find returns the position of a key. Different strings can share a hash
+// key, so strings are compared as well.
+func (hash *Hash) find(key Hashable) (int, bool) {
+	str, isString := key.(*String)
+	for _, index := range hash.buckets[key.HashKey()] {
+		other, otherIsString := hash.pairs[index].Key.(*String)
+		if !isString || (otherIsString && other.Value == str.Value) {
+			return index, true
+		}
+	}
+	return 0, false
+}
+
+// Pairs returns the keys and values in the order the keys were added.
+func (hash *Hash) Pairs() []HashPair {
+	return append([]HashPair{}, hash.pairs...)
+}
+
+func (hash *Hash) Len() int {
+	return len(hash.pairs)
+}
+
+func (hash *Hash) Type() Type { return HASH }
+func (hash *Hash) Inspect() string {
+	var out bytes.Buffer
+	var pairs []string
+
+	for _, pair := range hash.Pairs() {
+		pairs = append(pairs, pair.Key.Inspect()+": "+pair.Value.Inspect())
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
@@ -0,0 +1,30 @@
+package object
+
+import "testing"
+
+func TestHashCollidingStrings(t *testing.T) {
+	hash := NewHash()
+	first, second := &String{Value: "first"}, &String{Value: "second"}
+	hash.Set(first, &Integer{Value: 1})
+
+	// make the second string land in the first one's bucket, as it would
+	// if their hash keys collided
+	hash.buckets[second.HashKey()] = hash.buckets[first.HashKey()]
+	hash.Set(second, &Integer{Value: 2})
+
+	if hash.Len() != 2 {
+		t.Fatalf("colliding strings should be separate keys, got %s", hash.Inspect())
+	}
+	for _, pair := range []struct {
+		key      *String
+		expected int64
+	}{{first, 1}, {second, 2}} {
+		value, ok := hash.Get(pair.key)
+		if !ok || value.(*Integer).Value != pair.expected {
+			t.Errorf("%s should map to %d, got %v", pair.key.Value, pair.expected, value)
+		}
+	}
+	if _, ok := hash.Get(&String{Value: "third"}); ok {
+		t.Errorf("a missing key should not be found")
+	}
+}
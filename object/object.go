@@ -4,7 +4,10 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/noculture/plug/ast"
+	"github.com/noculture/plug/token"
+	"math/big"
 	"strings"
+	"sync"
 )
 
 type Type string
@@ -17,8 +20,44 @@ const (
 	FUNCTION     = "FUNCTION"
 	RETURN_VALUE = "RETURN_VALUE"
 	ARRAY        = "ARRAY"
+	HASH         = "HASH"
 	ERROR        = "ERROR"
+	EXCEPTION    = "EXCEPTION"
 	BUILTIN      = "BUILTIN"
+	TAIL_CALL    = "TAIL_CALL"
+	STRUCT       = "STRUCT"
+	INSTANCE     = "INSTANCE"
+	ENUM         = "ENUM"
+	CONSTRUCTOR  = "CONSTRUCTOR"
+	VARIANT      = "VARIANT"
+	ITERATOR     = "ITERATOR"
+	TASK         = "TASK"
+	CHANNEL      = "CHANNEL"
+	PROMISE      = "PROMISE"
+	BIG_INT      = "BIG_INT"
+	DECIMAL      = "DECIMAL"
+	RATIONAL     = "RATIONAL"
+	QUOTE        = "QUOTE"
+	MACRO        = "MACRO"
+	SCOPE        = "SCOPE"
+	MODULE       = "MODULE"
+)
+
+// Kinds of errors raised by the interpreter. Errors thrown from Plug code
+// with `throw` use THROWN_ERROR unless they rethrow a caught error.
+const (
+	RUNTIME_ERROR   = "RuntimeError"
+	TYPE_ERROR      = "TypeError"
+	NAME_ERROR      = "NameError"
+	ARGUMENT_ERROR  = "ArgumentError"
+	RECURSION_ERROR = "RecursionError"
+	THROWN_ERROR    = "Error"
+	SYNTAX_ERROR    = "SyntaxError"
+	DOMAIN_ERROR    = "DomainError"
+	IO_ERROR        = "IOError"
+
+	// EXIT unwinds a program that called `exit`, it is never caught
+	EXIT = "Exit"
 )
 
 type Object interface {
@@ -27,9 +66,12 @@ type Object interface {
 }
 
 type Function struct {
+	Name       string // the name the function was first bound to, if any
 	Parameters []*ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
+	Generator  bool // calling a generator returns an Iterator over what it yields
+	Async      bool // calling an async function returns a Promise of its result
 }
 
 func (fn *Function) Type() Type { return FUNCTION }
@@ -58,6 +100,17 @@ type ReturnValue struct {
 func (rValue *ReturnValue) Type() Type      { return RETURN_VALUE }
 func (rValue *ReturnValue) Inspect() string { return rValue.Value.Inspect() }
 
+// TailCall is a call in tail position that has been evaluated up to the
+// point of applying it. It never escapes the function it was made in.
+type TailCall struct {
+	Function  Object
+	Arguments []Object
+	Call      token.Token
+}
+
+func (tc *TailCall) Type() Type      { return TAIL_CALL }
+func (tc *TailCall) Inspect() string { return "tail call" }
+
 type Integer struct {
 	Value int64
 }
@@ -65,6 +118,15 @@ type Integer struct {
 func (int *Integer) Type() Type      { return INTEGER }
 func (int *Integer) Inspect() string { return fmt.Sprintf("%d", int.Value) }
 
+// BigInt holds integers that do not fit in an Integer. Arithmetic moves to
+// it when a result overflows and back to Integer once a result fits again.
+type BigInt struct {
+	Value *big.Int
+}
+
+func (bigInt *BigInt) Type() Type      { return BIG_INT }
+func (bigInt *BigInt) Inspect() string { return bigInt.Value.String() }
+
 type String struct {
 	Value string
 }
@@ -99,19 +161,438 @@ func (arr *Array) Inspect() string {
 	return out.String()
 }
 
+// Struct is a declared struct type. Calling it creates an Instance with the
+// fields set from the arguments in the order they were declared.
+type Struct struct {
+	Name    string
+	Fields  []string
+	Methods map[string]*Function
+}
+
+func (s *Struct) Type() Type { return STRUCT }
+func (s *Struct) Inspect() string {
+	return "struct " + s.Name + " {" + strings.Join(s.Fields, ", ") + "}"
+}
+
+// HasField reports whether the field was declared on the struct.
+func (s *Struct) HasField(name string) bool {
+	for _, field := range s.Fields {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Instance fields are guarded so instances can be shared between spawned functions.
+type Instance struct {
+	Struct *Struct
+	mu     sync.RWMutex
+	fields map[string]Object
+}
+
+func NewInstance(structure *Struct, fields map[string]Object) *Instance {
+	return &Instance{Struct: structure, fields: fields}
+}
+
+func (instance *Instance) Field(name string) (Object, bool) {
+	instance.mu.RLock()
+	defer instance.mu.RUnlock()
+
+	value, ok := instance.fields[name]
+	return value, ok
+}
+
+func (instance *Instance) SetField(name string, value Object) {
+	instance.mu.Lock()
+	defer instance.mu.Unlock()
+
+	instance.fields[name] = value
+}
+
+func (instance *Instance) Type() Type { return INSTANCE }
+func (instance *Instance) Inspect() string {
+	var out bytes.Buffer
+	var fields []string
+
+	for _, name := range instance.Struct.Fields {
+		value, _ := instance.Field(name)
+		fields = append(fields, name+": "+value.Inspect())
+	}
+
+	out.WriteString(instance.Struct.Name)
+	out.WriteString("{")
+	out.WriteString(strings.Join(fields, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// Enum is a declared enum type. Its variants are reached with `Enum.Variant`,
+// which gives the value itself for plain variants and a constructor for
+// variants that carry a payload.
+type Enum struct {
+	Name     string
+	Variants []*VariantConstructor
+}
+
+func (enum *Enum) Type() Type { return ENUM }
+func (enum *Enum) Inspect() string {
+	var variants []string
+
+	for _, variant := range enum.Variants {
+		variants = append(variants, variant.signature())
+	}
+
+	return "enum " + enum.Name + " {" + strings.Join(variants, ", ") + "}"
+}
+
+// Variant finds the variant with the given name.
+func (enum *Enum) Variant(name string) (*VariantConstructor, bool) {
+	for _, variant := range enum.Variants {
+		if variant.Name == name {
+			return variant, true
+		}
+	}
+	return nil, false
+}
+
+type VariantConstructor struct {
+	Enum   *Enum
+	Name   string
+	Fields []string
+	Unit   *Variant // the only value of a variant without fields
+}
+
+func (vc *VariantConstructor) Type() Type      { return CONSTRUCTOR }
+func (vc *VariantConstructor) Inspect() string { return vc.Enum.Name + "." + vc.signature() }
+func (vc *VariantConstructor) signature() string {
+	if len(vc.Fields) == 0 {
+		return vc.Name
+	}
+	return vc.Name + "(" + strings.Join(vc.Fields, ", ") + ")"
+}
+
+type Variant struct {
+	Constructor *VariantConstructor
+	Values      []Object
+}
+
+func (variant *Variant) Type() Type { return VARIANT }
+func (variant *Variant) Inspect() string {
+	var out bytes.Buffer
+	var values []string
+
+	for _, value := range variant.Values {
+		values = append(values, value.Inspect())
+	}
+
+	out.WriteString(variant.Constructor.Enum.Name + "." + variant.Constructor.Name)
+	if len(variant.Constructor.Fields) > 0 {
+		out.WriteString("(")
+		out.WriteString(strings.Join(values, ", "))
+		out.WriteString(")")
+	}
+
+	return out.String()
+}
+
+// Iterator produces values one at a time without materialising them. Next
+// reports false once there are no more values, an Error value ends iteration.
+type Iterator struct {
+	Next func() (Object, bool)
+}
+
+func (it *Iterator) Type() Type      { return ITERATOR }
+func (it *Iterator) Inspect() string { return "iterator" }
+
+// Task is a function running on its own goroutine, started with `spawn`.
+type Task struct {
+	done   chan struct{}
+	result Object
+}
+
+func NewTask() *Task {
+	return &Task{done: make(chan struct{})}
+}
+
+func (task *Task) Type() Type      { return TASK }
+func (task *Task) Inspect() string { return "task" }
+
+// Finish records the task's result and releases everyone waiting on it.
+func (task *Task) Finish(result Object) {
+	task.result = result
+	close(task.done)
+}
+
+// Wait blocks until the task has finished and returns its result. A failed
+// task gives each waiter its own copy of the error, since waiters add to its
+// stack and position as it unwinds through them.
+func (task *Task) Wait() Object {
+	<-task.done
+	if err, ok := task.result.(*Error); ok {
+		copied := *err
+		copied.Stack = append([]Frame(nil), err.Stack...)
+		return &copied
+	}
+	return task.result
+}
+
+// Channel passes values between spawned functions.
+type Channel struct {
+	Channel chan Object
+}
+
+func (ch *Channel) Type() Type      { return CHANNEL }
+func (ch *Channel) Inspect() string { return fmt.Sprintf("channel(%d)", cap(ch.Channel)) }
+
+// Send reports false instead of panicking when the channel is closed.
+func (ch *Channel) Send(value Object) (sent bool) {
+	defer func() {
+		if recover() != nil {
+			sent = false
+		}
+	}()
+
+	ch.Channel <- value
+	return true
+}
+
+// Close reports false instead of panicking when the channel is already closed.
+func (ch *Channel) Close() (closed bool) {
+	defer func() {
+		if recover() != nil {
+			closed = false
+		}
+	}()
+
+	close(ch.Channel)
+	return true
+}
+
+// Promise is the eventual result of an async function or timer. It settles
+// once, with an Error if what it stands for failed.
+type Promise struct {
+	mu        sync.Mutex
+	settled   bool
+	handled   bool // whether anything has waited for the result
+	result    Object
+	callbacks []func(Object)
+	loop      *EventLoop // reports the promise's rejection if nothing handles it
+}
+
+func NewPromise() *Promise {
+	return &Promise{}
+}
+
+func (p *Promise) Type() Type { return PROMISE }
+func (p *Promise) Inspect() string {
+	if result, ok := p.Result(); ok {
+		return "promise(" + result.Inspect() + ")"
+	}
+	return "promise(pending)"
+}
+
+// Settle records the result and calls everything waiting on it. Only the
+// first call has any effect.
+func (p *Promise) Settle(result Object) {
+	p.mu.Lock()
+	if p.settled {
+		p.mu.Unlock()
+		return
+	}
+	p.settled, p.result = true, result
+	callbacks := p.callbacks
+	p.callbacks = nil
+	unhandled := !p.handled
+	p.mu.Unlock()
+
+	if _, rejected := result.(*Error); rejected && unhandled && p.loop != nil {
+		p.loop.reject(p)
+	}
+
+	for _, callback := range callbacks {
+		callback(result)
+	}
+}
+
+// Then calls the callback with the result once the promise settles, straight
+// away if it already has.
+func (p *Promise) Then(callback func(Object)) {
+	p.mu.Lock()
+	p.handled = true
+	if !p.settled {
+		p.callbacks = append(p.callbacks, callback)
+		p.mu.Unlock()
+		return
+	}
+	p.mu.Unlock()
+
+	callback(p.result)
+}
+
+// Handle marks the result as dealt with by something other than Then, such
+// as a program awaiting the promise at its top level.
+func (p *Promise) Handle() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.handled = true
+}
+
+// Result returns the result and whether the promise has settled yet.
+func (p *Promise) Result() (Object, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.result, p.settled
+}
+
+// Settled reports whether the promise has a result yet.
+func (p *Promise) Settled() bool {
+	_, settled := p.Result()
+	return settled
+}
+
+// Quote is a piece of unevaluated syntax, made with `quote` and handed to
+// and returned from macros.
+type Quote struct {
+	Node ast.Node
+}
+
+func (quote *Quote) Type() Type      { return QUOTE }
+func (quote *Quote) Inspect() string { return "QUOTE(" + quote.Node.String() + ")" }
+
+type Macro struct {
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Environment
+}
+
+func (macro *Macro) Type() Type { return MACRO }
+func (macro *Macro) Inspect() string {
+	var out bytes.Buffer
+	var params []string
+
+	for _, param := range macro.Parameters {
+		params = append(params, param.String())
+	}
+
+	out.WriteString("macro")
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") \n")
+	out.WriteString(macro.Body.String())
+	out.WriteString("\n")
+
+	return out.String()
+}
+
+// Scope lets Plug code hold on to an environment, to evaluate source in it
+// or list what it binds.
+type Scope struct {
+	Env *Environment
+}
+
+func (scope *Scope) Type() Type      { return SCOPE }
+func (scope *Scope) Inspect() string { return "scope" }
+
+// Module is an imported file. Only the names it exports can be reached
+// through it.
+type Module struct {
+	Name    string
+	Path    string
+	Exports map[string]Object
+}
+
+func (module *Module) Type() Type      { return MODULE }
+func (module *Module) Inspect() string { return "module " + module.Name }
+
 type Null struct{}
 
 func (null *Null) Type() Type      { return NULL }
 func (null *Null) Inspect() string { return fmt.Sprintf("null") }
 
+// Error unwinds evaluation until it reaches a try statement or the top of
+// the program.
 type Error struct {
 	Message string
+	Kind    string
+	Line    int
+	Column  int
+	Value   Object  // the value passed to `throw`, nil for runtime errors
+	Status  int     // the status passed to `exit`, for EXIT errors
+	Stack   []Frame // the calls the error unwound through, innermost first
 }
 
 func (e *Error) Type() Type      { return ERROR }
 func (e *Error) Inspect() string { return "Error: " + e.Message }
 
-type BuiltinFunction func(args ...Object) Object
+// Frame is a single function call an error passed through on its way out.
+type Frame struct {
+	Function  string
+	Line      int
+	Column    int
+	Arguments int
+}
+
+func (frame Frame) String() string {
+	name := frame.Function
+	if name == "" {
+		name = "<anonymous>"
+	}
+
+	plural := "s"
+	if frame.Arguments == 1 {
+		plural = ""
+	}
+
+	// calls made from builtins have no position in the source
+	if frame.Line == 0 {
+		return fmt.Sprintf("at %s(%d arg%s)", name, frame.Arguments, plural)
+	}
+	return fmt.Sprintf("at %s(%d arg%s) %d:%d", name, frame.Arguments, plural, frame.Line, frame.Column)
+}
+
+// tracebackLimit is the number of frames shown at each end of a long stack,
+// the frames in between are summarised on a single line
+const tracebackLimit = 10
+
+// Traceback renders the error along with the calls it unwound through.
+func (e *Error) Traceback() string {
+	var out bytes.Buffer
+
+	out.WriteString(e.Inspect())
+	if e.Line > 0 {
+		out.WriteString(fmt.Sprintf(" [%d:%d]", e.Line, e.Column))
+	}
+	out.WriteString("\n")
+
+	for index, frame := range e.Stack {
+		if len(e.Stack) > 2*tracebackLimit && index >= tracebackLimit && index < len(e.Stack)-tracebackLimit {
+			if index == tracebackLimit {
+				out.WriteString(fmt.Sprintf("    ... %d more frames\n", len(e.Stack)-2*tracebackLimit))
+			}
+			continue
+		}
+		out.WriteString("    " + frame.String() + "\n")
+	}
+
+	return out.String()
+}
+
+// Exception is a caught Error. It is an ordinary value so it can be bound,
+// passed around and inspected without unwinding the program again.
+type Exception struct {
+	Error *Error
+}
+
+func (ex *Exception) Type() Type      { return EXCEPTION }
+func (ex *Exception) Inspect() string { return ex.Error.Kind + ": " + ex.Error.Message }
+
+// BuiltinFunction receives the environment it was called from so it can call
+// back into Plug functions.
+type BuiltinFunction func(env *Environment, args ...Object) Object
 
 type Builtin struct {
 	Function BuiltinFunction
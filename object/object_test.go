@@ -0,0 +1,41 @@
+package object
+
+import "testing"
+
+func TestTaskWaitCopiesErrors(t *testing.T) {
+	task := NewTask()
+	task.Finish(&Error{Message: "failed", Kind: RUNTIME_ERROR, Stack: []Frame{{Function: "inner"}}})
+
+	first, second := task.Wait().(*Error), task.Wait().(*Error)
+	if first == second {
+		t.Fatalf("every waiter should get its own error")
+	}
+
+	first.Stack = append(first.Stack, Frame{Function: "waiter"})
+	first.Line = 3
+	if len(second.Stack) != 1 || second.Line != 0 {
+		t.Errorf("one waiter's traceback leaked into another's, got %+v", second)
+	}
+	if second.Message != "failed" || second.Kind != RUNTIME_ERROR {
+		t.Errorf("the copy should keep the error, got %+v", second)
+	}
+}
+
+func TestEventLoopUnhandledRejections(t *testing.T) {
+	loop := NewEventLoop(true)
+	lost, awaited, later, plain := loop.NewPromise(), loop.NewPromise(), loop.NewPromise(), NewPromise()
+
+	awaited.Then(func(Object) {})
+	for _, promise := range []*Promise{lost, awaited, later, plain} {
+		promise.Settle(&Error{Message: "failed"})
+	}
+	later.Handle()
+
+	unhandled := loop.Unhandled()
+	if len(unhandled) != 1 || unhandled[0] != lost.result {
+		t.Fatalf("only the rejection nothing handled should be reported, got %+v", unhandled)
+	}
+	if again := loop.Unhandled(); len(again) != 0 {
+		t.Errorf("a rejection should be reported once, got %+v", again)
+	}
+}
@@ -0,0 +1,185 @@
+package object
+
+import (
+	"bufio"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMaxCallDepth is deep enough for any reasonable recursion while
+// keeping the Go stack well clear of its own limit.
+const DefaultMaxCallDepth = 10000
+
+// DefaultDecimalPlaces is how many places a decimal division that does not
+// come out exact is rounded to.
+const DefaultDecimalPlaces = 20
+
+// Runtime holds the settings shared by every environment of one interpreter.
+// Hosts embedding Plug can adjust it before evaluating a program.
+type Runtime struct {
+	// MaxCallDepth is the number of nested function calls allowed before
+	// evaluation fails with a recursion error, 0 means no limit
+	MaxCallDepth int
+
+	// DecimalPlaces and Rounding control decimal division and conversions
+	// of rationals to decimals
+	DecimalPlaces int
+	Rounding      RoundingMode
+
+	// VirtualClock makes timers fire as soon as nothing else is left to run,
+	// in the order they are due, without waiting in real time
+	VirtualClock bool
+
+	// ModulePath lists the directories searched for imports that are not
+	// found next to the importing file, taken from PLUG_PATH by default
+	ModulePath []string
+
+	// FileRoots lists the directories std/fs may read and change, including
+	// everything below them. Nil allows any path, an empty list none
+	FileRoots []string
+
+	// Stdin is read by `input` and `read_lines`, Stdout is written by
+	// `print` and Stderr by `eprint`. They are the process's standard
+	// streams by default
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	loopOnce sync.Once
+	loop     *EventLoop
+
+	modulesMu sync.Mutex
+	modules   map[string]*Module
+
+	randomOnce sync.Once
+	random     *rand.Rand
+
+	inputMu sync.Mutex
+	input   *bufio.Reader
+
+	outputMu sync.Mutex // keeps what spawned functions print from interleaving
+}
+
+func NewRuntime() *Runtime {
+	return &Runtime{
+		MaxCallDepth:  DefaultMaxCallDepth,
+		DecimalPlaces: DefaultDecimalPlaces,
+		Rounding:      RoundHalfEven,
+		ModulePath:    filepath.SplitList(os.Getenv("PLUG_PATH")),
+		Stdin:         os.Stdin,
+		Stdout:        os.Stdout,
+		Stderr:        os.Stderr,
+	}
+}
+
+// WriteOut writes text to Stdout.
+func (runtime *Runtime) WriteOut(text string) error {
+	return runtime.write(runtime.Stdout, text)
+}
+
+// WriteErr writes text to Stderr.
+func (runtime *Runtime) WriteErr(text string) error {
+	return runtime.write(runtime.Stderr, text)
+}
+
+func (runtime *Runtime) write(out io.Writer, text string) error {
+	runtime.outputMu.Lock()
+	defer runtime.outputMu.Unlock()
+
+	_, err := io.WriteString(out, text)
+	return err
+}
+
+// ReadLine returns the next line of Stdin without its line ending, or
+// io.EOF once there is nothing left to read.
+func (runtime *Runtime) ReadLine() (string, error) {
+	runtime.inputMu.Lock()
+	defer runtime.inputMu.Unlock()
+
+	if runtime.input == nil {
+		runtime.input = bufio.NewReader(runtime.Stdin)
+	}
+	line, err := runtime.input.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+	return line, err
+}
+
+// Random returns the interpreter's own source of random numbers, seeded from
+// the clock the first time it is needed. Seeding it makes every later use
+// repeatable without affecting other interpreters.
+func (runtime *Runtime) Random() *rand.Rand {
+	runtime.randomOnce.Do(func() {
+		source := rand.NewSource(time.Now().UnixNano()).(rand.Source64)
+		runtime.random = rand.New(&lockedSource{source: source})
+	})
+	return runtime.random
+}
+
+// lockedSource lets functions spawned by the same interpreter share its
+// random numbers.
+type lockedSource struct {
+	mu     sync.Mutex
+	source rand.Source64
+}
+
+func (s *lockedSource) Int63() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.source.Int63()
+}
+
+func (s *lockedSource) Uint64() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.source.Uint64()
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.source.Seed(seed)
+}
+
+// Module returns the already imported module for a file.
+func (runtime *Runtime) Module(path string) (*Module, bool) {
+	runtime.modulesMu.Lock()
+	defer runtime.modulesMu.Unlock()
+
+	module, ok := runtime.modules[path]
+	return module, ok
+}
+
+// AddModule caches the module imported from a file so the file is never
+// evaluated again. Functions spawned at the same time can both import a file
+// before either has finished, the first module added is the one every later
+// import gets, and AddModule returns it.
+func (runtime *Runtime) AddModule(path string, module *Module) *Module {
+	runtime.modulesMu.Lock()
+	defer runtime.modulesMu.Unlock()
+
+	if cached, ok := runtime.modules[path]; ok {
+		return cached
+	}
+	if runtime.modules == nil {
+		runtime.modules = map[string]*Module{}
+	}
+	runtime.modules[path] = module
+	return module
+}
+
+// EventLoop returns the loop running this interpreter's async functions,
+// creating it the first time it is needed.
+func (runtime *Runtime) EventLoop() *EventLoop {
+	runtime.loopOnce.Do(func() {
+		runtime.loop = NewEventLoop(runtime.VirtualClock)
+	})
+	return runtime.loop
+}
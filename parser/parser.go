@@ -1,17 +1,21 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
 	"github.com/noculture/plug/ast"
 	"github.com/noculture/plug/lexer"
 	"github.com/noculture/plug/token"
+	"math/big"
 	"strconv"
+	"strings"
 )
 
 // The arrangement of the following constants indicates their order of precedence
 const (
 	_ int = iota // give the following constants incrementing values from 0
 	LOWEST
+	ASSIGN
 	EQUALS
 	LESSGREATER
 	SUM
@@ -22,6 +26,7 @@ const (
 )
 
 var precedenceTable = map[token.Type]int{
+	token.ASSIGN:   ASSIGN,
 	token.EQ:       EQUALS,
 	token.NOT_EQ:   EQUALS,
 	token.LT:       LESSGREATER,
@@ -32,6 +37,7 @@ var precedenceTable = map[token.Type]int{
 	token.ASTERISK: PRODUCT,
 	token.LPAREN:   CALL,
 	token.LBRACKET: INDEX,
+	token.DOT:      INDEX,
 }
 
 type Parser struct {
@@ -43,6 +49,15 @@ type Parser struct {
 
 	prefixParseFuncs map[token.Type]prefixParseFunc
 	infixParseFuncs  map[token.Type]infixParseFunc
+
+	// enums declared and matches made in the program, kept so every match
+	// can be checked for exhaustiveness once the whole program is parsed
+	enums   map[string]*ast.EnumStatement
+	matches []*ast.MatchExpression
+
+	// the functions being parsed, innermost last, so yield and await know
+	// which function they belong to
+	functions []*ast.FunctionLiteral
 }
 
 type (
@@ -51,7 +66,7 @@ type (
 )
 
 func New(lexer *lexer.Lexer) *Parser {
-	parser := &Parser{lexer: lexer, errors: []string{}}
+	parser := &Parser{lexer: lexer, errors: []string{}, enums: map[string]*ast.EnumStatement{}}
 
 	parser.nextToken() // set currentToken
 	parser.nextToken() // set peekToken
@@ -62,12 +77,19 @@ func New(lexer *lexer.Lexer) *Parser {
 	parser.registerPrefix(token.TRUE, parser.parseBoolean)
 	parser.registerPrefix(token.FALSE, parser.parseBoolean)
 	parser.registerPrefix(token.INT, parser.parseIntegerLiteral)
+	parser.registerPrefix(token.DECIMAL, parser.parseDecimalLiteral)
 	parser.registerPrefix(token.LPAREN, parser.parseGroupedExpression)
 	parser.registerPrefix(token.BANG, parser.parsePrefixExpression)
 	parser.registerPrefix(token.MINUS, parser.parsePrefixExpression)
 	parser.registerPrefix(token.IF, parser.parseIfExpression)
 	parser.registerPrefix(token.FUNCTION, parser.parseFunctionLiteral)
+	parser.registerPrefix(token.ASYNC, parser.parseFunctionLiteral)
+	parser.registerPrefix(token.AWAIT, parser.parseAwaitExpression)
+	parser.registerPrefix(token.MACRO, parser.parseMacroLiteral)
 	parser.registerPrefix(token.LBRACKET, parser.parseArrayLiteral)
+	parser.registerPrefix(token.LBRACE, parser.parseHashLiteral)
+	parser.registerPrefix(token.MATCH, parser.parseMatchExpression)
+	parser.registerPrefix(token.SPAWN, parser.parseSpawnExpression)
 
 	parser.infixParseFuncs = make(map[token.Type]infixParseFunc)
 	parser.registerInfix(token.PLUS, parser.parseInfixExpression)
@@ -80,6 +102,8 @@ func New(lexer *lexer.Lexer) *Parser {
 	parser.registerInfix(token.GT, parser.parseInfixExpression)
 	parser.registerInfix(token.LPAREN, parser.parseCallExpression)
 	parser.registerInfix(token.LBRACKET, parser.parseIndexExpression)
+	parser.registerInfix(token.DOT, parser.parseDotExpression)
+	parser.registerInfix(token.ASSIGN, parser.parseAssignExpression)
 
 	return parser
 }
@@ -142,6 +166,20 @@ func (parser *Parser) parseStatement() ast.Statement {
 		return parser.parseReturnStatement()
 	case token.FOR:
 		return parser.parseForLoop()
+	case token.TRY:
+		return parser.parseTryStatement()
+	case token.THROW:
+		return parser.parseThrowStatement()
+	case token.STRUCT:
+		return parser.parseStructStatement()
+	case token.ENUM:
+		return parser.parseEnumStatement()
+	case token.YIELD:
+		return parser.parseYieldStatement()
+	case token.IMPORT:
+		return parser.parseImportStatement()
+	case token.EXPORT:
+		return parser.parseExportStatement()
 	default:
 		return parser.parseExpressionStatement()
 	}
@@ -155,6 +193,7 @@ func (parser *Parser) parseLetStatement() *ast.LetStatement {
 	}
 
 	statement.Name = &ast.Identifier{Token: parser.currentToken, Value: parser.currentToken.Literal}
+	statement.Type = parser.parseOptionalAnnotation()
 
 	if !parser.expectPeek(token.ASSIGN) {
 		return nil
@@ -170,6 +209,53 @@ func (parser *Parser) parseLetStatement() *ast.LetStatement {
 	return statement
 }
 
+func (parser *Parser) parseImportStatement() *ast.ImportStatement {
+	statement := &ast.ImportStatement{Token: parser.currentToken}
+
+	if !parser.expectPeek(token.STRING) {
+		return nil
+	}
+	statement.Path = &ast.StringLiteral{Token: parser.currentToken, Value: parser.currentToken.Literal}
+
+	if !parser.expectPeek(token.AS) || !parser.expectPeek(token.IDENTIFIER) {
+		return nil
+	}
+	statement.Name = &ast.Identifier{Token: parser.currentToken, Value: parser.currentToken.Literal}
+
+	if parser.peekTokenIs(token.SEMICOLON) {
+		parser.nextToken()
+	}
+
+	return statement
+}
+
+func (parser *Parser) parseExportStatement() *ast.ExportStatement {
+	statement := &ast.ExportStatement{Token: parser.currentToken}
+	parser.nextToken()
+
+	switch parser.currentToken.Type {
+	case token.LET:
+		if let := parser.parseLetStatement(); let != nil {
+			statement.Statement = let
+		}
+	case token.STRUCT:
+		if structure := parser.parseStructStatement(); structure != nil {
+			statement.Statement = structure
+		}
+	case token.ENUM:
+		if enum := parser.parseEnumStatement(); enum != nil {
+			statement.Statement = enum
+		}
+	default:
+		parser.errors = append(parser.errors, fmt.Sprintf("expected let, struct or enum after export, got %s instead", parser.currentToken.Type))
+	}
+
+	if statement.Statement == nil {
+		return nil
+	}
+	return statement
+}
+
 func (parser *Parser) parseForLoop() *ast.ForStatement {
 	statement := &ast.ForStatement{Token: parser.currentToken}
 
@@ -178,6 +264,13 @@ func (parser *Parser) parseForLoop() *ast.ForStatement {
 	switch parser.currentToken.Type {
 	case token.IDENTIFIER:
 		statement.Index = &ast.Identifier{Token: parser.currentToken, Value: parser.currentToken.Literal}
+		if parser.peekTokenIs(token.IN) {
+			parser.nextToken()
+			parser.nextToken()
+			statement.Iterable = parser.parseExpression(LOWEST)
+
+			return parser.parseForBody(statement)
+		}
 		if !parser.expectPeek(token.ASSIGN) {
 			return nil
 		}
@@ -185,17 +278,209 @@ func (parser *Parser) parseForLoop() *ast.ForStatement {
 		parser.nextToken()
 	}
 
-	statementRange := parser.parseExpression(LOWEST)
-	statement.Range = statementRange.(*ast.CallExpression)
+	statementRange, ok := parser.parseExpression(LOWEST).(*ast.CallExpression)
+	if !ok {
+		parser.errors = append(parser.errors, "expected a call such as range(n) in for loop")
+		return nil
+	}
+	statement.Range = statementRange
+
+	return parser.parseForBody(statement)
+}
 
+// parseForBody finishes either kind of for loop with its body.
+func (parser *Parser) parseForBody(statement *ast.ForStatement) *ast.ForStatement {
 	if !parser.expectPeek(token.LBRACE) {
 		return nil
 	}
 	statement.Body = parser.parseBlockStatement()
 
+	if parser.peekTokenIs(token.SEMICOLON) {
+		parser.nextToken()
+	}
+
 	return statement
 }
 
+func (parser *Parser) parseTryStatement() *ast.TryStatement {
+	statement := &ast.TryStatement{Token: parser.currentToken}
+
+	if !parser.expectPeek(token.LBRACE) {
+		return nil
+	}
+	statement.Block = parser.parseBlockStatement()
+
+	if parser.peekTokenIs(token.CATCH) {
+		parser.nextToken()
+
+		// the error binding is optional, `catch { ... }` simply discards it
+		if parser.peekTokenIs(token.LPAREN) {
+			parser.nextToken()
+			if !parser.expectPeek(token.IDENTIFIER) {
+				return nil
+			}
+			statement.Parameter = &ast.Identifier{Token: parser.currentToken, Value: parser.currentToken.Literal}
+			if !parser.expectPeek(token.RPAREN) {
+				return nil
+			}
+		}
+
+		if !parser.expectPeek(token.LBRACE) {
+			return nil
+		}
+		statement.Catch = parser.parseBlockStatement()
+	}
+
+	if parser.peekTokenIs(token.FINALLY) {
+		parser.nextToken()
+		if !parser.expectPeek(token.LBRACE) {
+			return nil
+		}
+		statement.Finally = parser.parseBlockStatement()
+	}
+
+	if statement.Catch == nil && statement.Finally == nil {
+		message := fmt.Sprintf("expected catch or finally after try block, got %s instead", parser.peekToken.Type)
+		parser.errors = append(parser.errors, message)
+		return nil
+	}
+
+	if parser.peekTokenIs(token.SEMICOLON) {
+		parser.nextToken()
+	}
+
+	return statement
+}
+
+func (parser *Parser) parseThrowStatement() *ast.ThrowStatement {
+	statement := &ast.ThrowStatement{Token: parser.currentToken}
+
+	parser.nextToken()
+
+	statement.Value = parser.parseExpression(LOWEST)
+
+	if parser.peekTokenIs(token.SEMICOLON) {
+		parser.nextToken()
+	}
+
+	return statement
+}
+
+func (parser *Parser) parseStructStatement() *ast.StructStatement {
+	statement := &ast.StructStatement{Token: parser.currentToken}
+
+	if !parser.expectPeek(token.IDENTIFIER) {
+		return nil
+	}
+	statement.Name = &ast.Identifier{Token: parser.currentToken, Value: parser.currentToken.Literal}
+
+	if !parser.expectPeek(token.LBRACE) {
+		return nil
+	}
+	parser.nextToken()
+
+	// fields and methods may be separated by commas, semicolons or nothing at all
+	for !parser.currentTokenIs(token.RBRACE) {
+		switch parser.currentToken.Type {
+		case token.EOF:
+			parser.throwPeekError(token.RBRACE)
+			return nil
+		case token.IDENTIFIER:
+			field := &ast.Identifier{Token: parser.currentToken, Value: parser.currentToken.Literal}
+			for _, existing := range statement.Fields {
+				if existing.Value == field.Value {
+					message := fmt.Sprintf("duplicate field %s in struct %s", field.Value, statement.Name.Value)
+					parser.errors = append(parser.errors, message)
+				}
+			}
+			statement.Fields = append(statement.Fields, field)
+		case token.FUNCTION, token.ASYNC:
+			method := parser.parseMethod()
+			if method == nil {
+				return nil
+			}
+			statement.Methods = append(statement.Methods, method)
+		case token.COMMA, token.SEMICOLON:
+		default:
+			message := fmt.Sprintf("expected field or method in struct %s, got %s instead",
+				statement.Name.Value, parser.currentToken.Type)
+			parser.errors = append(parser.errors, message)
+			return nil
+		}
+		parser.nextToken()
+	}
+
+	if parser.peekTokenIs(token.SEMICOLON) {
+		parser.nextToken()
+	}
+
+	return statement
+}
+
+func (parser *Parser) parseEnumStatement() *ast.EnumStatement {
+	statement := &ast.EnumStatement{Token: parser.currentToken}
+
+	if !parser.expectPeek(token.IDENTIFIER) {
+		return nil
+	}
+	statement.Name = &ast.Identifier{Token: parser.currentToken, Value: parser.currentToken.Literal}
+
+	if !parser.expectPeek(token.LBRACE) {
+		return nil
+	}
+	parser.nextToken()
+
+	for !parser.currentTokenIs(token.RBRACE) {
+		switch parser.currentToken.Type {
+		case token.EOF:
+			parser.throwPeekError(token.RBRACE)
+			return nil
+		case token.IDENTIFIER:
+			variant := &ast.EnumVariant{
+				Name: &ast.Identifier{Token: parser.currentToken, Value: parser.currentToken.Literal},
+			}
+			if parser.peekTokenIs(token.LPAREN) {
+				parser.nextToken()
+				variant.Fields = parser.parseFunctionParameters()
+			}
+			statement.Variants = append(statement.Variants, variant)
+		case token.COMMA, token.SEMICOLON:
+		default:
+			message := fmt.Sprintf("expected variant in enum %s, got %s instead",
+				statement.Name.Value, parser.currentToken.Type)
+			parser.errors = append(parser.errors, message)
+			return nil
+		}
+		parser.nextToken()
+	}
+
+	if parser.peekTokenIs(token.SEMICOLON) {
+		parser.nextToken()
+	}
+
+	parser.enums[statement.Name.Value] = statement
+	return statement
+}
+
+func (parser *Parser) parseMethod() *ast.FunctionLiteral {
+	method := &ast.FunctionLiteral{Token: parser.currentToken, Async: parser.currentTokenIs(token.ASYNC)}
+
+	if method.Async && !parser.expectPeek(token.FUNCTION) {
+		return nil
+	}
+	if !parser.expectPeek(token.IDENTIFIER) {
+		return nil
+	}
+	method.Name = parser.currentToken.Literal
+
+	if !parser.parseSignature(method) {
+		return nil
+	}
+	parser.parseFunctionBody(method)
+
+	return method
+}
+
 func (parser *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: parser.currentToken}
 	block.Statements = []ast.Statement{}
@@ -203,6 +488,9 @@ func (parser *Parser) parseBlockStatement() *ast.BlockStatement {
 
 	for !parser.currentTokenIs(token.RBRACE) && !parser.currentTokenIs(token.EOF) {
 		statement := parser.parseStatement()
+		if export, ok := statement.(*ast.ExportStatement); ok && export != nil {
+			parser.errors = append(parser.errors, "export outside of the top level of a file")
+		}
 		if statement != nil {
 			block.Statements = append(block.Statements, statement)
 		}
@@ -276,7 +564,79 @@ func (parser *Parser) parseCallExpression(function ast.Expression) ast.Expressio
 }
 
 func (parser *Parser) parseFunctionLiteral() ast.Expression {
-	literal := &ast.FunctionLiteral{Token: parser.currentToken}
+	literal := &ast.FunctionLiteral{Token: parser.currentToken, Async: parser.currentTokenIs(token.ASYNC)}
+
+	if literal.Async && !parser.expectPeek(token.FUNCTION) {
+		return nil
+	}
+	if !parser.parseSignature(literal) {
+		return nil
+	}
+	parser.parseFunctionBody(literal)
+
+	return literal
+}
+
+// parseSignature parses a function's parameters and return type, either of
+// which may be annotated with types, up to the opening brace of its body.
+func (parser *Parser) parseSignature(function *ast.FunctionLiteral) bool {
+	if !parser.expectPeek(token.LPAREN) {
+		return false
+	}
+
+	for !parser.peekTokenIs(token.RPAREN) {
+		if len(function.Parameters) > 0 && !parser.expectPeek(token.COMMA) {
+			return false
+		}
+		if !parser.expectPeek(token.IDENTIFIER) {
+			return false
+		}
+		parameter := &ast.Identifier{Token: parser.currentToken, Value: parser.currentToken.Literal}
+		function.Parameters = append(function.Parameters, parameter)
+		function.ParameterTypes = append(function.ParameterTypes, parser.parseOptionalAnnotation())
+	}
+	parser.nextToken()
+
+	function.ReturnType = parser.parseOptionalAnnotation()
+
+	return parser.expectPeek(token.LBRACE)
+}
+
+// parseOptionalAnnotation parses `: type` if it comes next.
+func (parser *Parser) parseOptionalAnnotation() *ast.TypeAnnotation {
+	if !parser.peekTokenIs(token.COLON) {
+		return nil
+	}
+	parser.nextToken()
+	parser.nextToken()
+
+	return parser.parseTypeAnnotation()
+}
+
+func (parser *Parser) parseTypeAnnotation() *ast.TypeAnnotation {
+	annotation := &ast.TypeAnnotation{Token: parser.currentToken}
+
+	switch parser.currentToken.Type {
+	case token.IDENTIFIER, token.FUNCTION:
+		annotation.Name = parser.currentToken.Literal
+	case token.LBRACKET:
+		parser.nextToken()
+		annotation.Element = parser.parseTypeAnnotation()
+		if annotation.Element == nil || !parser.expectPeek(token.RBRACKET) {
+			return nil
+		}
+		annotation.Name = "array"
+	default:
+		message := fmt.Sprintf("expected a type, got %s instead", parser.currentToken.Type)
+		parser.errors = append(parser.errors, message)
+		return nil
+	}
+
+	return annotation
+}
+
+func (parser *Parser) parseMacroLiteral() ast.Expression {
+	literal := &ast.MacroLiteral{Token: parser.currentToken}
 
 	if !parser.expectPeek(token.LPAREN) {
 		return nil
@@ -290,6 +650,45 @@ func (parser *Parser) parseFunctionLiteral() ast.Expression {
 	return literal
 }
 
+// parseFunctionBody parses the body of a function literal or method. A yield
+// in it, outside nested functions, marks the function as a generator.
+func (parser *Parser) parseFunctionBody(function *ast.FunctionLiteral) {
+	parser.functions = append(parser.functions, function)
+	function.Body = parser.parseBlockStatement()
+	parser.functions = parser.functions[:len(parser.functions)-1]
+}
+
+// currentFunction is the innermost function being parsed, nil at the top level.
+func (parser *Parser) currentFunction() *ast.FunctionLiteral {
+	if len(parser.functions) == 0 {
+		return nil
+	}
+	return parser.functions[len(parser.functions)-1]
+}
+
+func (parser *Parser) parseYieldStatement() *ast.YieldStatement {
+	statement := &ast.YieldStatement{Token: parser.currentToken}
+
+	switch function := parser.currentFunction(); {
+	case function == nil:
+		parser.errors = append(parser.errors, "yield outside of a function")
+	case function.Async:
+		parser.errors = append(parser.errors, "yield inside an async function")
+	default:
+		function.Generator = true
+	}
+
+	parser.nextToken()
+
+	statement.Value = parser.parseExpression(LOWEST)
+
+	if parser.peekTokenIs(token.SEMICOLON) {
+		parser.nextToken()
+	}
+
+	return statement
+}
+
 func (parser *Parser) parseFunctionParameters() []*ast.Identifier {
 	var identifiers []*ast.Identifier
 
@@ -325,6 +724,35 @@ func (parser *Parser) parseArrayLiteral() ast.Expression {
 	return array
 }
 
+func (parser *Parser) parseHashLiteral() ast.Expression {
+	hash := &ast.HashLiteral{Token: parser.currentToken}
+
+	for !parser.peekTokenIs(token.RBRACE) {
+		parser.nextToken()
+		key := parser.parseExpression(LOWEST)
+
+		if !parser.expectPeek(token.COLON) {
+			return nil
+		}
+
+		parser.nextToken()
+		value := parser.parseExpression(LOWEST)
+
+		hash.Keys = append(hash.Keys, key)
+		hash.Values = append(hash.Values, value)
+
+		if !parser.peekTokenIs(token.RBRACE) && !parser.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !parser.expectPeek(token.RBRACE) {
+		return nil
+	}
+
+	return hash
+}
+
 func (parser *Parser) parseExpressionList(end token.Type) []ast.Expression {
 	var list []ast.Expression
 
@@ -362,6 +790,219 @@ func (parser *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	return expression
 }
 
+func (parser *Parser) parseDotExpression(left ast.Expression) ast.Expression {
+	expression := &ast.DotExpression{Token: parser.currentToken, Left: left}
+
+	if !parser.expectPeek(token.IDENTIFIER) {
+		return nil
+	}
+	expression.Field = &ast.Identifier{Token: parser.currentToken, Value: parser.currentToken.Literal}
+
+	return expression
+}
+
+func (parser *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	expression := &ast.AssignExpression{Token: parser.currentToken, Target: target}
+
+	switch target.(type) {
+	case *ast.Identifier, *ast.DotExpression:
+	case nil:
+		return nil
+	default:
+		message := fmt.Sprintf("cannot assign to %s", target.String())
+		parser.errors = append(parser.errors, message)
+		return nil
+	}
+
+	// assignment is right associative, `a = b = c` assigns c to both
+	parser.nextToken()
+	expression.Value = parser.parseExpression(ASSIGN - 1)
+
+	return expression
+}
+
+func (parser *Parser) parseMatchExpression() ast.Expression {
+	expression := &ast.MatchExpression{Token: parser.currentToken}
+
+	if !parser.expectPeek(token.LPAREN) {
+		return nil
+	}
+	parser.nextToken()
+	expression.Subject = parser.parseExpression(LOWEST)
+
+	if !parser.expectPeek(token.RPAREN) {
+		return nil
+	}
+	if !parser.expectPeek(token.LBRACE) {
+		return nil
+	}
+	parser.nextToken()
+
+	for !parser.currentTokenIs(token.RBRACE) {
+		switch parser.currentToken.Type {
+		case token.EOF:
+			parser.throwPeekError(token.RBRACE)
+			return nil
+		case token.COMMA, token.SEMICOLON:
+			parser.nextToken()
+			continue
+		}
+
+		arm := parser.parseMatchArm()
+		if arm == nil {
+			return nil
+		}
+		expression.Arms = append(expression.Arms, arm)
+		parser.nextToken()
+	}
+
+	parser.matches = append(parser.matches, expression)
+	return expression
+}
+
+func (parser *Parser) parseMatchArm() *ast.MatchArm {
+	arm := &ast.MatchArm{Token: parser.currentToken}
+
+	if !parser.currentTokenIs(token.IDENTIFIER) {
+		message := fmt.Sprintf("expected a match pattern, got %s instead", parser.currentToken.Type)
+		parser.errors = append(parser.errors, message)
+		return nil
+	}
+
+	if parser.currentToken.Literal != "_" {
+		arm.Enum = &ast.Identifier{Token: parser.currentToken, Value: parser.currentToken.Literal}
+		if !parser.expectPeek(token.DOT) || !parser.expectPeek(token.IDENTIFIER) {
+			return nil
+		}
+		arm.Variant = &ast.Identifier{Token: parser.currentToken, Value: parser.currentToken.Literal}
+
+		if parser.peekTokenIs(token.LPAREN) {
+			parser.nextToken()
+			arm.Bindings = parser.parseFunctionParameters()
+			if arm.Bindings == nil {
+				arm.Bindings = []*ast.Identifier{}
+			}
+		}
+	}
+
+	if !parser.expectPeek(token.ARROW) {
+		return nil
+	}
+
+	// an arm's body is either a block or a single expression
+	if parser.peekTokenIs(token.LBRACE) {
+		parser.nextToken()
+		arm.Body = parser.parseBlockStatement()
+	} else {
+		parser.nextToken()
+		statement := &ast.ExpressionStatement{Token: parser.currentToken, Expression: parser.parseExpression(LOWEST)}
+		arm.Body = &ast.BlockStatement{Token: statement.Token, Statements: []ast.Statement{statement}}
+	}
+
+	return arm
+}
+
+// checkMatches reports matches on enums declared in the program that do not
+// cover every variant, name variants that do not exist or bind the wrong
+// number of values. Matches on enums declared elsewhere are checked at runtime.
+func (parser *Parser) checkMatches() {
+	for _, match := range parser.matches {
+		var enumName string
+		wildcard := false
+		covered := map[string]bool{}
+		mixed := false
+
+		for _, arm := range match.Arms {
+			if arm.IsWildcard() {
+				wildcard = true
+				continue
+			}
+			if enumName != "" && arm.Enum.Value != enumName {
+				message := fmt.Sprintf("match at %d:%d mixes variants of %s and %s",
+					match.Token.Line, match.Token.Column, enumName, arm.Enum.Value)
+				parser.errors = append(parser.errors, message)
+				mixed = true
+				break
+			}
+			enumName = arm.Enum.Value
+			covered[arm.Variant.Value] = true
+		}
+
+		declaration, ok := parser.enums[enumName]
+		if mixed || !ok {
+			continue
+		}
+
+		variants := map[string]*ast.EnumVariant{}
+		for _, variant := range declaration.Variants {
+			variants[variant.Name.Value] = variant
+		}
+
+		for _, arm := range match.Arms {
+			if arm.IsWildcard() {
+				continue
+			}
+			variant, ok := variants[arm.Variant.Value]
+			if !ok {
+				message := fmt.Sprintf("%s has no variant %s at %d:%d",
+					enumName, arm.Variant.Value, arm.Token.Line, arm.Token.Column)
+				parser.errors = append(parser.errors, message)
+				continue
+			}
+			if arm.Bindings != nil && len(arm.Bindings) != len(variant.Fields) {
+				message := fmt.Sprintf("%s.%s carries %d values, pattern at %d:%d binds %d", enumName,
+					arm.Variant.Value, len(variant.Fields), arm.Token.Line, arm.Token.Column, len(arm.Bindings))
+				parser.errors = append(parser.errors, message)
+			}
+		}
+
+		if wildcard {
+			continue
+		}
+
+		var missing []string
+		for _, variant := range declaration.Variants {
+			if !covered[variant.Name.Value] {
+				missing = append(missing, variant.Name.Value)
+			}
+		}
+		if len(missing) > 0 {
+			message := fmt.Sprintf("match at %d:%d on %s is not exhaustive, missing %s",
+				match.Token.Line, match.Token.Column, enumName, strings.Join(missing, ", "))
+			parser.errors = append(parser.errors, message)
+		}
+	}
+}
+
+func (parser *Parser) parseSpawnExpression() ast.Expression {
+	expression := &ast.SpawnExpression{Token: parser.currentToken}
+
+	parser.nextToken()
+	call, ok := parser.parseExpression(PREFIX).(*ast.CallExpression)
+	if !ok {
+		parser.errors = append(parser.errors, "expected a function call after spawn")
+		return nil
+	}
+	expression.Call = call
+
+	return expression
+}
+
+// parseAwaitExpression parses an await, which may appear at the top level of
+// a program or directly inside an async function.
+func (parser *Parser) parseAwaitExpression() ast.Expression {
+	expression := &ast.AwaitExpression{Token: parser.currentToken}
+
+	if function := parser.currentFunction(); function != nil && !function.Async {
+		parser.errors = append(parser.errors, "await outside of an async function")
+	}
+
+	parser.nextToken()
+	expression.Value = parser.parseExpression(PREFIX)
+
+	return expression
+}
+
 func (parser *Parser) parseIfExpression() ast.Expression {
 	expression := &ast.IfExpression{Token: parser.currentToken}
 
@@ -399,6 +1040,12 @@ func (parser *Parser) parseIntegerLiteral() ast.Expression {
 	literal := &ast.IntegerLiteral{Token: parser.currentToken}
 
 	value, err := strconv.ParseInt(parser.currentToken.Literal, 0, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		if huge, ok := new(big.Int).SetString(parser.currentToken.Literal, 0); ok {
+			literal.Big = huge
+			return literal
+		}
+	}
 	if err != nil {
 		message := fmt.Sprintf("could not parse %q as integer", parser.currentToken.Literal)
 		parser.errors = append(parser.errors, message)
@@ -409,6 +1056,10 @@ func (parser *Parser) parseIntegerLiteral() ast.Expression {
 	return literal
 }
 
+func (parser *Parser) parseDecimalLiteral() ast.Expression {
+	return &ast.DecimalLiteral{Token: parser.currentToken}
+}
+
 func (parser *Parser) parseStringLiteral() ast.Expression {
 	return &ast.StringLiteral{Token: parser.currentToken, Value: parser.currentToken.Literal}
 }
@@ -450,6 +1101,8 @@ func (parser *Parser) ParseProgram() *ast.Program {
 		parser.nextToken()
 	}
 
+	parser.checkMatches()
+
 	return program
 }
 
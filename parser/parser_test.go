@@ -51,6 +51,403 @@ func TestForLoopParsing(t *testing.T) {
 	}
 }
 
+func TestTryStatementParsing(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"try { x } catch (e) { y }", "try {x} catch (e) {y}"},
+		{"try { x } catch { y }", "try {x} catch {y}"},
+		{"try { x } finally { z }", "try {x} finally {z}"},
+		{"try { x } catch (e) { y } finally { z }", "try {x} catch (e) {y} finally {z}"},
+		{"try { x } catch { y };", "try {x} catch {y}"},
+		{"try { x } finally { z };", "try {x} finally {z}"},
+		{"throw x;", "throw x;"},
+	}
+
+	for _, testCase := range testCases {
+		program := setup(testCase.input, t)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program contains %d statements, not 1", len(program.Statements))
+		}
+		if program.String() != testCase.expected {
+			t.Errorf("expected %q, got %q", testCase.expected, program.String())
+		}
+	}
+}
+
+func TestTryWithoutHandlerError(t *testing.T) {
+	parser := New(lexerPackage.New("try { x }"))
+	parser.ParseProgram()
+
+	if len(parser.Errors()) != 1 {
+		t.Fatalf("expected 1 parser error, got %v", parser.Errors())
+	}
+}
+
+func TestStructStatementParsing(t *testing.T) {
+	input := `struct Point {
+	x, y;
+	func sum() { self.x + self.y }
+	func scale(factor) { Point(self.x * factor, self.y * factor) }
+}`
+
+	program := setup(input, t)
+	if len(program.Statements) != 1 {
+		t.Fatalf("program contains %d statements, not 1", len(program.Statements))
+	}
+
+	statement, ok := program.Statements[0].(*ast.StructStatement)
+	if !ok {
+		t.Fatalf("statement is not *ast.StructStatement, got %T", program.Statements[0])
+	}
+	if statement.Name.Value != "Point" {
+		t.Errorf("wrong struct name, got %q", statement.Name.Value)
+	}
+	if len(statement.Fields) != 2 || statement.Fields[0].Value != "x" || statement.Fields[1].Value != "y" {
+		t.Errorf("wrong fields, got %v", statement.Fields)
+	}
+	if len(statement.Methods) != 2 {
+		t.Fatalf("wrong number of methods, got %d", len(statement.Methods))
+	}
+	if statement.Methods[0].Name != "sum" || statement.Methods[1].Name != "scale" {
+		t.Errorf("wrong method names, got %q and %q", statement.Methods[0].Name, statement.Methods[1].Name)
+	}
+	if len(statement.Methods[1].Parameters) != 1 {
+		t.Errorf("wrong number of parameters for scale, got %d", len(statement.Methods[1].Parameters))
+	}
+
+	if program := setup("struct P { x }; P(1)", t); len(program.Statements) != 2 {
+		t.Errorf("a struct followed by a semicolon should leave 2 statements, got %d", len(program.Statements))
+	}
+}
+
+func TestDuplicateStructFieldError(t *testing.T) {
+	parser := New(lexerPackage.New("struct P { x, y, x }"))
+	parser.ParseProgram()
+
+	expected := []string{"duplicate field x in struct P"}
+	if len(parser.Errors()) != 1 || parser.Errors()[0] != expected[0] {
+		t.Errorf("expected errors %v, got %v", expected, parser.Errors())
+	}
+}
+
+func TestDotAndAssignParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a.b", "a.b"},
+		{"a.b.c", "a.b.c"},
+		{"a.b(1)", "a.b(1)"},
+		{"a.b[1] + c", "((a.b[1]) + c)"},
+		{"-a.b", "(-a.b)"},
+		{"a = 1 + 2", "(a = (1 + 2))"},
+		{"a.b = c.d", "(a.b = c.d)"},
+		{"a = b = c", "(a = (b = c))"},
+		{"a = b == c", "(a = (b == c))"},
+	}
+
+	for _, testCase := range tests {
+		program := setup(testCase.input, t)
+
+		if program.String() != testCase.expected {
+			t.Errorf("expected=%q, got=%q", testCase.expected, program.String())
+		}
+	}
+}
+
+func TestInvalidAssignmentTarget(t *testing.T) {
+	parser := New(lexerPackage.New("1 + 2 = 3"))
+	parser.ParseProgram()
+
+	if len(parser.Errors()) == 0 {
+		t.Fatalf("expected a parser error for an invalid assignment target")
+	}
+}
+
+func TestEnumAndMatchParsing(t *testing.T) {
+	input := `enum Shape { Circle(radius), Rect(width, height), Empty }
+match (s) {
+	Shape.Circle(r) => r * r,
+	Shape.Rect(w, h) => { w * h }
+	Shape.Empty => 0
+}`
+
+	program := setup(input, t)
+	if len(program.Statements) != 2 {
+		t.Fatalf("program contains %d statements, not 2", len(program.Statements))
+	}
+
+	enum, ok := program.Statements[0].(*ast.EnumStatement)
+	if !ok {
+		t.Fatalf("statement is not *ast.EnumStatement, got %T", program.Statements[0])
+	}
+	if enum.String() != "enum Shape {Circle(radius), Rect(width, height), Empty}" {
+		t.Errorf("wrong enum, got %q", enum.String())
+	}
+
+	statement := program.Statements[1].(*ast.ExpressionStatement)
+	match, ok := statement.Expression.(*ast.MatchExpression)
+	if !ok {
+		t.Fatalf("expression is not *ast.MatchExpression, got %T", statement.Expression)
+	}
+	expected := "match (s) {Shape.Circle(r) => (r * r), Shape.Rect(w, h) => (w * h), Shape.Empty => 0}"
+	if match.String() != expected {
+		t.Errorf("expected %q, got %q", expected, match.String())
+	}
+
+	if program := setup("enum E { A }; E.A", t); len(program.Statements) != 2 {
+		t.Errorf("an enum followed by a semicolon should leave 2 statements, got %d", len(program.Statements))
+	}
+}
+
+func TestMatchExhaustiveness(t *testing.T) {
+	declaration := "enum Shape { Circle(radius), Rect(width, height), Empty }\n"
+	testCases := []struct {
+		input    string
+		expected []string
+	}{
+		{"match (s) { Shape.Circle(r) => r, Shape.Empty => 0 }",
+			[]string{"match at 2:1 on Shape is not exhaustive, missing Rect"}},
+		{"match (s) { Shape.Circle(r) => r, _ => 0 }", nil},
+		{"match (s) { Shape.Circle => 1, Shape.Rect => 2, Shape.Empty => 3 }", nil},
+		{"match (s) { Shape.Circle(a, b) => 1, _ => 0 }",
+			[]string{"Shape.Circle carries 1 values, pattern at 2:13 binds 2"}},
+		{"match (s) { Shape.Square => 1, _ => 0 }",
+			[]string{"Shape has no variant Square at 2:13"}},
+		{"match (s) { Shape.Empty => 1, Other.Empty => 0 }",
+			[]string{"match at 2:1 mixes variants of Shape and Other"}},
+		{"match (s) { Other.A => 1 }", nil},
+	}
+
+	for _, testCase := range testCases {
+		parser := New(lexerPackage.New(declaration + testCase.input))
+		parser.ParseProgram()
+
+		errors := parser.Errors()
+		if len(errors) != len(testCase.expected) {
+			t.Errorf("expected errors %q, got %q", testCase.expected, errors)
+			continue
+		}
+		for index, message := range testCase.expected {
+			if errors[index] != message {
+				t.Errorf("expected error %q, got %q", message, errors[index])
+			}
+		}
+	}
+}
+
+func TestForInLoopParsing(t *testing.T) {
+	program := setup("for x in [1, 2] { x }", t)
+	if len(program.Statements) != 1 {
+		t.Fatalf("program contains %d statements, not 1", len(program.Statements))
+	}
+
+	statement, ok := program.Statements[0].(*ast.ForStatement)
+	if !ok {
+		t.Fatalf("statement is not *ast.ForStatement, got %T", program.Statements[0])
+	}
+	if !testIdentifier(t, statement.Index, "x") {
+		return
+	}
+	if _, ok := statement.Iterable.(*ast.ArrayLiteral); !ok {
+		t.Errorf("iterable is not *ast.ArrayLiteral, got %T", statement.Iterable)
+	}
+	if statement.String() != "for x in [1, 2] {x}" {
+		t.Errorf("wrong string, got %q", statement.String())
+	}
+
+	for _, input := range []string{"for x in [1, 2] { x }; x", "for i = range(2) { i }; i"} {
+		if program := setup(input, t); len(program.Statements) != 2 {
+			t.Errorf("%s should be 2 statements, got %d", input, len(program.Statements))
+		}
+	}
+}
+
+func TestGeneratorParsing(t *testing.T) {
+	program := setup("func() { yield 1; let inner = func() { 2 } }; func() { func() { yield 3 } }", t)
+
+	outer := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	if !outer.Generator {
+		t.Errorf("function with yield is not a generator")
+	}
+	inner := outer.Body.Statements[1].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+	if inner.Generator {
+		t.Errorf("function without yield is a generator")
+	}
+	wrapper := program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	if wrapper.Generator {
+		t.Errorf("yield in a nested function made the outer function a generator")
+	}
+
+	parser := New(lexerPackage.New("yield 1"))
+	parser.ParseProgram()
+	if len(parser.Errors()) != 1 || parser.Errors()[0] != "yield outside of a function" {
+		t.Errorf("expected a yield outside of a function error, got %v", parser.Errors())
+	}
+}
+
+func TestBigIntegerLiteral(t *testing.T) {
+	program := setup("123456789012345678901234567890;", t)
+
+	literal, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.IntegerLiteral)
+	if !ok {
+		t.Fatalf("expression is not *ast.IntegerLiteral, got %T", program.Statements[0].(*ast.ExpressionStatement).Expression)
+	}
+	if literal.Big == nil || literal.Big.String() != "123456789012345678901234567890" {
+		t.Errorf("literal.Big is wrong, got %v", literal.Big)
+	}
+	if literal.String() != "123456789012345678901234567890" {
+		t.Errorf("literal.String() is wrong, got %q", literal.String())
+	}
+}
+
+func TestDecimalLiteral(t *testing.T) {
+	program := setup("19.99 * 3;", t)
+
+	infix := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.InfixExpression)
+	literal, ok := infix.Left.(*ast.DecimalLiteral)
+	if !ok {
+		t.Fatalf("expression is not *ast.DecimalLiteral, got %T", infix.Left)
+	}
+	if literal.String() != "19.99" {
+		t.Errorf("literal.String() is wrong, got %q", literal.String())
+	}
+}
+
+func TestMacroLiteralParsing(t *testing.T) {
+	program := setup("macro(x, y) { x + y; }", t)
+
+	macro, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.MacroLiteral)
+	if !ok {
+		t.Fatalf("expression is not *ast.MacroLiteral, got %T", program.Statements[0].(*ast.ExpressionStatement).Expression)
+	}
+	if len(macro.Parameters) != 2 || macro.Parameters[0].Value != "x" || macro.Parameters[1].Value != "y" {
+		t.Errorf("macro parameters are wrong, got %v", macro.Parameters)
+	}
+	if macro.String() != "macro(x, y)(x + y)" {
+		t.Errorf("macro.String() is wrong, got %q", macro.String())
+	}
+}
+
+func TestTypeAnnotationParsing(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"let x: int = 5;", "let x: int = 5;"},
+		{"let xs: [string] = [];", "let xs: [string] = [];"},
+		{"func(x: int, y): string { y }", "func(x: int, y): stringy"},
+		{"let f: func = func(p: Point): [int] { [] };", "let f: func = func(p: Point): [int][];"},
+		{"struct P { x\n func at(i: int): int { i } }", "struct P {x; func at(i: int): inti}"},
+	}
+
+	for _, testCase := range testCases {
+		program := setup(testCase.input, t)
+		if program.String() != testCase.expected {
+			t.Errorf("expected %q, got %q", testCase.expected, program.String())
+		}
+	}
+
+	function := setup("func(x: int, y) { y }", t).Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	if len(function.ParameterTypes) != 2 || function.ParameterTypes[0].Name != "int" || function.ParameterTypes[1] != nil {
+		t.Errorf("parameter types are wrong, got %v", function.ParameterTypes)
+	}
+
+	parser := New(lexerPackage.New("let x: 5 = 1;"))
+	parser.ParseProgram()
+	if len(parser.Errors()) == 0 || parser.Errors()[0] != "expected a type, got INT instead" {
+		t.Errorf("expected a missing type error, got %v", parser.Errors())
+	}
+}
+
+func TestImportExportParsing(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{`import "lib/util.plug" as util`, `import "lib/util.plug" as util;`},
+		{"export let x = 1;", "export let x = 1;"},
+		{"export struct P { x }", "export struct P {x}"},
+		{"export enum Color { Red }", "export enum Color {Red}"},
+	}
+
+	for _, testCase := range testCases {
+		program := setup(testCase.input, t)
+		if program.String() != testCase.expected {
+			t.Errorf("expected %q, got %q", testCase.expected, program.String())
+		}
+	}
+
+	errorCases := []struct {
+		input    string
+		expected string
+	}{
+		{"import util", "expected next token to be STRING, got IDENTIFIER instead"},
+		{`import "util.plug" util`, "expected next token to be AS, got IDENTIFIER instead"},
+		{"export 1", "expected let, struct or enum after export, got INT instead"},
+		{"if (true) { export let x = 1 }", "export outside of the top level of a file"},
+		{"func() { export let x = 1 }", "export outside of the top level of a file"},
+	}
+
+	for _, testCase := range errorCases {
+		parser := New(lexerPackage.New(testCase.input))
+		parser.ParseProgram()
+		if len(parser.Errors()) == 0 || parser.Errors()[0] != testCase.expected {
+			t.Errorf("expected error %q for %q, got %v", testCase.expected, testCase.input, parser.Errors())
+		}
+	}
+}
+
+func TestSpawnParsing(t *testing.T) {
+	program := setup("spawn worker(1, ch)", t)
+
+	spawn, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.SpawnExpression)
+	if !ok {
+		t.Fatalf("expression is not *ast.SpawnExpression, got %T", program.Statements[0].(*ast.ExpressionStatement).Expression)
+	}
+	if spawn.String() != "spawn worker(1, ch)" {
+		t.Errorf("spawn expression is wrong, got %q", spawn.String())
+	}
+
+	parser := New(lexerPackage.New("spawn worker"))
+	parser.ParseProgram()
+	if len(parser.Errors()) != 1 || parser.Errors()[0] != "expected a function call after spawn" {
+		t.Errorf("expected a missing call error, got %v", parser.Errors())
+	}
+}
+
+func TestAsyncParsing(t *testing.T) {
+	program := setup("let f = async func(x) { await sleep(x) + 1 }; await f(2)", t)
+
+	if program.String() != "let f = async func(x)(await sleep(x) + 1);await f(2)" {
+		t.Errorf("program is wrong, got %q", program.String())
+	}
+	function := program.Statements[0].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+	if !function.Async {
+		t.Errorf("function is not async")
+	}
+
+	errorCases := []struct {
+		input         string
+		expectedError string
+	}{
+		{"func() { await x }", "await outside of an async function"},
+		{"async func() { func() { await x } }", "await outside of an async function"},
+		{"async func() { yield 1 }", "yield inside an async function"},
+	}
+
+	for _, testCase := range errorCases {
+		parser := New(lexerPackage.New(testCase.input))
+		parser.ParseProgram()
+		if len(parser.Errors()) != 1 || parser.Errors()[0] != testCase.expectedError {
+			t.Errorf("expected %q for %q, got %v", testCase.expectedError, testCase.input, parser.Errors())
+		}
+	}
+}
+
 func TestReturnStatements(t *testing.T) {
 	testCases := []struct {
 		input         string
@@ -385,6 +782,38 @@ func TestArrayLiteralParsing(t *testing.T) {
 	testInfixExpression(t, array.Elements[2], 4, "+", 5)
 }
 
+func TestHashLiteralParsing(t *testing.T) {
+	input := `{"one": 1, "two": 2 * 3, true: 4 + 5}`
+	program := setup(input, t)
+
+	statement := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := statement.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("expression is not an ast.HashLiteral, got %T", statement.Expression)
+	}
+
+	if len(hash.Keys) != 3 || len(hash.Values) != 3 {
+		t.Fatalf("hash should have 3 pairs, got %d", len(hash.Keys))
+	}
+	if hash.String() != `{one: 1, two: (2 * 3), true: (4 + 5)}` {
+		t.Errorf("hash is wrong, got %q", hash.String())
+	}
+	testIntegerLiteral(t, hash.Values[0], 1)
+	testInfixExpression(t, hash.Values[1], 2, "*", 3)
+	testInfixExpression(t, hash.Values[2], 4, "+", 5)
+
+	empty := setup("{}", t).Statements[0].(*ast.ExpressionStatement).Expression.(*ast.HashLiteral)
+	if len(empty.Keys) != 0 {
+		t.Errorf("empty hash should have no pairs, got %d", len(empty.Keys))
+	}
+
+	parser := New(lexerPackage.New(`{"a" 1}`))
+	parser.ParseProgram()
+	if len(parser.Errors()) == 0 || parser.Errors()[0] != "expected next token to be :, got INT instead" {
+		t.Errorf("expected a missing colon error, got %v", parser.Errors())
+	}
+}
+
 func TestIndexExpressionParsing(t *testing.T) {
 	input := "myArray[1 + 1]"
 	program := setup(input, t)
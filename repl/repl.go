@@ -1,8 +1,6 @@
 package repl
 
 import (
-	"bufio"
-	"fmt"
 	"github.com/noculture/plug/evaluator"
 	"github.com/noculture/plug/lexer"
 	"github.com/noculture/plug/object"
@@ -12,37 +10,68 @@ import (
 
 const PROMPT = "~> "
 
-func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
+// Start reads and evaluates lines until the input ends or `exit` is called,
+// returning the status given to `exit` or 0.
+func Start(in io.Reader, out io.Writer) int {
+	// lines are read through the runtime so `input` shares them, and what
+	// programs print goes to out along with the results and errors
+	runtime := object.NewRuntime()
+	runtime.Stdin = in
+	runtime.Stdout = out
+	runtime.Stderr = out
+	env := object.NewEnvironmentWithRuntime(runtime)
+	macroEnv := object.NewEnvironment()
 
 	for {
-		fmt.Printf(PROMPT)
-		if scanned := scanner.Scan(); !scanned {
-			return
+		_ = runtime.WriteOut(PROMPT)
+		line, readErr := runtime.ReadLine()
+		if readErr != nil {
+			return 0
 		}
 
-		line := scanner.Text()
 		lex := lexer.New(line)
 		p := parser.New(lex)
 
 		program := p.ParseProgram()
 		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
+			printParserErrors(runtime, p.Errors())
 			continue
 		}
 
-		evaluated := evaluator.Eval(program, env)
+		evaluator.DefineMacros(program, macroEnv)
+		expanded, err := evaluator.ExpandMacros(program, macroEnv)
+		if err != nil {
+			_ = runtime.WriteErr(err.Traceback())
+			continue
+		}
+
+		evaluated := evaluator.Eval(expanded, env)
+		if err, ok := evaluated.(*object.Error); ok {
+			if err.Kind == object.EXIT {
+				return err.Status
+			}
+			_ = runtime.WriteErr(err.Traceback())
+			continue
+		}
+
+		// async functions the line started finish before the next prompt
+		loop := runtime.EventLoop()
+		loop.Run()
+		if exit := loop.Stopped(); exit != nil {
+			return exit.Status
+		}
+		for _, err := range loop.Unhandled() {
+			_ = runtime.WriteErr("Unhandled rejection: " + err.Traceback())
+		}
 		if evaluated != nil {
-			_, _ = io.WriteString(out, evaluated.Inspect())
-			_, _ = io.WriteString(out, "\n")
+			_ = runtime.WriteOut(evaluated.Inspect() + "\n")
 		}
 	}
 }
 
-func printParserErrors(out io.Writer, errors []string) {
-	_, _ = io.WriteString(out, "Parser errors:\n")
+func printParserErrors(runtime *object.Runtime, errors []string) {
+	_ = runtime.WriteErr("Parser errors:\n")
 	for _, msg := range errors {
-		_, _ = io.WriteString(out, "\t"+msg+"\n")
+		_ = runtime.WriteErr("\t" + msg + "\n")
 	}
 }
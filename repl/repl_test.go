@@ -0,0 +1,54 @@
+package repl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestStartStatus(t *testing.T) {
+	testCases := []struct {
+		input  string
+		status int
+	}{
+		{"1 + 2\n", 0},
+		{"1 / 0\nlet = 1\n", 0},
+		{"print(1)\nexit(3)\nprint(2)\n", 3},
+		{"exit()", 0},
+		{"let f = async func() { await sleep(1); exit(3) }\nf()\nprint(2)\n", 3},
+	}
+
+	for _, testCase := range testCases {
+		var out bytes.Buffer
+		status := Start(strings.NewReader(testCase.input), &out)
+		if status != testCase.status {
+			t.Errorf("%q should end with %d, got %d", testCase.input, testCase.status, status)
+		}
+		if strings.Contains(out.String(), "2\n") {
+			t.Errorf("%q should stop at exit, got %q", testCase.input, out.String())
+		}
+	}
+}
+
+func TestStartWritesToOut(t *testing.T) {
+	var out bytes.Buffer
+	Start(strings.NewReader("print(\"hi\")\n1 + 2\nmissing\n"), &out)
+
+	expected := PROMPT + "hi\nnull\n" + PROMPT + "3\n" + PROMPT + "Error: identifier not found: missing [1:1]\n" + PROMPT
+	if out.String() != expected {
+		t.Errorf("the prompt, output and results should all be written to out, expected %q, got %q", expected, out.String())
+	}
+}
+
+func TestStartReportsUnhandledRejections(t *testing.T) {
+	var out bytes.Buffer
+	status := Start(strings.NewReader("let f = async func() { await sleep(1); throw \"lost\" }\nf(); 1\n2\n"), &out)
+
+	if status != 0 {
+		t.Errorf("an unhandled rejection should not end the session, got status %d", status)
+	}
+	expected := PROMPT + PROMPT + "Unhandled rejection: Error: lost [1:40]\n    at f(0 args) 1:2\n1\n" + PROMPT + "2\n" + PROMPT
+	if out.String() != expected {
+		t.Errorf("expected %q, got %q", expected, out.String())
+	}
+}
@@ -8,11 +8,40 @@ import (
 	"github.com/noculture/plug/parser"
 	"io"
 	"io/ioutil"
+	"path/filepath"
 )
 
-func Start(in io.Reader, out io.Writer) {
-	scanner, _ := ioutil.ReadAll(in)
-	env := object.NewEnvironment()
+// Start runs the program read from in, with everything it prints and any
+// errors written to out.
+func Start(in io.Reader, out io.Writer) int {
+	runtime := object.NewRuntime()
+	runtime.Stdout = out
+	runtime.Stderr = out
+	return StartFile("", nil, in, runtime)
+}
+
+// StartFile runs a program read from the named file, which imports in it are
+// resolved against, with `args` bound to the given arguments. The program
+// uses the runtime's streams, and parser errors and tracebacks go to its
+// Stderr. The file counts as being imported while it runs, so a module
+// importing it back is reported as a cycle. It returns the status the
+// process should exit with: the one given to `exit`, 1 when the program
+// fails and 0 otherwise.
+func StartFile(filename string, args []string, source io.Reader, runtime *object.Runtime) int {
+	scanner, _ := ioutil.ReadAll(source)
+	env := object.NewEnvironmentWithRuntime(runtime)
+	if filename != "" {
+		if path, err := filepath.Abs(filename); err == nil {
+			env = object.NewFileEnvironment(runtime, path)
+			env.SetImports([]string{path})
+		}
+	}
+
+	arguments := make([]object.Object, len(args))
+	for index, arg := range args {
+		arguments[index] = &object.String{Value: arg}
+	}
+	env.Set("args", &object.Array{Elements: arguments})
 
 	input := bytes.NewBuffer(scanner).String()
 
@@ -21,16 +50,45 @@ func Start(in io.Reader, out io.Writer) {
 
 	program := p.ParseProgram()
 	if len(p.Errors()) != 0 {
-		printParserErrors(out, p.Errors())
-		return
+		printParserErrors(runtime, p.Errors())
+		return 1
 	}
 
-	evaluator.Eval(program, env)
+	macroEnv := object.NewEnvironment()
+	evaluator.DefineMacros(program, macroEnv)
+	expanded, err := evaluator.ExpandMacros(program, macroEnv)
+	if err != nil {
+		_ = runtime.WriteErr(err.Traceback())
+		return 1
+	}
+
+	evaluated := evaluator.Eval(expanded, env)
+	if err, ok := evaluated.(*object.Error); ok {
+		if err.Kind == object.EXIT {
+			return err.Status
+		}
+		_ = runtime.WriteErr(err.Traceback())
+		return 1
+	}
+
+	// let async functions the program started but never awaited run to the
+	// end, failing the program when one does with nothing to handle it
+	loop := runtime.EventLoop()
+	loop.Run()
+	if exit := loop.Stopped(); exit != nil {
+		return exit.Status
+	}
+	status := 0
+	for _, err := range loop.Unhandled() {
+		_ = runtime.WriteErr("Unhandled rejection: " + err.Traceback())
+		status = 1
+	}
+	return status
 }
 
-func printParserErrors(out io.Writer, errors []string) {
-	_, _ = io.WriteString(out, "Parser errors:\n")
+func printParserErrors(runtime *object.Runtime, errors []string) {
+	_ = runtime.WriteErr("Parser errors:\n")
 	for _, msg := range errors {
-		_, _ = io.WriteString(out, "\t"+msg+"\n")
+		_ = runtime.WriteErr("\t" + msg + "\n")
 	}
 }
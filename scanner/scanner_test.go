@@ -0,0 +1,79 @@
+package scanner
+
+import (
+	"bytes"
+	"github.com/noculture/plug/object"
+	"strings"
+	"testing"
+)
+
+func testStartFile(source string) (int, string) {
+	var out bytes.Buffer
+	runtime := object.NewRuntime()
+	runtime.VirtualClock = true
+	runtime.Stdout = &out
+	runtime.Stderr = &out
+
+	status := StartFile("", nil, strings.NewReader(source), runtime)
+	return status, out.String()
+}
+
+func TestStartFileStatus(t *testing.T) {
+	testCases := []struct {
+		source   string
+		status   int
+		expected string
+	}{
+		{`print(1 + 2)`, 0, "3\n"},
+		{`print("before"); 1 / 0; print("after")`, 1, "before\nError: division by zero [1:20]\n"},
+		{`let = 1`, 1, "Parser errors:\n\texpected next token to be IDENTIFIER"},
+		{`print("bye"); exit(3); print("after")`, 3, "bye\n"},
+		{`try { exit(3) } catch (e) { print("caught") }`, 3, ""},
+		{"let f = async func() { await sleep(10); exit(4) }\nf()", 4, ""},
+		{"let f = async func() { await sleep(10); exit(4) }\nf()\nawait sleep(100)\nprint(\"after\")", 4, ""},
+		{"let f = async func() { print(\"bye\"); exit(5) }\nf()\nprint(\"after\")", 5, "bye\n"},
+		{"let f = async func() { await sleep(10); exit(4) }\nlet g = async func() { await sleep(20); print(\"after\") }\nf(); g()", 4, ""},
+	}
+
+	for _, testCase := range testCases {
+		status, out := testStartFile(testCase.source)
+		if status != testCase.status {
+			t.Errorf("%s should exit with %d, got %d", testCase.source, testCase.status, status)
+		}
+		if !strings.HasPrefix(out, testCase.expected) || strings.Contains(out, "after") || strings.Contains(out, "caught") {
+			t.Errorf("%s should print %q, got %q", testCase.source, testCase.expected, out)
+		}
+	}
+}
+
+func TestStartFileFinishesAsyncFunctions(t *testing.T) {
+	source := `let later = async func(text) { await sleep(10); print(text) }
+later("done")
+eval("later(1)")
+print("first")`
+
+	status, out := testStartFile(source)
+	if status != 0 {
+		t.Fatalf("expected status 0, got %d with output %q", status, out)
+	}
+	if out != "first\ndone\n1\n" {
+		t.Errorf("async functions should finish once the program has, got %q", out)
+	}
+}
+
+func TestStartFileReportsUnhandledRejections(t *testing.T) {
+	source := `let f = async func() { await sleep(10); throw "lost" }
+let handled = async func() { await sleep(10); throw "caught" }
+f()
+let p = handled()
+try { await p } catch (e) { print(e.value) }`
+
+	status, out := testStartFile(source)
+	if status != 1 {
+		t.Errorf("an unhandled rejection should fail the program, got status %d", status)
+	}
+	expected := "caught\nUnhandled rejection: Error: lost [1:41]\n    at f(0 args) 3:2\n"
+	if out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+}
@@ -0,0 +1,34 @@
+// Package stdlib holds the standard library, modules written in Plug that
+// are compiled into the binary and imported as "std/<name>".
+package stdlib
+
+import (
+	"embed"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+//go:embed *.plug
+var files embed.FS
+
+// Source returns the source of the named module.
+func Source(name string) (string, bool) {
+	source, err := files.ReadFile(name + ".plug")
+	if err != nil {
+		return "", false
+	}
+	return string(source), true
+}
+
+// Names lists the modules in the standard library in sorted order.
+func Names() []string {
+	entries, _ := fs.ReadDir(files, ".")
+
+	var names []string
+	for _, entry := range entries {
+		names = append(names, strings.TrimSuffix(entry.Name(), ".plug"))
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,89 @@
+package stdlib_test
+
+import (
+	"github.com/noculture/plug/evaluator"
+	"github.com/noculture/plug/lexer"
+	"github.com/noculture/plug/object"
+	"github.com/noculture/plug/parser"
+	"github.com/noculture/plug/stdlib"
+	"testing"
+)
+
+func TestEveryModuleImports(t *testing.T) {
+	for _, name := range stdlib.Names() {
+		evaluated := run(t, `import "std/`+name+`" as module; type(module)`)
+		if evaluated.Inspect() != object.MODULE {
+			t.Errorf("std/%s should import cleanly, got %s", name, evaluated.Inspect())
+		}
+	}
+
+	if _, ok := stdlib.Source("missing"); ok {
+		t.Errorf("a module that does not exist should have no source")
+	}
+}
+
+func TestList(t *testing.T) {
+	testModule(t, "list", []moduleCase{
+		{`list.reduce([1, 2, 3], 10, func(total, x) { total + x })`, "16"},
+		{`list.reduce([], "start", func(total, x) { total + x })`, "start"},
+		{`let seen = []; list.each([1, 2], func(x) { seen = push(seen, x * 2) }); seen`, "[2, 4]"},
+		{`list.each([1, 2], func(x) { x })`, "[1, 2]"},
+		{`list.contains([1, 2, 3], 2)`, "true"},
+		{`list.contains(["a"], "b")`, "false"},
+		{`list.index_of(["a", "b", "c"], "c")`, "2"},
+		{`list.index_of([1], 5)`, "-1"},
+		{`list.reverse([1, 2, 3])`, "[3, 2, 1]"},
+		{`list.reverse([])`, "[]"},
+		{`list.concat([1, 2], [3])`, "[1, 2, 3]"},
+		{`list.sum([1, 2, 3, 4])`, "10"},
+		{`list.sum([])`, "0"},
+		{`let pairs = list.enumerate(["a", "b"]); [next(pairs), next(pairs)]`, "[[0, a], [1, b]]"},
+	})
+}
+
+func TestFn(t *testing.T) {
+	testModule(t, "fn", []moduleCase{
+		{`fn.identity(7)`, "7"},
+		{`fn.constant(3)()`, "3"},
+		{`fn.compose(func(x) { x + 1 }, func(x) { x * 2 })(5)`, "11"},
+		{`fn.flip(func(a, b) { a - b })(1, 10)`, "9"},
+		{`fn.partial(func(a, b) { a * b }, 6)(7)`, "42"},
+	})
+}
+
+func TestAssert(t *testing.T) {
+	testModule(t, "assert", []moduleCase{
+		{`assert.ok(1 < 2, "ordered")`, "true"},
+		{`try { assert.ok(false, "broken") } catch (e) { e.value }`, "broken"},
+		{`assert.equal("a" + "b", "ab")`, "true"},
+		{`try { assert.equal(1 + 1, 3) } catch (e) { e.value }`, "expected 3, got 2"},
+	})
+}
+
+type moduleCase struct {
+	input    string
+	expected string
+}
+
+func testModule(t *testing.T, name string, testCases []moduleCase) {
+	for _, testCase := range testCases {
+		evaluated := run(t, `import "std/`+name+`" as `+name+`; `+testCase.input)
+		if evaluated.Inspect() != testCase.expected {
+			t.Errorf("%s should be %s, got %s", testCase.input, testCase.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func run(t *testing.T, input string) object.Object {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+
+	evaluated := evaluator.Eval(program, object.NewEnvironment())
+	if evaluated == nil {
+		t.Fatalf("%q evaluated to nothing", input)
+	}
+	return evaluated
+}
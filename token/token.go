@@ -5,6 +5,8 @@ type Type string
 type Token struct {
 	Type    Type
 	Literal string
+	Line    int
+	Column  int
 }
 
 const (
@@ -13,6 +15,7 @@ const (
 
 	IDENTIFIER = "IDENTIFIER"
 	INT        = "INT"
+	DECIMAL    = "DECIMAL"
 	STRING     = "STRING"
 
 	ASSIGN   = "="
@@ -23,11 +26,14 @@ const (
 	ASTERISK = "*"
 	EQ       = "=="
 	NOT_EQ   = "!="
+	ARROW    = "=>"
 
 	LT = "<"
 	GT = ">"
 
 	COMMA     = ","
+	COLON     = ":"
+	DOT       = "."
 	SEMICOLON = ";"
 	LPAREN    = "("
 	RPAREN    = ")"
@@ -44,17 +50,49 @@ const (
 	IF       = "IF"
 	ELSE     = "ELSE"
 	FOR      = "FOR"
+	IN       = "IN"
+	TRY      = "TRY"
+	CATCH    = "CATCH"
+	FINALLY  = "FINALLY"
+	THROW    = "THROW"
+	STRUCT   = "STRUCT"
+	ENUM     = "ENUM"
+	MATCH    = "MATCH"
+	YIELD    = "YIELD"
+	SPAWN    = "SPAWN"
+	ASYNC    = "ASYNC"
+	AWAIT    = "AWAIT"
+	MACRO    = "MACRO"
+	IMPORT   = "IMPORT"
+	EXPORT   = "EXPORT"
+	AS       = "AS"
 )
 
 var keywords = map[string]Type{
-	"func":   FUNCTION,
-	"let":    LET,
-	"return": RETURN,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"for":    FOR,
+	"func":    FUNCTION,
+	"let":     LET,
+	"return":  RETURN,
+	"true":    TRUE,
+	"false":   FALSE,
+	"if":      IF,
+	"else":    ELSE,
+	"for":     FOR,
+	"in":      IN,
+	"try":     TRY,
+	"catch":   CATCH,
+	"finally": FINALLY,
+	"throw":   THROW,
+	"struct":  STRUCT,
+	"enum":    ENUM,
+	"match":   MATCH,
+	"yield":   YIELD,
+	"spawn":   SPAWN,
+	"async":   ASYNC,
+	"await":   AWAIT,
+	"macro":   MACRO,
+	"import":  IMPORT,
+	"export":  EXPORT,
+	"as":      AS,
 }
 
 func LookUpIdentifier(identifier string) Type {